@@ -16,7 +16,6 @@ limitations under the License.
 package cmd
 
 import (
-	"crypto"
 	"fmt"
 	"os"
 
@@ -25,8 +24,6 @@ import (
 	"github.com/spf13/cobra"
 )
 
-const DefaultHashAlgorithm = crypto.SHA1
-
 const Flag_Calc_NoShowPath = "no-show-path"
 
 // calcCmd represents the calc command
@@ -44,19 +41,26 @@ func init() {
 }
 
 func runCalcHash(cmd *cobra.Command, args []string) (int, error) {
+	alg, err := getHashAlg(cmd)
+	if err != nil {
+		return ExitStatus_Fatal, err
+	}
+
+	status := ExitStatus_OK
 	for _, v := range args {
 		if isDir, _ := IsDirectory(v); isDir {
 			// skip directory
 			continue
 		}
-		if isSymLink, _ := IsSymbolicLink(v); isSymLink {
+		if isSymLink, _ := IsSymbolicLink(v); isSymLink && !IsFollowingSymlinks() {
 			// skip symlink
 			continue
 		}
 
-		hash, err := core.CalcHash(v, core.NewDefaultHashAlg())
+		hash, err := core.CalcHash(v, alg)
 		if err != nil {
 			ShowError(err)
+			status = ExitStatus_PartialFailure
 			continue
 		}
 		if f, _ := cmd.Flags().GetBool(Flag_Calc_NoShowPath); !f {
@@ -65,5 +69,5 @@ func runCalcHash(cmd *cobra.Command, args []string) (int, error) {
 			fmt.Fprintf(os.Stdout, "%s\n", hash) // nolint:errcheck
 		}
 	}
-	return 0, nil
+	return status, nil
 }
@@ -0,0 +1,233 @@
+/*
+Copyright © 2022 Yusuke KOMORI
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	. "github.com/little-forest/hasher/common" // nolint:staticcheck
+	"github.com/little-forest/hasher/core"
+	"github.com/spf13/cobra"
+)
+
+const Flag_Catalog_showOnlyDifferences = "show-only-differences"
+
+// catalogCmd represents the catalog command
+var catalogCmd = &cobra.Command{
+	Use:   "catalog",
+	Short: "Query the catalog database",
+	Long: `Query the catalog database specified by --catalog option or the config file.
+Hash values are recorded to the catalog whenever they are calculated or checked,
+so the catalog can be queried even after the disk is unmounted.`,
+	Example: `
+  (1) Record hash values to the catalog
+        hasher --catalog CATALOG_FILE update -r DIR...
+
+  (2) List recorded files under the directory
+        hasher --catalog CATALOG_FILE catalog list DIR
+
+  (3) Compare two directories recorded in the catalog
+        hasher --catalog CATALOG_FILE catalog diff BASE_DIR TARGET_DIR
+`,
+}
+
+var catalogListCmd = &cobra.Command{
+	Use:   "list [DIR...]",
+	Short: "List files recorded in the catalog",
+	RunE:  statusWrapper.RunE(runCatalogList),
+}
+
+var catalogDiffCmd = &cobra.Command{
+	Use:   "diff BASE_DIR TARGET_DIR",
+	Args:  cobra.ExactArgs(2),
+	Short: "Compares two directories recorded in the catalog",
+	Long: `Compares two directories recorded in the catalog and displays the differences.
+Files are paired by relative path.
+
+  [=] : same file
+  [+] : added file
+  [-] : removed file
+  [>] : different file (base is newer)
+  [<] : different file (target is newer)
+  [~] : different file (modtime is same)
+`,
+	RunE: statusWrapper.RunE(runCatalogDiff),
+}
+
+var catalogRemoveCmd = &cobra.Command{
+	Use:   "remove DIR...",
+	Args:  cobra.MinimumNArgs(1),
+	Short: "Remove files under the directory from the catalog",
+	RunE:  statusWrapper.RunE(runCatalogRemove),
+}
+
+func init() {
+	rootCmd.AddCommand(catalogCmd)
+	catalogCmd.AddCommand(catalogListCmd)
+	catalogCmd.AddCommand(catalogDiffCmd)
+	catalogCmd.AddCommand(catalogRemoveCmd)
+
+	catalogDiffCmd.Flags().BoolP(Flag_Catalog_showOnlyDifferences, "d", false, "Show only differences")
+}
+
+// openActiveCatalog opens the catalog specified by --catalog option or the config file.
+// The returned function must be called after use.
+func openActiveCatalog(writable bool) (*core.Catalog, func(), error) {
+	path := core.GetCatalogPath()
+	if path == "" {
+		return nil, nil, fmt.Errorf("catalog is not specified. use --catalog option or set it in the config file")
+	}
+	return openCatalog(path, writable)
+}
+
+// catalogDirPrefix returns an absolute directory path with trailing separator
+// to be used as a prefix of catalog entries.
+func catalogDirPrefix(dir string) (string, error) {
+	p, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasSuffix(p, string(filepath.Separator)) {
+		p += string(filepath.Separator)
+	}
+	return p, nil
+}
+
+func runCatalogList(cmd *cobra.Command, args []string) (int, error) {
+	c, closeCatalog, err := openActiveCatalog(false)
+	if err != nil {
+		return ExitStatus_Fatal, err
+	}
+	defer closeCatalog()
+	alg, err := getHashAlg(cmd)
+	if err != nil {
+		return ExitStatus_Fatal, err
+	}
+
+	out, err := newRecordWriter(cmd)
+	if err != nil {
+		return ExitStatus_Fatal, err
+	}
+
+	prefixes := []string{""}
+	if len(args) > 0 {
+		prefixes = make([]string, len(args))
+		for i, a := range args {
+			if prefixes[i], err = catalogDirPrefix(a); err != nil {
+				return ExitStatus_Fatal, err
+			}
+		}
+	}
+
+	for _, prefix := range prefixes {
+		err := c.Walk(prefix, func(entry *core.CatalogEntry) error {
+			h := entry.Hash(alg)
+			if h == nil {
+				return nil
+			}
+			if out != nil {
+				return out.Write(core.NewHashRecord(h.Path, []*core.Hash{h}))
+			}
+			fmt.Println(h.Tsv())
+			return nil
+		})
+		if err != nil {
+			return ExitStatus_Fatal, err
+		}
+	}
+	if out != nil {
+		if err := out.Close(); err != nil {
+			return ExitStatus_Fatal, err
+		}
+	}
+	return ExitStatus_OK, nil
+}
+
+func runCatalogDiff(cmd *cobra.Command, args []string) (int, error) {
+	c, closeCatalog, err := openActiveCatalog(false)
+	if err != nil {
+		return ExitStatus_Fatal, err
+	}
+	defer closeCatalog()
+	alg, err := getHashAlg(cmd)
+	if err != nil {
+		return ExitStatus_Fatal, err
+	}
+	showOnlyDiff, _ := cmd.Flags().GetBool(Flag_Catalog_showOnlyDifferences)
+
+	base, err := filepath.Abs(args[0])
+	if err != nil {
+		return ExitStatus_Fatal, err
+	}
+	target, err := filepath.Abs(args[1])
+	if err != nil {
+		return ExitStatus_Fatal, err
+	}
+
+	out, err := newRecordWriter(cmd)
+	if err != nil {
+		return ExitStatus_Fatal, err
+	}
+
+	diffs, err := c.Diff(base, target, alg)
+	if err != nil {
+		return ExitStatus_Fatal, err
+	}
+
+	for _, f := range diffs {
+		if showOnlyDiff && f.Status == core.SAME {
+			continue
+		}
+		if out != nil {
+			if err := out.Write(core.NewDiffRecord("", f, alg)); err != nil {
+				return ExitStatus_Fatal, err
+			}
+			continue
+		}
+		fmt.Println(getColorByStatus(f.Status).Apply(fmt.Sprintf("%s %s", f.StatusMark(), f.Basename)))
+	}
+	if out != nil {
+		if err := out.Close(); err != nil {
+			return ExitStatus_Fatal, err
+		}
+	}
+	return ExitStatus_OK, nil
+}
+
+func runCatalogRemove(cmd *cobra.Command, args []string) (int, error) {
+	c, closeCatalog, err := openActiveCatalog(true)
+	if err != nil {
+		return ExitStatus_Fatal, err
+	}
+	defer closeCatalog()
+
+	for _, a := range args {
+		prefix, err := catalogDirPrefix(a)
+		if err != nil {
+			return ExitStatus_Fatal, err
+		}
+		count, err := c.Remove(prefix)
+		if err != nil {
+			return ExitStatus_Fatal, err
+		}
+		if v, _ := cmd.Flags().GetBool(Flag_root_Verbose); v {
+			fmt.Printf("%s %d entries removed : %s\n", Mark_OK, count, prefix)
+		}
+	}
+	return ExitStatus_OK, nil
+}
@@ -0,0 +1,124 @@
+/*
+Copyright © 2022 Yusuke KOMORI
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	. "github.com/little-forest/hasher/common" // nolint:staticcheck
+	"github.com/little-forest/hasher/core"
+	"github.com/spf13/cobra"
+)
+
+const Flag_Check_Full = "full"
+
+// checkCmd represents the check command
+var checkCmd = &cobra.Command{
+	Use:   "check [--full] CHECKSUM_FILE...",
+	Args:  cobra.MinimumNArgs(1),
+	Short: "Check files with checksum files (sha256sum -c compatible)",
+	Long: `Reads checksum files written in the coreutils format (VALUE  PATH)
+or the BSD style format (ALG (PATH) = VALUE) and checks the listed files.
+Specify "-" to read from stdin.
+
+Checksum files written by other tools (e.g. sha256sum, shasum, b3sum) can be read.
+The hash algorithm of coreutils format lines is decided in order of
+--algorithm option, the name of the checksum file (e.g. FILE.sha256, SHA256SUMS),
+and the length of hash values.
+
+Stored hash values are reused if size and mtime of the file are unchanged.
+Specify --full to always read file contents.
+
+  [OK]     : hash value matches
+  [FAILED] : hash value doesn't match
+  [ERROR]  : file can't be read
+
+Exit status:
+  0 : all files are OK
+  1 : some files failed or could not be read
+  2 : fatal error
+`,
+	RunE: statusWrapper.RunE(runCheck),
+}
+
+func init() {
+	rootCmd.AddCommand(checkCmd)
+
+	checkCmd.Flags().Bool(Flag_Check_Full, false, "always read file contents instead of reusing stored hash values")
+}
+
+func runCheck(cmd *cobra.Command, args []string) (int, error) {
+	verbose, _ := cmd.Flags().GetBool(Flag_root_Verbose)
+	full, _ := cmd.Flags().GetBool(Flag_Check_Full)
+
+	a, err := getHashAlg(cmd)
+	if err != nil {
+		return ExitStatus_Fatal, err
+	}
+	// algorithm given by --algorithm option is always used,
+	// while the default one is used only when it matches the length of hash values
+	var alg, preferredAlg *core.HashAlg
+	if s, _ := cmd.Flags().GetString(Flag_root_Algorithm); s != "" {
+		alg = a
+	} else {
+		preferredAlg = a
+	}
+
+	var ok, failed, errors, malformed int
+	for _, p := range args {
+		entries, lineErrors, err := core.ReadChecksumFile(p, alg, preferredAlg)
+		if err != nil {
+			ShowError(err)
+			errors++
+			continue
+		}
+		for _, e := range lineErrors {
+			ShowWarn("%s", e.Error())
+		}
+		malformed += len(lineErrors)
+		if len(entries) == 0 {
+			ShowErrorMsg("no properly formatted checksum lines found : %s", p)
+			errors++
+			continue
+		}
+
+		for _, entry := range entries {
+			matched, err := core.CheckChecksum(entry, full)
+			if err != nil {
+				fmt.Printf("%s %s (%s)\n", Mark_Error, entry.Path, err.Error())
+				errors++
+			} else if matched {
+				if verbose {
+					fmt.Printf("%s %s\n", Mark_OK, entry.Path)
+				}
+				ok++
+			} else {
+				fmt.Printf("%s %s\n", Mark_Failed, entry.Path)
+				failed++
+			}
+		}
+	}
+
+	if verbose {
+		fmt.Fprintf(os.Stderr, "OK: %d, FAILED: %d, ERROR: %d, IMPROPERLY FORMATTED: %d\n", ok, failed, errors, malformed)
+	}
+
+	if failed > 0 || errors > 0 {
+		return ExitStatus_PartialFailure, nil
+	}
+	return ExitStatus_OK, nil
+}
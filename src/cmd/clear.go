@@ -18,7 +18,6 @@ package cmd
 import (
 	"fmt"
 	"io/fs"
-	"path/filepath"
 
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
@@ -44,12 +43,13 @@ func runClear(cmd *cobra.Command, args []string) (int, error) {
 	verbose, _ := cmd.Flags().GetBool(Flag_root_Verbose)
 	recuesive, _ := cmd.Flags().GetBool(Flag_root_Recursive)
 
-	status := 0
+	status := ExitStatus_OK
 	var errResult error
 	for _, p := range args {
 		ftype, err := CheckFileType(p)
 		if err != nil {
 			ShowError(err)
+			status = ExitStatus_PartialFailure
 			continue
 		}
 
@@ -69,7 +69,7 @@ func runClear(cmd *cobra.Command, args []string) (int, error) {
 		}
 		if err != nil {
 			ShowError(err)
-			status = 1
+			status = ExitStatus_PartialFailure
 			errResult = err
 		}
 	}
@@ -82,28 +82,28 @@ func clear(path string) error {
 		return err
 	}
 	// skip symlink
-	if yes, _ := common.IsSymbolicLink(path); yes {
+	if yes, _ := common.IsSymbolicLink(path); yes && !common.IsFollowingSymlinks() {
 		return nil
 	}
-	return core.ClearXattr(file)
+	return core.ClearAttr(file)
 }
 
 func clearRecursively(dirPath string, verbose bool) error {
-	paths := []string{dirPath}
-	totalCount := CountAllFiles(paths, verbose)
+	counter := StartCountingFiles([]string{dirPath})
+	defer counter.Stop()
 
 	var n core.ProgressNotifier = NewHasherProgressNotifier(1, verbose)
-	n.SetTotal(totalCount)
+	n.SetFileCounter(counter)
 	n.Start()
 
 	count := 0
 
-	err := filepath.WalkDir(dirPath, func(path string, info fs.DirEntry, err error) error {
+	err := WalkDirFiltered(dirPath, func(path string, info fs.DirEntry, err error) error {
 		if err != nil {
 			return errors.Wrap(err, "failed to filepath.Walk")
 		}
 
-		if info.IsDir() {
+		if info.IsDir() || IsHasherFile(path) {
 			return nil
 		}
 
@@ -117,10 +117,12 @@ func clearRecursively(dirPath string, verbose bool) error {
 		}
 		count++
 		n.NotifyTaskDone(0, resultMsg)
-		n.NotifyProgress(count, totalCount)
+		n.NotifyProgress(count, -1)
 
 		return nil
 	})
+	// all files are walked
+	n.NotifyProgress(count, count)
 	n.Shutdown()
 	return err
 }
@@ -36,27 +36,30 @@ func init() {
 
 func runCompare(cmd *cobra.Command, args []string) (int, error) {
 	if len(args) < 2 {
-		return -1, fmt.Errorf("too few arguments")
+		return ExitStatus_Fatal, fmt.Errorf("too few arguments")
 	}
 
-	result, err := compare(args[0], args[1])
+	alg, err := getHashAlg(cmd)
 	if err != nil {
-		return 1, nil
+		return ExitStatus_Fatal, err
+	}
+
+	result, err := compare(args[0], args[1], alg)
+	if err != nil {
+		return ExitStatus_Fatal, err
 	}
 
 	if result {
-		return 0, nil
+		return ExitStatus_OK, nil
 	} else {
-		return 1, nil
+		return ExitStatus_PartialFailure, nil
 	}
 }
 
 /*
 Return true if given two failes have same hash value.
 */
-func compare(path1 string, path2 string) (bool, error) {
-	hashAlg := core.NewDefaultHashAlg()
-
+func compare(path1 string, path2 string, hashAlg *core.HashAlg) (bool, error) {
 	_, hash1, err := core.UpdateHashStrictly(path1, hashAlg, false)
 	if err != nil {
 		return false, err
@@ -0,0 +1,234 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/little-forest/hasher/common" // nolint:staticcheck
+	"github.com/little-forest/hasher/core"
+	"github.com/spf13/cobra"
+)
+
+// Environment variable to override config file path
+const Env_ConfigPath = "HASHER_CONFIG"
+
+// Config keys
+const Config_Algorithm = "algorithm"
+const Config_Store = "store"
+const Config_Catalog = "catalog"
+
+// hasherConfig holds settings loaded from the config file.
+//
+// The config file is a plain text file located at $HASHER_CONFIG or
+// <UserConfigDir>/hasher/config, which contains one `key = value` pair per line.
+// Lines starting with '#' are ignored.
+//
+//	# default hash algorithm
+//	algorithm = sha256
+//	# where hash attributes are stored (xattr, sidecar, auto)
+//	store = auto
+//	# catalog database file
+//	catalog = ~/.local/share/hasher/catalog.db
+type hasherConfig struct {
+	values map[string]string
+}
+
+var config = &hasherConfig{values: make(map[string]string)}
+
+func getConfigPath() string {
+	if p := os.Getenv(Env_ConfigPath); p != "" {
+		return p
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "hasher", "config")
+}
+
+// loadConfig loads the config file.
+// If the config file doesn't exist, it returns empty config.
+func loadConfig(path string) (*hasherConfig, error) {
+	c := &hasherConfig{values: make(map[string]string)}
+	if path == "" {
+		return c, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return c, nil
+		}
+		return nil, err
+	}
+	// nolint:errcheck
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		pos := strings.Index(line, "=")
+		if pos == -1 {
+			return nil, fmt.Errorf("invalid config line : %s:%d", path, lineNo)
+		}
+		key := strings.TrimSpace(line[:pos])
+		value := strings.TrimSpace(line[pos+1:])
+		c.values[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c hasherConfig) Get(key string, defaultValue string) string {
+	if v, ok := c.values[key]; ok && v != "" {
+		return v
+	}
+	return defaultValue
+}
+
+// getHashAlgs returns hash algorithms specified by --algorithm option.
+// Multiple algorithms can be specified by separating them with commas.
+// When the option is omitted, the algorithm in the config file is used.
+func getHashAlgs(cmd *cobra.Command) ([]*core.HashAlg, error) {
+	algNames, _ := cmd.Flags().GetString(Flag_root_Algorithm)
+	if algNames == "" {
+		algNames = config.Get(Config_Algorithm, core.DefaultHashAlgName)
+	}
+
+	algs := make([]*core.HashAlg, 0)
+	for _, algName := range strings.Split(algNames, ",") {
+		algName = strings.ToLower(strings.TrimSpace(algName))
+		if algName == "" {
+			continue
+		}
+		alg := core.NewHashAlgFromString(algName)
+		if alg == nil {
+			return nil, fmt.Errorf("unsupported hash algorithm : %s", algName)
+		}
+		for _, a := range algs {
+			if a == alg {
+				return nil, fmt.Errorf("duplicated hash algorithm : %s", algName)
+			}
+		}
+		algs = append(algs, alg)
+	}
+	if len(algs) == 0 {
+		return nil, fmt.Errorf("no hash algorithm specified")
+	}
+	return algs, nil
+}
+
+// getHashAlg returns a hash algorithm specified by --algorithm option.
+// It is used by sub-commands which deal with only one algorithm.
+func getHashAlg(cmd *cobra.Command) (*core.HashAlg, error) {
+	algs, err := getHashAlgs(cmd)
+	if err != nil {
+		return nil, err
+	}
+	if len(algs) > 1 {
+		return nil, fmt.Errorf("%s doesn't support multiple hash algorithms", cmd.Name())
+	}
+	return algs[0], nil
+}
+
+// setupAttrStore selects the storage backend of hash attributes
+// specified by --store option or the config file.
+func setupAttrStore(cmd *cobra.Command) error {
+	storeName, _ := cmd.Flags().GetString(Flag_root_Store)
+	if storeName == "" {
+		storeName = config.Get(Config_Store, core.AttrStore_Auto)
+	}
+
+	store, err := core.NewAttrStore(strings.ToLower(storeName))
+	if err != nil {
+		return err
+	}
+	return core.SetAttrStore(store)
+}
+
+// setupWalkFilter sets the filter and symbolic link mode of walking directories specified by options.
+func setupWalkFilter(cmd *cobra.Command) error {
+	f := WalkFilter{}
+	f.Excludes, _ = cmd.Flags().GetStringArray(Flag_root_Exclude)
+	f.Includes, _ = cmd.Flags().GetStringArray(Flag_root_Include)
+
+	var err error
+	if v, _ := cmd.Flags().GetString(Flag_root_MinSize); v != "" {
+		if f.MinSize, err = ParseSize(v); err != nil {
+			return err
+		}
+	}
+	if v, _ := cmd.Flags().GetString(Flag_root_MaxSize); v != "" {
+		if f.MaxSize, err = ParseSize(v); err != nil {
+			return err
+		}
+	}
+	f.OneFileSystem, _ = cmd.Flags().GetBool(Flag_root_OneFileSystem)
+	f.FsTypeIncludes, _ = cmd.Flags().GetStringSlice(Flag_root_IncludeFsType)
+	f.FsTypeExcludes, _ = cmd.Flags().GetStringSlice(Flag_root_ExcludeFsType)
+	if err := SetWalkFilter(f); err != nil {
+		return err
+	}
+
+	follow, _ := cmd.Flags().GetBool(Flag_root_FollowSymlinks)
+	SetFollowSymlinks(follow)
+	return nil
+}
+
+// setupCatalog sets the catalog database specified by --catalog option or the config file.
+// It is opened only when hash values are recorded, so that commands which don't update them
+// can run while another process is using it.
+func setupCatalog(cmd *cobra.Command) error {
+	catalogPath, _ := cmd.Flags().GetString(Flag_root_Catalog)
+	if catalogPath == "" {
+		catalogPath = config.Get(Config_Catalog, "")
+	}
+	if catalogPath == "" {
+		return nil
+	}
+
+	catalogPath, err := CleanPath(catalogPath)
+	if err != nil {
+		return err
+	}
+	core.SetCatalogPath(catalogPath)
+	return nil
+}
+
+// openCatalog returns the catalog of given path.
+// If it is the catalog already opened by --catalog option, it is reused.
+// Otherwise it is opened read-only unless writable is true.
+// The returned function must be called after use.
+func openCatalog(path string, writable bool) (*core.Catalog, func(), error) {
+	if c := core.GetCatalog(); c != nil {
+		p1, _ := filepath.Abs(c.Path())
+		p2, _ := filepath.Abs(path)
+		if p1 == p2 {
+			return c, func() {}, nil
+		}
+	}
+
+	open := core.OpenCatalogReadOnly
+	if writable {
+		open = core.OpenCatalog
+	}
+	c, err := open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return c, func() {
+		if err := c.Close(); err != nil {
+			ShowError(err)
+		}
+	}, nil
+}
@@ -0,0 +1,145 @@
+/*
+Copyright © 2022 Yusuke KOMORI
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	. "github.com/little-forest/hasher/common" // nolint:staticcheck
+	"github.com/little-forest/hasher/core"
+	"github.com/spf13/cobra"
+)
+
+const Flag_Dedupe_Action = "action"
+const Flag_Dedupe_Keep = "keep"
+const Flag_Dedupe_Prefer = "prefer"
+const Flag_Dedupe_DryRun = "dry-run"
+
+// dedupeCmd represents the dedupe command
+var dedupeCmd = &cobra.Command{
+	Use:   "dedupe --action (hardlink|reflink|delete) (HASH_LIST_TSV|DIR|CATALOG)...",
+	Short: "Deduplicate identical files",
+	Long: `Leaves only one file of each group of identical files reported by the dupes sub-command,
+and replaces the others with hard links or reflinks of it, or deletes them.
+
+  hardlink : replace duplicates with hard links (must be on the same filesystem)
+  reflink  : replace duplicates with copy-on-write clones (Btrfs, XFS, etc.)
+  delete   : delete duplicates
+
+The file to keep is chosen by --keep option (oldest, newest or shortest path).
+Files under directories specified by --prefer option are kept in preference to others.
+
+Before each file is changed, its contents are compared byte-for-byte with the kept file,
+and it is replaced atomically, so a file which is not identical is never lost.
+Each line shows the action, the deduplicated file and the kept file, separated by tabs.
+With --dry-run option, only the plan is shown without changing anything.
+
+Exit status:
+  0 : all duplicates are deduplicated
+  1 : some files could not be deduplicated
+  2 : fatal error
+`,
+	Example: `
+  (1) Show the plan to replace duplicates with hard links, keeping the oldest file
+        hasher dedupe --action hardlink -n DIR
+
+  (2) Delete duplicates, keeping files under DIR1
+        hasher dedupe --action delete --prefer DIR1 DIR1 DIR2
+`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: statusWrapper.RunE(runDedupe),
+}
+
+func init() {
+	rootCmd.AddCommand(dedupeCmd)
+
+	dedupeCmd.Flags().String(Flag_Dedupe_Action, "", "action to deduplicate : hardlink, reflink or delete")
+	dedupeCmd.Flags().StringP(Flag_Dedupe_Keep, "k", core.KeepPolicy_Oldest, "file to keep : oldest, newest or shortest")
+	dedupeCmd.Flags().StringArrayP(Flag_Dedupe_Prefer, "p", []string{}, "keep files under the directory in preference to others (can be specified multiple times)")
+	dedupeCmd.Flags().BoolP(Flag_Dedupe_DryRun, "n", false, "show the plan without changing anything")
+}
+
+func runDedupe(cmd *cobra.Command, args []string) (int, error) {
+	action, _ := cmd.Flags().GetString(Flag_Dedupe_Action)
+	keep, _ := cmd.Flags().GetString(Flag_Dedupe_Keep)
+	preferDirs, _ := cmd.Flags().GetStringArray(Flag_Dedupe_Prefer)
+	dryRun, _ := cmd.Flags().GetBool(Flag_Dedupe_DryRun)
+	if action == "" {
+		return ExitStatus_Fatal, fmt.Errorf("--%s option is required", Flag_Dedupe_Action)
+	}
+	// checked before scanning, which may take long
+	if err := core.ValidateDedupeAction(action); err != nil {
+		return ExitStatus_Fatal, err
+	}
+
+	alg, err := getHashAlg(cmd)
+	if err != nil {
+		return ExitStatus_Fatal, err
+	}
+	policy, err := core.NewKeepPolicy(keep, preferDirs)
+	if err != nil {
+		return ExitStatus_Fatal, err
+	}
+
+	store, summary, err := loadDupesHashData(args, alg)
+	if err != nil {
+		return ExitStatus_Fatal, err
+	}
+	// files which could not be scanned are counted as failures
+	var failed int
+	if summary != nil {
+		showSummaryErrors(summary)
+		failed = len(summary.Errors())
+	}
+	steps, err := core.PlanDedupe(core.FindDuplicateGroups(store), action, policy)
+	if err != nil {
+		return ExitStatus_Fatal, err
+	}
+
+	if dryRun {
+		var freed int64
+		for _, s := range steps {
+			fmt.Printf("%s\t%s\t%s\n", s.Action, s.Target, s.Keep)
+			freed += s.Size
+		}
+		fmt.Fprintf(os.Stderr, "%d files would be deduplicated, %s would be freed\n", len(steps), FormatSize(freed))
+		return dedupeStatus(failed)
+	}
+
+	var done int
+	var freed int64
+	for _, s := range steps {
+		if err := s.Apply(); err != nil {
+			ShowWarn("Failed to %s : %s (reason : %s)", s.Action, s.Target, err.Error())
+			failed++
+			continue
+		}
+		fmt.Printf("%s\t%s\t%s\n", s.Action, s.Target, s.Keep)
+		done++
+		freed += s.Size
+	}
+	fmt.Fprintf(os.Stderr, "%d files deduplicated, %s freed, %d failed\n", done, FormatSize(freed), failed)
+	return dedupeStatus(failed)
+}
+
+func dedupeStatus(failed int) (int, error) {
+	if failed == 0 {
+		return ExitStatus_OK, nil
+	}
+	err := &core.PartialFailureError{NumOfErrors: failed}
+	return exitStatusOf(err), err
+}
@@ -57,25 +57,38 @@ func runDirDiff(cmd *cobra.Command, args []string) (int, error) {
 	path2 := args[1]
 
 	if err := checkDirectory(path1); err != nil {
-		return 1, err
+		return ExitStatus_Fatal, err
 	}
 	if err := checkDirectory(path2); err != nil {
-		return 1, err
+		return ExitStatus_Fatal, err
 	}
 
 	showOnlyDiff, _ := cmd.Flags().GetBool(Flag_DirDiff_showOnlyDifferences)
 
-	status, err := dirDiff(path1, path2, showOnlyDiff, true)
+	alg, err := getHashAlg(cmd)
+	if err != nil {
+		return ExitStatus_Fatal, err
+	}
+
+	out, err := newRecordWriter(cmd)
+	if err != nil {
+		return ExitStatus_Fatal, err
+	}
+	if out != nil {
+		return dirDiffRecords(path1, path2, alg, showOnlyDiff, out)
+	}
+
+	status, err := dirDiff(path1, path2, alg, showOnlyDiff, true)
 
 	return status, err
 }
 
-func dirDiff(basePath string, targetPath string, showOnlyDiff bool, verbose bool) (int, error) {
+func dirDiff(basePath string, targetPath string, alg *core.HashAlg, showOnlyDiff bool, verbose bool) (int, error) {
 	// diff
-	dirPairs, err := core.DirDiffRecursively(basePath, targetPath)
+	dirPairs, err := core.DirDiffRecursively(basePath, targetPath, alg)
 	if err != nil {
 		common.ShowErrorMsg("dirdiff failed : %s", err.Error())
-		return 1, nil
+		return ExitStatus_Fatal, nil
 	}
 
 	// display
@@ -99,7 +112,32 @@ func dirDiff(basePath string, targetPath string, showOnlyDiff bool, verbose bool
 	}
 
 	// RESULT
-	return 0, err
+	return ExitStatus_OK, err
+}
+
+// dirDiffRecords writes the differences of each file as JSON records.
+func dirDiffRecords(basePath string, targetPath string, alg *core.HashAlg, showOnlyDiff bool, out *core.RecordWriter) (int, error) {
+	dirPairs, err := core.DirDiffRecursively(basePath, targetPath, alg)
+	if err != nil {
+		common.ShowErrorMsg("dirdiff failed : %s", err.Error())
+		return ExitStatus_Fatal, nil
+	}
+
+	for _, pair := range dirPairs {
+		d := pair.Base
+		if pair.Status == core.TARGET_ONLY {
+			d = pair.Target
+		}
+		for _, f := range d.GetSortedChildren() {
+			if showOnlyDiff && f.Status == core.SAME {
+				continue
+			}
+			if err := out.Write(core.NewDiffRecord(pair.Path(), f, alg)); err != nil {
+				return ExitStatus_Fatal, err
+			}
+		}
+	}
+	return ExitStatus_OK, out.Close()
 }
 
 func displayDir(d *core.DirDiff, showOnlyDiff bool) {
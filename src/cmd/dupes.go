@@ -0,0 +1,173 @@
+/*
+Copyright © 2022 Yusuke KOMORI
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	. "github.com/little-forest/hasher/common" // nolint:staticcheck
+	"github.com/little-forest/hasher/core"
+	"github.com/spf13/cobra"
+)
+
+// dupesCmd represents the dupes command
+var dupesCmd = &cobra.Command{
+	Use:   "dupes (HASH_LIST_TSV|DIR|CATALOG)...",
+	Short: "Report groups of identical files",
+	Long: `Reports every group of identical files within given directories,
+hash lists output by the list-hash sub-command, or catalog database files.
+
+Groups are sorted in descending order of wasted bytes,
+which can be freed by leaving only one file of each group.
+Each line shows wasted bytes, size of each file, number of files, the hash value
+and paths of the group, separated by tabs.
+Hard links of the same file are already deduplicated, so they are counted only once
+and shown with "hardlink:" prefix.
+
+Files in directories are narrowed down before being hashed:
+files of unique size are never read, and files whose first and last 64 KiB
+differ from all others of the same size are not read any further.
+Hash values stored in attributes are reused if they are still valid.
+
+Totals of all groups are shown to stderr at the end,
+followed by the number of files scanned, hashed and skipped in directories.
+
+Exit status:
+  0 : all files are compared
+  1 : some files could not be read
+  2 : fatal error
+`,
+	Example: `
+  (1) Find duplicated files scattered across directories
+        hasher dupes DIR1 DIR2
+
+  (2) Show the 10 largest groups
+        hasher dupes DIR | head -n 10
+`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: statusWrapper.RunE(runDupes),
+}
+
+func init() {
+	rootCmd.AddCommand(dupesCmd)
+}
+
+func runDupes(cmd *cobra.Command, args []string) (int, error) {
+	alg, err := getHashAlg(cmd)
+	if err != nil {
+		return ExitStatus_Fatal, err
+	}
+	out, err := newRecordWriter(cmd)
+	if err != nil {
+		return ExitStatus_Fatal, err
+	}
+
+	store, summary, err := loadDupesHashData(args, alg)
+	if err != nil {
+		return ExitStatus_Fatal, err
+	}
+
+	groups := core.FindDuplicateGroups(store)
+	err = printDuplicateGroups(groups, out)
+	if out != nil {
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		return ExitStatus_Fatal, err
+	}
+
+	if summary != nil {
+		summary.Write(os.Stderr) // nolint:errcheck
+		showSummaryErrors(summary)
+		if err := summary.Err(); err != nil {
+			return exitStatusOf(err), err
+		}
+	}
+	return ExitStatus_OK, nil
+}
+
+// showSummaryErrors shows errors recorded to the summary as warnings.
+func showSummaryErrors(summary *core.RunSummary) {
+	for _, e := range summary.Errors() {
+		ShowWarn("Failed to %s : %s (reason : %s)", e.Op, e.Path, e.Error)
+	}
+}
+
+// loadDupesHashData loads hash values of given sources.
+// Directories are scanned all together by AppendDuplicateCandidates,
+// so that only files which may have duplicates are hashed.
+// The returned summary is nil if no directory is given.
+func loadDupesHashData(srcPaths []string, alg *core.HashAlg) (*core.HashStore, *core.RunSummary, error) {
+	dirPaths := make([]string, 0)
+	otherPaths := make([]string, 0)
+	for _, p := range srcPaths {
+		isDir, err := IsDirectory(p)
+		if err != nil {
+			return nil, nil, err
+		}
+		if isDir {
+			dirPaths = append(dirPaths, p)
+		} else {
+			otherPaths = append(otherPaths, p)
+		}
+	}
+
+	store, err := loadHashData(otherPaths, alg)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(dirPaths) == 0 {
+		return store, nil, nil
+	}
+
+	summary := core.NewRunSummary()
+	if err := store.AppendDuplicateCandidates(dirPaths, alg, summary); err != nil {
+		return nil, nil, err
+	}
+	return store, summary, nil
+}
+
+func printDuplicateGroups(groups []*core.DuplicateGroup, out *core.RecordWriter) error {
+	var duplicates int
+	var wasted int64
+	for _, g := range groups {
+		duplicates += g.NumOfDuplicates()
+		wasted += g.WastedBytes()
+
+		if out != nil {
+			if err := out.Write(core.NewDuplicateGroupRecord(g)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		first := g.Files[0]
+		line := fmt.Sprintf("%d\t%d\t%d\t%s:%s", g.WastedBytes(), g.Size, len(g.Files), first.Alg.AlgName, first.String())
+		for _, f := range g.Files {
+			line += "\t" + f.Path
+		}
+		for _, h := range g.HardLinks {
+			line += "\t" + hardLinkPrefix + h.Path
+		}
+		fmt.Println(line)
+	}
+
+	fmt.Fprintf(os.Stderr, "%d groups, %d duplicated files, %s wasted\n", len(groups), duplicates, FormatSize(wasted))
+	return nil
+}
@@ -17,6 +17,8 @@ package cmd
 
 import (
 	"fmt"
+	"os"
+	"time"
 
 	. "github.com/little-forest/hasher/common" // nolint:staticcheck
 	"github.com/little-forest/hasher/core"
@@ -29,6 +31,12 @@ const Flag_Duplication_ShowExistsOnly = "exists-only"
 const Flag_Duplication_ShowMissingOnly = "missing-only"
 const Flag_Duplication_PrintSourcePathOnly = "print-source-path-only"
 const Flag_Duplication_PrintZero = "print0"
+const Flag_Duplication_MoveTo = "move-to"
+const Flag_Duplication_DeleteExisting = "delete-existing"
+const Flag_Duplication_Log = "log"
+
+// Prefix of hard links of the source file in the result
+const hardLinkPrefix = "hardlink:"
 
 const (
 	SHOW_ALWAYS = iota + 1
@@ -38,11 +46,15 @@ const (
 
 type checkDuplicationOption struct {
 	HashAlg             *core.HashAlg
+	Out                 *core.RecordWriter
+	MoveTo              string
+	LogPath             string
 	Source              []string
 	Target              []string
 	ShowMode            int
 	PrintSourcePathOnly bool
 	PrintZero           bool
+	DeleteExisting      bool
 }
 
 // checkDuplicationCmd represents the compare command
@@ -56,8 +68,26 @@ var checkDuplicationCmd = &cobra.Command{
   (2) Find each file in SOURCE_DIRs exists in TARGET_DIR
         hasher duplicate -t TARGET_DIR SOURCE_DIRs
 
-  Instead of directories, you can also specify a TSV file output by the list-hash sub-command.
+  Instead of directories, you can also specify a TSV file output by the list-hash sub-command,
+  or a catalog database file.
   Cannot use -s and -t options at the same time.
+
+  Each line shows the source file, the number of duplicated files and their paths.
+  Hard links of the source file are already deduplicated, so they are not counted
+  and shown with "hardlink:" prefix.
+
+  (3) Delete each file in SOURCE_DIR already backed up in TARGET_DIR
+        hasher duplicate -s SOURCE_DIR --delete-existing TARGET_DIR
+
+  (4) Move them into TRASH_DIR instead of deleting them, and log what has been done
+        hasher duplicate -s SOURCE_DIR --move-to TRASH_DIR --log LOG_FILE TARGET_DIR
+
+  With --delete-existing or --move-to option, hash values of the source file and
+  its duplicates are calculated again without trusting stored attributes, and the source
+  file is removed only when one of them is confirmed to be identical.
+  Each line shows the action, the source file, the confirmed backup and,
+  when moved, the path in the trash directory.
+  The source file is kept under TRASH_DIR with its absolute path.
 `,
 	RunE: statusWrapper.RunE(runCheckDuplicated),
 	Args: func(cmd *cobra.Command, args []string) error {
@@ -73,6 +103,21 @@ var checkDuplicationCmd = &cobra.Command{
 		if source != "" && target != "" {
 			return fmt.Errorf("can't specify both -s and -t option")
 		}
+
+		moveTo, _ := cmd.Flags().GetString(Flag_Duplication_MoveTo)
+		deleteExisting, _ := cmd.Flags().GetBool(Flag_Duplication_DeleteExisting)
+		if moveTo != "" && deleteExisting {
+			return fmt.Errorf("can't specify both --%s and --%s option", Flag_Duplication_MoveTo, Flag_Duplication_DeleteExisting)
+		}
+		if moveTo != "" || deleteExisting {
+			printSourcePathOnly, _ := cmd.Flags().GetBool(Flag_Duplication_PrintSourcePathOnly)
+			printZero, _ := cmd.Flags().GetBool(Flag_Duplication_PrintZero)
+			if showMissingOnly || printSourcePathOnly || printZero {
+				return fmt.Errorf("can't specify -m, -f or -0 option with --%s or --%s", Flag_Duplication_MoveTo, Flag_Duplication_DeleteExisting)
+			}
+		} else if log, _ := cmd.Flags().GetString(Flag_Duplication_Log); log != "" {
+			return fmt.Errorf("--%s option requires --%s or --%s", Flag_Duplication_Log, Flag_Duplication_MoveTo, Flag_Duplication_DeleteExisting)
+		}
 		return nil
 	},
 }
@@ -86,9 +131,12 @@ func init() {
 	checkDuplicationCmd.Flags().BoolP(Flag_Duplication_ShowMissingOnly, "m", false, "show missing files only")
 	checkDuplicationCmd.Flags().BoolP(Flag_Duplication_PrintSourcePathOnly, "f", false, "print only source file path")
 	checkDuplicationCmd.Flags().BoolP(Flag_Duplication_PrintZero, "0", false, "separate by null character")
+	checkDuplicationCmd.Flags().String(Flag_Duplication_MoveTo, "", "move source files backed up in the target into the trash directory")
+	checkDuplicationCmd.Flags().Bool(Flag_Duplication_DeleteExisting, false, "delete source files backed up in the target")
+	checkDuplicationCmd.Flags().String(Flag_Duplication_Log, "", "append moved or deleted files to the log file")
 }
 
-func newCkeckDuplicationOption(cmd *cobra.Command, args []string) checkDuplicationOption {
+func newCkeckDuplicationOption(cmd *cobra.Command, args []string) (checkDuplicationOption, error) {
 	showExistsOnly, _ := cmd.Flags().GetBool(Flag_Duplication_ShowExistsOnly)
 	showMissingOnly, _ := cmd.Flags().GetBool(Flag_Duplication_ShowMissingOnly)
 
@@ -102,12 +150,31 @@ func newCkeckDuplicationOption(cmd *cobra.Command, args []string) checkDuplicati
 	printSourcePathOnly, _ := cmd.Flags().GetBool(Flag_Duplication_PrintSourcePathOnly)
 	printZero, _ := cmd.Flags().GetBool(Flag_Duplication_PrintZero)
 
+	alg, err := getHashAlg(cmd)
+	if err != nil {
+		return checkDuplicationOption{}, err
+	}
+	out, err := newRecordWriter(cmd)
+	if err != nil {
+		return checkDuplicationOption{}, err
+	}
+	if out != nil && (printSourcePathOnly || printZero) {
+		return checkDuplicationOption{}, fmt.Errorf("can't specify -f or -0 option with --%s", Flag_root_Output)
+	}
+
 	opt := checkDuplicationOption{
-		HashAlg:             core.NewDefaultHashAlg(),
+		HashAlg:             alg,
+		Out:                 out,
 		PrintSourcePathOnly: printSourcePathOnly,
 		PrintZero:           printZero,
 		ShowMode:            showMode,
 	}
+	opt.MoveTo, _ = cmd.Flags().GetString(Flag_Duplication_MoveTo)
+	opt.DeleteExisting, _ = cmd.Flags().GetBool(Flag_Duplication_DeleteExisting)
+	opt.LogPath, _ = cmd.Flags().GetString(Flag_Duplication_Log)
+	if out != nil && (opt.MoveTo != "" || opt.DeleteExisting) {
+		return checkDuplicationOption{}, fmt.Errorf("can't specify --%s or --%s option with --%s", Flag_Duplication_MoveTo, Flag_Duplication_DeleteExisting, Flag_root_Output)
+	}
 
 	// set target and source
 	optSource, _ := cmd.Flags().GetString(Flag_Duplication_Source)
@@ -125,25 +192,37 @@ func newCkeckDuplicationOption(cmd *cobra.Command, args []string) checkDuplicati
 		copy(opt.Source, args)
 	}
 
-	return opt
+	return opt, nil
 }
 
 func runCheckDuplicated(cmd *cobra.Command, args []string) (int, error) {
-	opt := newCkeckDuplicationOption(cmd, args)
+	opt, err := newCkeckDuplicationOption(cmd, args)
+	if err != nil {
+		return ExitStatus_Fatal, err
+	}
 
 	// make source hash store
 	srcHashData, err := loadHashData(opt.Source, opt.HashAlg)
 	if err != nil {
-		return 1, err
+		return ExitStatus_Fatal, err
 	}
 
 	// make target hash store
 	targetHashData, err := loadHashData(opt.Target, opt.HashAlg)
 	if err != nil {
-		return 1, err
+		return ExitStatus_Fatal, err
+	}
+
+	if opt.MoveTo != "" || opt.DeleteExisting {
+		return removeBackedUpFiles(srcHashData, targetHashData, opt)
 	}
 
 	result, err := doCheckDuplication(srcHashData, targetHashData, opt)
+	if opt.Out != nil {
+		if closeErr := opt.Out.Close(); err == nil {
+			err = closeErr
+		}
+	}
 	return result, err
 }
 
@@ -160,6 +239,11 @@ func loadHashData(srcPaths []string, alg *core.HashAlg) (*core.HashStore, error)
 			if err != nil {
 				return nil, err
 			}
+		} else if core.IsCatalogFile(p) {
+			err = appendHashDataFromCatalog(store, p, alg)
+			if err != nil {
+				return nil, err
+			}
 		} else {
 			err = store.LoadHashData(p)
 			if err != nil {
@@ -170,6 +254,16 @@ func loadHashData(srcPaths []string, alg *core.HashAlg) (*core.HashStore, error)
 	return store, nil
 }
 
+func appendHashDataFromCatalog(store *core.HashStore, catalogPath string, alg *core.HashAlg) error {
+	c, closeCatalog, err := openCatalog(catalogPath, false)
+	if err != nil {
+		return err
+	}
+	defer closeCatalog()
+
+	return store.AppendHashDataFromCatalog(c, alg)
+}
+
 func doCheckDuplication(src *core.HashStore, target *core.HashStore, opt checkDuplicationOption) (int, error) {
 	sep := "\n"
 	if opt.PrintZero {
@@ -179,31 +273,122 @@ func doCheckDuplication(src *core.HashStore, target *core.HashStore, opt checkDu
 	for _, hash := range src.Values() {
 		sames := target.Get(hash.String())
 		hasSame := len(sames) > 0
+		// hard links of the source are not duplicates
+		duplicates, hardLinks := core.SplitHardLinks(hash, sames)
 
 		// Src Target | no-opt missing-only existing-only
 		// -----------+-----------------------------------
 		//  o    o    |   o         -            o
 		//  o    -    |   o         o            -
 		//  -    o    |  N/A       N/A          N/A
-		if hasSame && opt.ShowMode != SHOW_MISSING_ONLY {
-			fmt.Print(makeResult(hash, sames, opt.PrintSourcePathOnly))
-			fmt.Print(sep)
-		} else if !hasSame && opt.ShowMode != SHOW_EXISTS_ONLY {
-			fmt.Print(makeResult(hash, sames, opt.PrintSourcePathOnly))
-			fmt.Print(sep)
+		if (hasSame && opt.ShowMode == SHOW_MISSING_ONLY) || (!hasSame && opt.ShowMode == SHOW_EXISTS_ONLY) {
+			continue
 		}
+		if opt.Out != nil {
+			if err := opt.Out.Write(core.NewDuplicateRecord(hash, duplicates, hardLinks)); err != nil {
+				return ExitStatus_Fatal, err
+			}
+			continue
+		}
+		fmt.Print(makeResult(hash, duplicates, hardLinks, opt.PrintSourcePathOnly))
+		fmt.Print(sep)
 	}
-	return 0, nil
+	return ExitStatus_OK, nil
 }
 
-func makeResult(hash *core.Hash, sames []*core.Hash, printSourcePathOnly bool) string {
+func makeResult(hash *core.Hash, duplicates []*core.Hash, hardLinks []*core.Hash, printSourcePathOnly bool) string {
 	if printSourcePathOnly {
 		return hash.Path
 	} else {
-		result := fmt.Sprintf("%s\t%d", hash.Path, len(sames))
-		for _, s := range sames {
+		result := fmt.Sprintf("%s\t%d", hash.Path, len(duplicates))
+		for _, s := range duplicates {
 			result += "\t" + s.Path
 		}
+		for _, h := range hardLinks {
+			result += "\t" + hardLinkPrefix + h.Path
+		}
 		return result
 	}
 }
+
+// removeBackedUpFiles deletes source files, or moves them into the trash directory,
+// only when their backups in the target are confirmed by hash values calculated again.
+func removeBackedUpFiles(src *core.HashStore, target *core.HashStore, opt checkDuplicationOption) (int, error) {
+	var log *os.File
+	if opt.LogPath != "" {
+		var err error
+		if log, err = os.OpenFile(opt.LogPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644); err != nil {
+			return ExitStatus_Fatal, err
+		}
+		// nolint:errcheck
+		defer log.Close()
+	}
+
+	action := "delete"
+	if opt.MoveTo != "" {
+		action = "move"
+	}
+
+	var removed, unconfirmed, failed int
+	var freed int64
+	done := make(map[string]bool)
+	for _, hash := range src.Values() {
+		if done[hash.Path] {
+			continue
+		}
+		done[hash.Path] = true
+
+		// hard links of the source are not backups
+		duplicates, _ := core.SplitHardLinks(hash, target.Get(hash.String()))
+		if len(duplicates) == 0 {
+			continue
+		}
+
+		srcHash, backup, err := core.ConfirmBackup(hash.Path, duplicates, opt.HashAlg)
+		if err != nil {
+			ShowWarn("Failed to confirm backup : %s (reason : %s)", hash.Path, err.Error())
+			failed++
+			continue
+		}
+		if backup == nil {
+			ShowWarn("Backup is not confirmed : %s", hash.Path)
+			unconfirmed++
+			continue
+		}
+
+		result := fmt.Sprintf("%s\t%s\t%s", action, hash.Path, backup.Path)
+		if opt.MoveTo != "" {
+			var trashPath string
+			trashPath, err = core.MoveToTrash(opt.MoveTo, hash.Path)
+			result += "\t" + trashPath
+		} else {
+			err = os.Remove(hash.Path)
+		}
+		if err != nil {
+			ShowWarn("Failed to %s : %s (reason : %s)", action, hash.Path, err.Error())
+			failed++
+			continue
+		}
+
+		fmt.Println(result)
+		removed++
+		freed += srcHash.Size
+		if log != nil {
+			line := fmt.Sprintf("%s\t%s\t%s:%s\n", time.Now().Format(time.RFC3339), result, srcHash.Alg.AlgName, srcHash.String())
+			if _, err := log.WriteString(line); err != nil {
+				return ExitStatus_Fatal, err
+			}
+		}
+	}
+
+	verb := "deleted"
+	if opt.MoveTo != "" {
+		verb = "moved"
+	}
+	fmt.Fprintf(os.Stderr, "%d files %s (%s), %d not confirmed, %d failed\n", removed, verb, FormatSize(freed), unconfirmed, failed)
+	if failed > 0 {
+		err := &core.PartialFailureError{NumOfErrors: failed}
+		return exitStatusOf(err), err
+	}
+	return ExitStatus_OK, nil
+}
@@ -0,0 +1,84 @@
+/*
+Copyright © 2022 Yusuke KOMORI
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	. "github.com/little-forest/hasher/common" // nolint:staticcheck
+	"github.com/little-forest/hasher/core"
+	"github.com/spf13/cobra"
+)
+
+const Flag_Export_Out = "out"
+
+// exportCmd represents the export command
+var exportCmd = &cobra.Command{
+	Use:   "export [-o MANIFEST_FILE] DIR",
+	Args:  cobra.ExactArgs(1),
+	Short: "Export stored hash values to a manifest file",
+	Long: `Exports stored hash values of all files under the directory to a manifest file.
+Paths in the manifest are relative to the directory, so that hash values can be imported
+to a copy of the tree made by tools which drop extended attributes.
+
+Files modified after the hash was calculated are skipped.
+`,
+	Example: `
+  (1) Export hash values and import them to a copy
+        hasher export -o MANIFEST_FILE SRC_DIR
+        hasher import MANIFEST_FILE DST_DIR
+`,
+	RunE: statusWrapper.RunE(runExport),
+}
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+
+	exportCmd.Flags().StringP(Flag_Export_Out, "o", "", "output file path")
+}
+
+func runExport(cmd *cobra.Command, args []string) (int, error) {
+	verbose, _ := cmd.Flags().GetBool(Flag_root_Verbose)
+	out, _ := cmd.Flags().GetString(Flag_Export_Out)
+
+	if err := EnsureDirectory(args[0]); err != nil {
+		return ExitStatus_Fatal, err
+	}
+
+	var writer io.Writer
+	if out != "" {
+		f, err := os.Create(out)
+		if err != nil {
+			return ExitStatus_Fatal, err
+		}
+		// nolint:errcheck
+		defer f.Close()
+		writer = f
+	} else {
+		writer = os.Stdout
+	}
+
+	count, err := core.ExportManifest(args[0], writer)
+	if err != nil {
+		return ExitStatus_Fatal, err
+	}
+	if verbose {
+		fmt.Fprintf(os.Stderr, "Exported %d files\n", count)
+	}
+	return ExitStatus_OK, nil
+}
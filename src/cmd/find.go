@@ -42,6 +42,9 @@ var findCmd = &cobra.Command{
 
   (3) Find files that have same hash value as given SRCFILE from directories
         hasher find -f SRCFILE DIR...
+
+  (4) Find files that have same hash value as given SRCFILE from the catalog
+        hasher --catalog CATALOG_FILE find -f SRCFILE
 `,
 	RunE: statusWrapper.RunE(runFind),
 }
@@ -60,33 +63,61 @@ func runFind(cmd *cobra.Command, args []string) (int, error) {
 	findHasHash, _ := cmd.Flags().GetBool(Flag_Find_HasHash)
 	srcFile, _ := cmd.Flags().GetString(Flag_Find_File)
 
-	alg := core.NewDefaultHashAlg()
+	alg, err := getHashAlg(cmd)
+	if err != nil {
+		return ExitStatus_Fatal, err
+	}
+	out, err := newRecordWriter(cmd)
+	if err != nil {
+		return ExitStatus_Fatal, err
+	}
+	if out != nil {
+		// nolint:errcheck
+		defer out.Close()
+	}
+
 	if findNoHash {
-		w := &findNoHashWalker{Alg: alg}
+		w := &findNoHashWalker{Alg: alg, Out: out}
 		if err := WalkDirsWithWalker(args, w); err != nil {
-			return 1, err
+			return ExitStatus_Fatal, err
 		} else {
-			return 0, nil
+			return ExitStatus_OK, nil
 		}
 	} else if findHasHash {
-		w := &findHasHashWalker{Alg: alg}
+		w := &findHasHashWalker{Alg: alg, Out: out}
 		if err := WalkDirsWithWalker(args, w); err != nil {
-			return 1, err
+			return ExitStatus_Fatal, err
+		} else {
+			return ExitStatus_OK, nil
+		}
+	} else if srcFile != "" && len(args) == 0 && core.GetCatalogPath() != "" {
+		if err := findSameHashFileFromCatalog(alg, srcFile, core.GetCatalogPath(), out); err != nil {
+			return ExitStatus_Fatal, err
 		} else {
-			return 0, nil
+			return ExitStatus_OK, nil
 		}
 	} else if srcFile != "" {
-		if err := findSameHashFile(alg, srcFile, args); err != nil {
-			return 1, err
+		if err := findSameHashFile(alg, srcFile, args, out); err != nil {
+			return ExitStatus_Fatal, err
 		} else {
-			return 0, nil
+			return ExitStatus_OK, nil
 		}
 	}
-	return 1, fmt.Errorf("invalid argument")
+	return ExitStatus_Fatal, fmt.Errorf("invalid argument")
+}
+
+// printHash prints the hash value as a TSV line or a JSON record.
+func printHash(out *core.RecordWriter, hash *core.Hash) error {
+	if out != nil {
+		return out.Write(core.NewHashRecord(hash.Path, []*core.Hash{hash}))
+	}
+	fmt.Println(hash.Tsv())
+	return nil
 }
 
 type findNoHashWalker struct {
 	Alg *core.HashAlg
+	Out *core.RecordWriter
 }
 
 func (w findNoHashWalker) Deal(f *os.File) error {
@@ -94,14 +125,23 @@ func (w findNoHashWalker) Deal(f *os.File) error {
 	if err != nil {
 		return err
 	}
-	if hash == nil {
-		fmt.Printf("%s\n", f.Name())
+	if hash != nil {
+		return nil
 	}
+	if w.Out != nil {
+		r, err := newFileRecord(f)
+		if err != nil {
+			return err
+		}
+		return w.Out.Write(r)
+	}
+	fmt.Printf("%s\n", f.Name())
 	return nil
 }
 
 type findHasHashWalker struct {
 	Alg *core.HashAlg
+	Out *core.RecordWriter
 }
 
 func (w findHasHashWalker) Deal(f *os.File) error {
@@ -110,7 +150,7 @@ func (w findHasHashWalker) Deal(f *os.File) error {
 		return err
 	}
 	if hash != nil {
-		fmt.Println(hash.Tsv())
+		return printHash(w.Out, hash)
 	}
 	return nil
 }
@@ -118,6 +158,7 @@ func (w findHasHashWalker) Deal(f *os.File) error {
 type findSameHashWalker struct {
 	Alg    *core.HashAlg
 	Source *core.Hash
+	Out    *core.RecordWriter
 }
 
 func (w findSameHashWalker) Deal(f *os.File) error {
@@ -126,12 +167,12 @@ func (w findSameHashWalker) Deal(f *os.File) error {
 		ShowWarn("failed to update hash : %s", err.Error())
 	}
 	if hash != nil && w.Source.HasSameHashValue(hash) {
-		fmt.Println(hash.Tsv())
+		return printHash(w.Out, hash)
 	}
 	return nil
 }
 
-func findSameHashFile(alg *core.HashAlg, srcPath string, targetDirs []string) error {
+func findSameHashFile(alg *core.HashAlg, srcPath string, targetDirs []string, out *core.RecordWriter) error {
 	if err := EnsureRegularFile(srcPath); err != nil {
 		return err
 	}
@@ -140,6 +181,38 @@ func findSameHashFile(alg *core.HashAlg, srcPath string, targetDirs []string) er
 		return err
 	}
 
-	w := &findSameHashWalker{Alg: alg, Source: srcHash}
+	w := &findSameHashWalker{Alg: alg, Source: srcHash, Out: out}
 	return WalkDirsWithWalker(targetDirs, w)
 }
+
+func findSameHashFileFromCatalog(alg *core.HashAlg, srcPath string, catalogPath string, out *core.RecordWriter) error {
+	if err := EnsureRegularFile(srcPath); err != nil {
+		return err
+	}
+	// the catalog is only queried, so that it is opened read-only
+	// and the hash value of the source is not recorded to it
+	core.SetCatalogPath("")
+	catalog, closeCatalog, err := openCatalog(catalogPath, false)
+	if err != nil {
+		return err
+	}
+	defer closeCatalog()
+
+	_, srcHash, err := core.UpdateHash(srcPath, alg, false)
+	if err != nil {
+		return err
+	}
+
+	entries, err := catalog.FindByHash(srcHash)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if h := e.Hash(alg); h != nil {
+			if err := printHash(out, h); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
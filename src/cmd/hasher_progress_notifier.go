@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"path/filepath"
@@ -51,8 +52,28 @@ type errorEvent struct {
 	commonEvent
 }
 
+// Interval to refresh the display while files are being read
+const progressRefreshInterval = 500 * time.Millisecond
+
+// byteProgress holds bytes processed by workers.
+// It is updated by workers directly instead of events, because bytes are notified very often.
+type byteProgress struct {
+	// bytes of the current file read by each worker
+	workerRead []atomic.Int64
+	// bytes read by all workers
+	read atomic.Int64
+	// bytes processed without reading
+	skipped atomic.Int64
+}
+
 type HasherProgressNotifier struct {
-	notifyQueue  chan progressNotfierEvent
+	// time when the current task of each worker started
+	taskStarted []time.Time
+	startTime   time.Time
+	notifyQueue chan progressNotfierEvent
+	bytes       *byteProgress
+	// estimates the total until it is known
+	counter      *FileCounter
 	messages     []string
 	NumOfWorkers int
 	done         int
@@ -67,6 +88,8 @@ func NewHasherProgressNotifier(numOfWorkers int, verbose bool) *HasherProgressNo
 		Verbose:      verbose,
 		total:        -1,
 		messages:     make([]string, numOfWorkers),
+		taskStarted:  make([]time.Time, numOfWorkers),
+		bytes:        &byteProgress{workerRead: make([]atomic.Int64, numOfWorkers)},
 		notifyQueue:  make(chan progressNotfierEvent),
 		isClosed:     false,
 	}
@@ -78,11 +101,16 @@ func (n *HasherProgressNotifier) SetTotal(total int) {
 	}
 }
 
+func (n *HasherProgressNotifier) SetFileCounter(counter *FileCounter) {
+	n.counter = counter
+}
+
 func (n HasherProgressNotifier) IsVerbose() bool {
 	return n.Verbose
 }
 
 func (n *HasherProgressNotifier) Start() {
+	n.startTime = time.Now()
 	go n.doStart()
 }
 
@@ -95,7 +123,8 @@ func (n *HasherProgressNotifier) Shutdown() {
 	}
 }
 
-func (n HasherProgressNotifier) NotifyTaskStart(workerId int, taskName string) {
+func (n *HasherProgressNotifier) NotifyTaskStart(workerId int, taskName string) {
+	n.bytes.workerRead[workerId].Store(0)
 	e := startEvent{
 		commonEvent: commonEvent{
 			workerId: workerId,
@@ -105,7 +134,7 @@ func (n HasherProgressNotifier) NotifyTaskStart(workerId int, taskName string) {
 	n.notifyQueue <- e
 }
 
-func (n HasherProgressNotifier) NotifyTaskDone(workerId int, message string) {
+func (n *HasherProgressNotifier) NotifyTaskDone(workerId int, message string) {
 	e := doneEvent{
 		commonEvent: commonEvent{
 			workerId: workerId,
@@ -115,7 +144,7 @@ func (n HasherProgressNotifier) NotifyTaskDone(workerId int, message string) {
 	n.notifyQueue <- e
 }
 
-func (n HasherProgressNotifier) NotifyProgress(done int, total int) {
+func (n *HasherProgressNotifier) NotifyProgress(done int, total int) {
 	e := progressEvent{
 		Done:  done,
 		Total: total,
@@ -123,7 +152,16 @@ func (n HasherProgressNotifier) NotifyProgress(done int, total int) {
 	n.notifyQueue <- e
 }
 
-func (n HasherProgressNotifier) NotifyWarning(workerId int, message string) {
+func (n *HasherProgressNotifier) NotifyBytes(workerId int, bytes int64, read bool) {
+	if read {
+		n.bytes.workerRead[workerId].Add(bytes)
+		n.bytes.read.Add(bytes)
+	} else {
+		n.bytes.skipped.Add(bytes)
+	}
+}
+
+func (n *HasherProgressNotifier) NotifyWarning(workerId int, message string) {
 	e := warningEvent{
 		commonEvent: commonEvent{
 			workerId: workerId,
@@ -133,7 +171,7 @@ func (n HasherProgressNotifier) NotifyWarning(workerId int, message string) {
 	n.notifyQueue <- e
 }
 
-func (n HasherProgressNotifier) NotifyError(workerId int, message string) {
+func (n *HasherProgressNotifier) NotifyError(workerId int, message string) {
 	e := errorEvent{
 		commonEvent: commonEvent{
 			workerId: workerId,
@@ -168,18 +206,31 @@ func (n *HasherProgressNotifier) updatePrograss(done int, total int) {
 func (n *HasherProgressNotifier) doStart() {
 	n.prepare()
 
-	for event := range n.notifyQueue {
-		switch e := event.(type) {
-		case startEvent:
-			n.showStart(e.workerId, e.TaskName)
-		case doneEvent:
-			n.showDone(e.workerId, e.Message)
-		case progressEvent:
-			n.showProgress(e.Done, e.Total)
-		case errorEvent:
-			n.showError(e.Message)
-		default:
-			n.showError(fmt.Sprintf("<ProgressNotifier> Unknown event : %T : %v", e, e))
+	// refresh bytes of files being read
+	ticker := time.NewTicker(progressRefreshInterval)
+	defer ticker.Stop()
+
+loop:
+	for {
+		select {
+		case event, ok := <-n.notifyQueue:
+			if !ok {
+				break loop
+			}
+			switch e := event.(type) {
+			case startEvent:
+				n.showStart(e.workerId, e.TaskName)
+			case doneEvent:
+				n.showDone(e.workerId, e.Message)
+			case progressEvent:
+				n.showProgress(e.Done, e.Total)
+			case errorEvent:
+				n.showError(e.Message)
+			default:
+				n.showError(fmt.Sprintf("<ProgressNotifier> Unknown event : %T : %v", e, e))
+			}
+		case <-ticker.C:
+			n.refresh()
 		}
 	}
 
@@ -190,7 +241,7 @@ func (n *HasherProgressNotifier) doStart() {
 func (n *HasherProgressNotifier) showTaskMessage(workerId int) {
 	fmt.Print(aec.Down(uint(workerId)))
 	fmt.Print("\x1b[0K") // delete line after cursor
-	fmt.Printf("[Worker-%d] : %s", workerId, n.messages[workerId])
+	fmt.Printf("[Worker-%d] : %s%s", workerId, n.messages[workerId], n.workerBytesText(workerId))
 	if workerId > 0 {
 		fmt.Print(aec.PreviousLine(uint(workerId)))
 	} else {
@@ -204,6 +255,7 @@ func (n *HasherProgressNotifier) showStart(workerId int, path string) {
 	}
 
 	n.messages[workerId] = n.chopPath(path)
+	n.taskStarted[workerId] = time.Now()
 
 	n.showTaskMessage(workerId)
 }
@@ -214,10 +266,35 @@ func (n *HasherProgressNotifier) showDone(workerId int, message string) {
 	}
 
 	n.messages[workerId] = n.messages[workerId] + " " + message
+	n.taskStarted[workerId] = time.Time{}
 
 	n.showTaskMessage(workerId)
 }
 
+// refresh shows bytes read by running workers and the progress.
+func (n *HasherProgressNotifier) refresh() {
+	if !n.Verbose {
+		return
+	}
+	for id, started := range n.taskStarted {
+		if !started.IsZero() {
+			n.showTaskMessage(id)
+		}
+	}
+	n.showProgress(-1, -1)
+}
+
+// workerBytesText returns bytes read by the worker and its throughput,
+// while the worker is reading a file.
+func (n *HasherProgressNotifier) workerBytesText(workerId int) string {
+	started := n.taskStarted[workerId]
+	read := n.bytes.workerRead[workerId].Load()
+	if started.IsZero() || read == 0 {
+		return ""
+	}
+	return fmt.Sprintf("  %s %s/s", FormatSize(read), FormatSize(bytesPerSecond(read, time.Since(started))))
+}
+
 func (n HasherProgressNotifier) showWarning(msg string) {
 	if n.Verbose {
 		// Insert one line to bottom
@@ -252,10 +329,67 @@ func (n *HasherProgressNotifier) showProgress(done int, total int) {
 	}
 
 	fmt.Print(aec.NextLine(uint(n.NumOfWorkers)))
-	fmt.Printf("%d / %d", n.done, n.total)
+	fmt.Print("\x1b[0K") // delete line after cursor
+	fmt.Print(n.progressText())
+	fmt.Print(n.bytesText())
 	fmt.Print(aec.PreviousLine(uint(n.NumOfWorkers)))
 }
 
+// bytesText returns processed bytes, the throughput of all workers and ETA.
+// The percentage and ETA are shown only when the total bytes are estimated by the file counter.
+// Nothing is shown until any bytes are processed.
+//
+//	e.g. "  12.3GB / ~50.0GB (24%)  150.2MB/s  ETA 4m12s"
+func (n *HasherProgressNotifier) bytesText() string {
+	read := n.bytes.read.Load()
+	processed := read + n.bytes.skipped.Load()
+	elapsed := time.Since(n.startTime)
+	if processed == 0 {
+		return ""
+	}
+
+	if n.counter == nil {
+		return fmt.Sprintf("  %s  %s/s", FormatSize(processed), FormatSize(bytesPerSecond(read, elapsed)))
+	}
+
+	total := n.counter.Bytes()
+	if total < processed {
+		total = processed
+	}
+	percent := 100
+	if total > 0 {
+		percent = int(processed * 100 / total)
+	}
+	eta := time.Duration(float64(elapsed) * float64(total-processed) / float64(processed))
+	return fmt.Sprintf("  %s / ~%s (%d%%)  %s/s  ETA %s",
+		FormatSize(processed), FormatSize(total), percent, FormatSize(bytesPerSecond(read, elapsed)), eta.Round(time.Second))
+}
+
+// bytesPerSecond returns the throughput of reading bytes in the duration.
+func bytesPerSecond(bytes int64, d time.Duration) int64 {
+	if d <= 0 {
+		return 0
+	}
+	return int64(float64(bytes) / d.Seconds())
+}
+
+// progressText returns the progress as "DONE / TOTAL".
+// While the total is unknown, the estimate by the file counter is shown as "~TOTAL".
+func (n *HasherProgressNotifier) progressText() string {
+	if n.total >= 0 || n.counter == nil {
+		return fmt.Sprintf("%d / %d", n.done, n.total)
+	}
+	// the estimate is never less than files already done
+	estimate := n.counter.Count()
+	if estimate < n.done {
+		estimate = n.done
+	}
+	if n.counter.IsDone() {
+		return fmt.Sprintf("%d / ~%d", n.done, estimate)
+	}
+	return fmt.Sprintf("%d / ~%d (still counting)", n.done, estimate)
+}
+
 func (n HasherProgressNotifier) tearDown() {
 	if !n.Verbose {
 		return
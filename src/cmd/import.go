@@ -0,0 +1,121 @@
+/*
+Copyright © 2022 Yusuke KOMORI
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	. "github.com/little-forest/hasher/common" // nolint:staticcheck
+	"github.com/little-forest/hasher/core"
+	"github.com/spf13/cobra"
+)
+
+const Flag_Import_Verify = "verify"
+const Flag_Import_MtimeWindow = "mtime-window"
+
+var Mark_NotFound = fmt.Sprintf("[%s]", C_gray.Apply("NOT FOUND"))
+
+// importCmd represents the import command
+var importCmd = &cobra.Command{
+	Use:   "import [--verify] [--mtime-window DURATION] MANIFEST_FILE DIR",
+	Args:  cobra.ExactArgs(2),
+	Short: "Import hash values from a manifest file",
+	Long: `Imports hash values exported by the export sub-command to files under the directory.
+Hash values are imported only when size and mtime of the file match the manifest.
+With --verify, hash values are recalculated and imported only when they match.
+
+  [OK]        : hash values are imported
+  [MODIFIED]  : size or mtime differs from the manifest
+  [NOT FOUND] : file doesn't exist
+  [CORRUPTED] : contents don't match the manifest (only with --verify)
+
+Exit status:
+  0 : no error occurred
+  1 : some files could not be imported
+  2 : fatal error
+  3 : corrupted files are found
+`,
+	RunE: statusWrapper.RunE(runImport),
+}
+
+func init() {
+	rootCmd.AddCommand(importCmd)
+
+	importCmd.Flags().Bool(Flag_Import_Verify, false, "recalculate hash values and import them only when they match")
+	importCmd.Flags().String(Flag_Import_MtimeWindow, "1s", "allowed difference of mtime (for filesystems with coarse timestamps)")
+}
+
+func runImport(cmd *cobra.Command, args []string) (int, error) {
+	verbose, _ := cmd.Flags().GetBool(Flag_root_Verbose)
+
+	var opt core.ImportOption
+	opt.Verify, _ = cmd.Flags().GetBool(Flag_Import_Verify)
+	window, _ := cmd.Flags().GetString(Flag_Import_MtimeWindow)
+	var err error
+	if opt.MtimeWindow, err = ParseDuration(window); err != nil {
+		return ExitStatus_Fatal, err
+	}
+
+	if err = EnsureDirectory(args[1]); err != nil {
+		return ExitStatus_Fatal, err
+	}
+
+	entries, err := core.ReadManifest(args[0])
+	if err != nil {
+		return ExitStatus_Fatal, err
+	}
+
+	counts := make(map[core.ImportStatus]int)
+	errors := 0
+	core.ImportManifest(entries, args[1], opt, func(path string, entry *core.ManifestEntry, status core.ImportStatus, err error) {
+		if err != nil {
+			ShowErrorMsg("Failed to import : %s (%s)", path, err.Error())
+			errors++
+			return
+		}
+		counts[status]++
+
+		switch status {
+		case core.IMPORT_OK:
+			if verbose {
+				fmt.Printf("%s %s\n", Mark_OK, path)
+			}
+		case core.IMPORT_MODIFIED:
+			fmt.Printf("%s %s\n", Mark_Modified, path)
+		case core.IMPORT_NOT_FOUND:
+			if verbose {
+				fmt.Printf("%s %s\n", Mark_NotFound, path)
+			}
+		case core.IMPORT_CORRUPTED:
+			fmt.Printf("%s %s\n", Mark_Corrupted, path)
+		}
+	})
+
+	if verbose {
+		fmt.Fprintf(os.Stderr, "OK: %d, MODIFIED: %d, NOT FOUND: %d, CORRUPTED: %d, ERROR: %d\n",
+			counts[core.IMPORT_OK], counts[core.IMPORT_MODIFIED], counts[core.IMPORT_NOT_FOUND],
+			counts[core.IMPORT_CORRUPTED], errors)
+	}
+
+	if counts[core.IMPORT_CORRUPTED] > 0 {
+		return Status_Corrupted, nil
+	}
+	if errors > 0 {
+		return ExitStatus_PartialFailure, nil
+	}
+	return ExitStatus_OK, nil
+}
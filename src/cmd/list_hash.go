@@ -16,8 +16,10 @@ limitations under the License.
 package cmd
 
 import (
+	"fmt"
 	"io"
 	"os"
+	"strings"
 
 	"github.com/little-forest/hasher/core"
 	"github.com/spf13/cobra"
@@ -25,13 +27,35 @@ import (
 
 const Flag_ListHash_Out = "out"
 const Flag_ListHash_UpdateHash = "update-hash"
+const Flag_ListHash_Format = "format"
+const Flag_ListHash_ErrorReport = "error-report"
 
 // listHashCmd represents the listHash command
 var listHashCmd = &cobra.Command{
-	Use:   "list-hash [-u] [-o OUT_FILE] TARGET...",
-	Short: "Output hash list in TSV format",
-	Long:  ``,
-	RunE:  statusWrapper.RunE(runListHash),
+	Use:   "list-hash [-u] [-o OUT_FILE] [--format FORMAT] TARGET...",
+	Short: "Output hash list",
+	Long: `Outputs hash values of given files.
+
+Formats:
+  tsv       : hasher's own TSV format (default)
+  coreutils : sha256sum compatible format, readable by the check sub-command and 'sha256sum -c'
+  bsd       : BSD style format (ALG (PATH) = VALUE)
+
+When the output is written to a file with -o, or some files failed,
+a summary of listed, failed and skipped files is shown to stderr.
+With --error-report, the summary and all errors are also written to the file in JSON.
+
+Exit status:
+  0 : hash values of all files are listed
+  1 : some files could not be listed
+  2 : fatal error
+`,
+	Example: `
+  (1) Write a checksum file and check it later
+        hasher -a sha256 list-hash -u --format coreutils -o FILES.sha256 DIR
+        hasher check FILES.sha256
+`,
+	RunE: statusWrapper.RunE(runListHash),
 }
 
 func init() {
@@ -39,22 +63,48 @@ func init() {
 
 	listHashCmd.Flags().StringP(Flag_ListHash_Out, "o", "", "output file path")
 	listHashCmd.Flags().BoolP(Flag_ListHash_UpdateHash, "u", false, "When the hash is NOT up-to-date. Update it.")
+	listHashCmd.Flags().String(Flag_ListHash_Format, core.HashFormat_Tsv, fmt.Sprintf("output format (%s)", strings.Join(core.HashFormatNames(), ", ")))
+	listHashCmd.Flags().String(Flag_ListHash_ErrorReport, "", "write the summary and errors to the file in JSON")
 }
 
 func runListHash(cmd *cobra.Command, args []string) (int, error) {
 	out, _ := cmd.Flags().GetString(Flag_ListHash_Out)
 	updateHash, _ := cmd.Flags().GetBool(Flag_ListHash_UpdateHash)
-	alg := core.NewDefaultHashAlg()
-
-	err := listHashAll(args, alg, out, updateHash)
+	format, _ := cmd.Flags().GetString(Flag_ListHash_Format)
+	reportPath, _ := cmd.Flags().GetString(Flag_ListHash_ErrorReport)
+	algs, err := getHashAlgs(cmd)
 	if err != nil {
-		return 1, err
-	} else {
-		return 0, nil
+		return ExitStatus_Fatal, err
+	}
+	output, err := getOutputFormat(cmd)
+	if err != nil {
+		return ExitStatus_Fatal, err
+	}
+	if output != core.OutputFormat_Tsv {
+		if format != core.HashFormat_Tsv {
+			return ExitStatus_Fatal, fmt.Errorf("can't specify both --%s and --%s", Flag_ListHash_Format, Flag_root_Output)
+		}
+		format = output
+	}
+	if err = core.CheckHashFormat(format, algs); err != nil {
+		return ExitStatus_Fatal, err
+	}
+
+	summary := core.NewRunSummary()
+	err = listHashAll(args, algs, format, out, updateHash, summary)
+	if exitStatusOf(err) == ExitStatus_Fatal {
+		return ExitStatus_Fatal, err
+	}
+
+	if out != "" || err != nil || reportPath != "" {
+		if reportErr := finishRunSummary(summary, reportPath); reportErr != nil {
+			return ExitStatus_Fatal, reportErr
+		}
 	}
+	return exitStatusOf(err), err
 }
 
-func listHashAll(paths []string, alg *core.HashAlg, outPath string, updateHash bool) error {
+func listHashAll(paths []string, algs []*core.HashAlg, format string, outPath string, updateHash bool, summary *core.RunSummary) error {
 	verbose := false
 
 	var writer io.Writer
@@ -83,6 +133,6 @@ func listHashAll(paths []string, alg *core.HashAlg, outPath string, updateHash b
 		notifier = NewStdioProgressNotifier()
 	}
 
-	err := core.ListHash2(paths, core.NewDefaultHashAlg(), writer, notifier, verbose, updateHash)
+	err := core.ListHash2(paths, algs, format, writer, summary, notifier, verbose, updateHash)
 	return err
 }
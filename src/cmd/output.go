@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/little-forest/hasher/core"
+	"github.com/spf13/cobra"
+)
+
+// getOutputFormat returns the output format specified by --output option.
+func getOutputFormat(cmd *cobra.Command) (string, error) {
+	format, _ := cmd.Flags().GetString(Flag_root_Output)
+	for _, f := range core.OutputFormatNames() {
+		if format == f {
+			return format, nil
+		}
+	}
+	return "", fmt.Errorf("unknown output format : %s (%s)", format, strings.Join(core.OutputFormatNames(), ", "))
+}
+
+// newRecordWriter returns a RecordWriter to stdout when JSON output is specified.
+// When the output format is tsv, it will return nil.
+func newRecordWriter(cmd *cobra.Command) (*core.RecordWriter, error) {
+	format, err := getOutputFormat(cmd)
+	if err != nil {
+		return nil, err
+	}
+	if format == core.OutputFormat_Tsv {
+		return nil, nil
+	}
+	return core.NewRecordWriter(os.Stdout, format)
+}
+
+// newFileRecord returns HashRecord of the file which has no hash value.
+func newFileRecord(f *os.File) (core.HashRecord, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return core.NewHashRecord(f.Name(), nil), err
+	}
+	return core.NewFileRecord(f.Name(), info), nil
+}
+
+// finishRunSummary shows the summary to stderr,
+// and writes the error report when its path is specified.
+func finishRunSummary(summary *core.RunSummary, reportPath string) error {
+	summary.Write(os.Stderr) // nolint:errcheck
+	if reportPath == "" {
+		return nil
+	}
+
+	f, err := os.Create(reportPath)
+	if err != nil {
+		return fmt.Errorf("failed to write error report : %s", err.Error())
+	}
+	if err := summary.WriteErrorReport(f); err != nil {
+		f.Close() // nolint:errcheck
+		return fmt.Errorf("failed to write error report : %s", err.Error())
+	}
+	return f.Close()
+}
+
+// exitStatusOf returns the exit status of the command which returned given error.
+func exitStatusOf(err error) int {
+	if err == nil {
+		return ExitStatus_OK
+	}
+	var partial *core.PartialFailureError
+	if errors.As(err, &partial) {
+		return ExitStatus_PartialFailure
+	}
+	return ExitStatus_Fatal
+}
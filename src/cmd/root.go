@@ -16,13 +16,29 @@ limitations under the License.
 package cmd
 
 import (
+	"fmt"
 	"os"
+	"strings"
 
+	. "github.com/little-forest/hasher/common" // nolint:staticcheck
+	"github.com/little-forest/hasher/core"
 	"github.com/spf13/cobra"
 )
 
 const Flag_root_Verbose = "verbose"
 const Flag_root_Recursive = "recursive"
+const Flag_root_Algorithm = "algorithm"
+const Flag_root_Store = "store"
+const Flag_root_Catalog = "catalog"
+const Flag_root_Output = "output"
+const Flag_root_Exclude = "exclude"
+const Flag_root_Include = "include"
+const Flag_root_MinSize = "min-size"
+const Flag_root_MaxSize = "max-size"
+const Flag_root_FollowSymlinks = "follow-symlinks"
+const Flag_root_OneFileSystem = "one-file-system"
+const Flag_root_IncludeFsType = "include-fs-type"
+const Flag_root_ExcludeFsType = "exclude-fs-type"
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
@@ -32,14 +48,44 @@ var rootCmd = &cobra.Command{
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
 	// Run: func(cmd *cobra.Command, args []string) { },
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		c, err := loadConfig(getConfigPath())
+		if err != nil {
+			return err
+		}
+		config = c
+
+		if _, err := getOutputFormat(cmd); err != nil {
+			return err
+		}
+		if err := setupWalkFilter(cmd); err != nil {
+			return err
+		}
+		if err := setupAttrStore(cmd); err != nil {
+			return err
+		}
+		return setupCatalog(cmd)
+	},
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
 	err := rootCmd.Execute()
-	if err != nil {
-		os.Exit(1)
+	showSkippedMountPoints()
+	if flushErr := core.FlushAttrStore(); flushErr != nil {
+		ShowErrorMsg("Failed to save attributes : %s", flushErr.Error())
+		os.Exit(ExitStatus_Fatal)
+	}
+	if c := core.GetCatalog(); c != nil {
+		if closeErr := c.Close(); closeErr != nil {
+			ShowErrorMsg("Failed to save catalog : %s", closeErr.Error())
+			os.Exit(ExitStatus_Fatal)
+		}
+	}
+	if err != nil && statusWrapper.Status == ExitStatus_OK {
+		// invalid arguments or options
+		os.Exit(ExitStatus_Fatal)
 	}
 	os.Exit(statusWrapper.Status)
 }
@@ -47,4 +93,37 @@ func Execute() {
 func init() {
 	rootCmd.PersistentFlags().BoolP(Flag_root_Verbose, "v", false, "verbose")
 	rootCmd.PersistentFlags().BoolP(Flag_root_Recursive, "r", false, "recursive")
+	rootCmd.PersistentFlags().StringP(Flag_root_Algorithm, "a", "",
+		fmt.Sprintf("hash algorithm (%s). comma separated for multiple. default: %s or config file setting", strings.Join(core.HashAlgNames(), ", "), core.DefaultHashAlgName))
+	rootCmd.PersistentFlags().String(Flag_root_Store, "",
+		fmt.Sprintf("where hash attributes are stored (%s, %s, %s). default: %s or config file setting",
+			core.AttrStore_Xattr, core.AttrStore_Sidecar, core.AttrStore_Auto, core.AttrStore_Auto))
+	rootCmd.PersistentFlags().String(Flag_root_Catalog, "", "catalog database file where hash values are recorded. default: config file setting")
+	rootCmd.PersistentFlags().String(Flag_root_Output, core.OutputFormat_Tsv,
+		fmt.Sprintf("output format of list-hash, find, show, duplicate, dupes, dirdiff and catalog (%s)", strings.Join(core.OutputFormatNames(), ", ")))
+	rootCmd.PersistentFlags().StringArray(Flag_root_Exclude, nil,
+		fmt.Sprintf("skip files and directories matching the gitignore style pattern while walking directories. can be specified multiple times. patterns in %s files are also applied", IgnoreFileName))
+	rootCmd.PersistentFlags().StringArray(Flag_root_Include, nil, "walk only files matching the gitignore style pattern. can be specified multiple times")
+	rootCmd.PersistentFlags().String(Flag_root_MinSize, "", "skip files smaller than this size while walking directories (e.g. 1M)")
+	rootCmd.PersistentFlags().String(Flag_root_MaxSize, "", "skip files larger than this size while walking directories (e.g. 4G)")
+	rootCmd.PersistentFlags().BoolP(Flag_root_FollowSymlinks, "L", false,
+		"follow symbolic links while walking directories. each link is reported with its own path, and the same file is hashed only once")
+	rootCmd.PersistentFlags().BoolP(Flag_root_OneFileSystem, "x", false, "don't descend into directories on other filesystems while walking directories")
+	rootCmd.PersistentFlags().StringSlice(Flag_root_IncludeFsType, nil,
+		"walk only filesystems of these types (e.g. ext4,xfs). comma separated")
+	rootCmd.PersistentFlags().StringSlice(Flag_root_ExcludeFsType, nil,
+		fmt.Sprintf("skip filesystems of these types (e.g. nfs,fuse). comma separated. %s are skipped unless --%s is specified",
+			strings.Join(DefaultExcludedFsTypes, ", "), Flag_root_IncludeFsType))
+}
+
+// showSkippedMountPoints warns of mount points which were not walked.
+func showSkippedMountPoints() {
+	points := SkippedMountPoints()
+	if len(points) == 0 {
+		return
+	}
+	ShowWarn("Skipped %d mount point(s) :", len(points))
+	for _, p := range points {
+		fmt.Fprintf(os.Stderr, "  %s\n", p)
+	}
 }
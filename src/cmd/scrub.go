@@ -0,0 +1,101 @@
+/*
+Copyright © 2022 Yusuke KOMORI
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	. "github.com/little-forest/hasher/common" // nolint:staticcheck
+	"github.com/little-forest/hasher/core"
+	"github.com/spf13/cobra"
+)
+
+const Flag_Scrub_OlderThan = "older-than"
+const Flag_Scrub_MaxBytes = "max-bytes"
+const Flag_Scrub_MaxTime = "max-time"
+
+// scrubCmd represents the scrub command
+var scrubCmd = &cobra.Command{
+	Use:   "scrub [--older-than AGE] [--max-bytes SIZE] [--max-time DURATION] DIR...",
+	Short: "Verify files which have not been checked recently",
+	Long: `Verifies only files whose hash checked time is older than given age,
+in order of oldest checked time. Successfully verified files get their checked time updated,
+so that repeated runs with a budget walk through the whole tree incrementally.
+
+Results are displayed in the same way as the verify sub-command.
+`,
+	Example: `
+  (1) Verify files not checked for 30 days, reading at most 500GiB per run
+        hasher scrub --older-than 30d --max-bytes 500G DIR...
+
+  (2) Verify files not checked for 2 weeks, stopping after 1 hour
+        hasher scrub --older-than 2w --max-time 1h DIR...
+`,
+	RunE: statusWrapper.RunE(runScrub),
+}
+
+func init() {
+	rootCmd.AddCommand(scrubCmd)
+
+	scrubCmd.Flags().String(Flag_Scrub_OlderThan, "30d", "verify files checked before this age (e.g. 12h, 30d, 2w)")
+	scrubCmd.Flags().String(Flag_Scrub_MaxBytes, "", "read at most this amount of bytes, skipping files which don't fit (e.g. 100G, 2T)")
+	scrubCmd.Flags().String(Flag_Scrub_MaxTime, "", "stop after this duration (e.g. 30m, 6h)")
+}
+
+func runScrub(cmd *cobra.Command, args []string) (int, error) {
+	verbose, _ := cmd.Flags().GetBool(Flag_root_Verbose)
+
+	algs, err := getHashAlgs(cmd)
+	if err != nil {
+		return ExitStatus_Fatal, err
+	}
+
+	olderThanStr, _ := cmd.Flags().GetString(Flag_Scrub_OlderThan)
+	olderThan, err := ParseDuration(olderThanStr)
+	if err != nil {
+		return ExitStatus_Fatal, err
+	}
+
+	var budget core.ScrubBudget
+	if s, _ := cmd.Flags().GetString(Flag_Scrub_MaxBytes); s != "" {
+		if budget.MaxBytes, err = ParseSize(s); err != nil {
+			return ExitStatus_Fatal, err
+		}
+	}
+	if s, _ := cmd.Flags().GetString(Flag_Scrub_MaxTime); s != "" {
+		if budget.MaxDuration, err = ParseDuration(s); err != nil {
+			return ExitStatus_Fatal, err
+		}
+	}
+
+	targets, err := core.ListScrubTargets(args, algs, time.Now().Add(-olderThan))
+	if err != nil {
+		return ExitStatus_Fatal, err
+	}
+
+	summary := newVerifySummary()
+	count, bytes := core.Scrub(targets, algs, budget, func(t core.ScrubTarget, result *core.VerifyResult, err error) {
+		summary.report(t.Path, result, err, verbose)
+	})
+
+	if verbose {
+		summary.show()
+		fmt.Fprintf(os.Stderr, "Scrubbed %d / %d files (%d bytes)\n", count, len(targets), bytes)
+	}
+	return summary.status(), nil
+}
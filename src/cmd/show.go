@@ -19,7 +19,6 @@ import (
 	"fmt"
 	"io/fs"
 	"os"
-	"path/filepath"
 	"strconv"
 	"time"
 
@@ -44,16 +43,29 @@ func init() {
 func runShow(cmd *cobra.Command, args []string) (int, error) {
 	recuesive, _ := cmd.Flags().GetBool(Flag_root_Recursive)
 
-	alg := core.NewDefaultHashAlg()
+	alg, err := getHashAlg(cmd)
+	if err != nil {
+		return ExitStatus_Fatal, err
+	}
+	out, err := newRecordWriter(cmd)
+	if err != nil {
+		return ExitStatus_Fatal, err
+	}
 
-	showHeader()
+	if out != nil {
+		// nolint:errcheck
+		defer out.Close()
+	} else {
+		showHeader()
+	}
 
-	status := 0
+	status := ExitStatus_OK
 	var errResult error
 	for _, p := range args {
 		isDir, err := IsDirectory(p)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+			status = ExitStatus_PartialFailure
 			continue
 		}
 
@@ -63,31 +75,47 @@ func runShow(cmd *cobra.Command, args []string) (int, error) {
 				fmt.Fprintf(os.Stderr, "Skip directory : %s\n", p)
 				continue
 			}
-			err = showAttributesRecursively(p, alg)
+			err = showAttributesRecursively(p, alg, out)
 		} else {
-			err = showAttributes(p, alg)
+			err = showAttributes(p, alg, out)
 		}
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "%s\n", err.Error())
-			status = 1
+			status = ExitStatus_PartialFailure
 			errResult = err
 		}
 	}
 	return status, errResult
 }
 
-func showAttributes(path string, hashAlg *core.HashAlg) error {
+func showAttributes(path string, hashAlg *core.HashAlg, out *core.RecordWriter) error {
 	f, err := OpenFile(path)
 	if err != nil {
 		return err
 	}
+	// nolint:errcheck
+	defer f.Close()
 
-	hash := core.GetXattr(f, hashAlg.AttrName)
-	size := core.GetXattr(f, core.Xattr_size)
+	hash := core.GetAttr(f, hashAlg.AttrName)
+	size := core.GetAttr(f, core.Xattr_size)
 
 	mTime := getUnixTimeNano(f, core.Xattr_modifiedTime)
 	hTime := getUnixTimeNano(f, core.Xattr_hashCheckedTime)
 
+	if out != nil {
+		r := core.HashRecord{
+			Path:        path,
+			Hashes:      make([]core.HashValue, 0, 1),
+			ModTime:     mTime,
+			CheckedTime: hTime,
+		}
+		if hash != "" {
+			r.Hashes = append(r.Hashes, core.HashValue{Algorithm: hashAlg.AlgName, Value: hash})
+		}
+		r.Size, _ = strconv.ParseInt(size, 10, 64)
+		return out.Write(r)
+	}
+
 	fmt.Printf("%s\t%s\t%s\t%s\t%s\n", path, hash, size, mTime, hTime)
 
 	return nil
@@ -99,24 +127,24 @@ func showHeader() {
 
 func getUnixTimeNano(f *os.File, attrName string) string {
 	timeStr := ""
-	t, err := strconv.ParseInt(core.GetXattr(f, attrName), 10, 64)
+	t, err := strconv.ParseInt(core.GetAttr(f, attrName), 10, 64)
 	if err == nil {
 		timeStr = time.Unix(0, t).Format(time.RFC3339Nano)
 	}
 	return timeStr
 }
 
-func showAttributesRecursively(dirPath string, hashAlg *core.HashAlg) error {
-	err := filepath.WalkDir(dirPath, func(path string, info fs.DirEntry, err error) error {
+func showAttributesRecursively(dirPath string, hashAlg *core.HashAlg, out *core.RecordWriter) error {
+	err := WalkDirFiltered(dirPath, func(path string, info fs.DirEntry, err error) error {
 		if err != nil {
 			return errors.Wrap(err, "failed to filepath.Walk")
 		}
 
-		if info.IsDir() {
+		if info.IsDir() || IsHasherFile(path) {
 			return nil
 		}
 
-		showErr := showAttributes(path, core.NewDefaultHashAlg())
+		showErr := showAttributes(path, hashAlg, out)
 		if showErr != nil {
 			fmt.Fprintf(os.Stderr, "%s\n", showErr.Error())
 		}
@@ -4,6 +4,16 @@ import (
 	"github.com/spf13/cobra"
 )
 
+// Exit statuses shared by all commands.
+// verify, scrub and import additionally use Status_Corrupted.
+const (
+	ExitStatus_OK = 0
+	// some files could not be processed, or compared files differ (check, compare)
+	ExitStatus_PartialFailure = 1
+	// the command could not run
+	ExitStatus_Fatal = 2
+)
+
 type CobraCommandStatusWrapper struct {
 	Status int
 }
@@ -12,6 +22,10 @@ func (w *CobraCommandStatusWrapper) RunE(f func(cmd *cobra.Command, args []strin
 	return func(cmd *cobra.Command, arg []string) error {
 		status, err := f(cmd, arg)
 		w.Status = status
+		if status == ExitStatus_PartialFailure {
+			// usage is not helpful for errors on files
+			cmd.SilenceUsage = true
+		}
 		return err
 	}
 }
@@ -19,6 +19,10 @@ func (n *StdioProgressNotifier) SetTotal(total int) {
 	// do nothing
 }
 
+func (n *StdioProgressNotifier) SetFileCounter(counter *common.FileCounter) {
+	// do nothing
+}
+
 func (n *StdioProgressNotifier) Start() {
 	// do nothing
 }
@@ -39,6 +43,10 @@ func (n *StdioProgressNotifier) NotifyProgress(done int, total int) {
 	// do nothing
 }
 
+func (n *StdioProgressNotifier) NotifyBytes(workerId int, bytes int64, read bool) {
+	// do nothing
+}
+
 func (n *StdioProgressNotifier) NotifyWarning(workerId int, message string) {
 	fmt.Fprintln(os.Stderr, common.C_yellow.Apply(message))
 }
@@ -0,0 +1,183 @@
+/*
+Copyright © 2022 Yusuke KOMORI
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	. "github.com/little-forest/hasher/common" // nolint:staticcheck
+	"github.com/little-forest/hasher/core"
+	"github.com/spf13/cobra"
+)
+
+const Flag_Sync_Layout = "layout"
+const Flag_Sync_DryRun = "dry-run"
+
+// syncCmd represents the sync command
+var syncCmd = &cobra.Command{
+	Use:     "sync [--layout relative|date] SOURCE_DIR... TARGET_DIR",
+	Aliases: []string{"collect"},
+	Short:   "Copy files missing in the target directory",
+	Long: `Copies each file in SOURCE_DIRs whose contents don't exist anywhere in TARGET_DIR,
+which is a file reported by "hasher duplicate -m -s SOURCE_DIR TARGET_DIR".
+Files of the same contents in SOURCE_DIRs are copied only once.
+
+Where files are copied is chosen by --layout option.
+
+  relative : keep the relative path from SOURCE_DIR (default)
+  date     : YYYY/MM/DD/FILENAME by mtime of the file
+
+When a different file already exists at the path, a number is added to the file name.
+Each copy is read again after written to confirm it has the same hash value as the source,
+and the hash value is written to its attributes, so TARGET_DIR doesn't need to be hashed again.
+Each line shows the source file and the copied file, separated by a tab.
+With --dry-run option, only the plan is shown without copying anything.
+
+Exit status:
+  0 : all missing files are copied
+  1 : some files could not be copied
+  2 : fatal error
+`,
+	Example: `
+  (1) Collect photos not backed up yet, sorted by date
+        hasher sync --layout date /media/card/DCIM ~/Pictures
+`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: statusWrapper.RunE(runSync),
+}
+
+func init() {
+	rootCmd.AddCommand(syncCmd)
+
+	syncCmd.Flags().String(Flag_Sync_Layout, core.SyncLayout_Relative, "where files are copied : relative or date")
+	syncCmd.Flags().BoolP(Flag_Sync_DryRun, "n", false, "show the plan without copying anything")
+}
+
+func runSync(cmd *cobra.Command, args []string) (int, error) {
+	layout, _ := cmd.Flags().GetString(Flag_Sync_Layout)
+	dryRun, _ := cmd.Flags().GetBool(Flag_Sync_DryRun)
+	if layout != core.SyncLayout_Relative && layout != core.SyncLayout_Date {
+		return ExitStatus_Fatal, fmt.Errorf("unsupported layout : %s", layout)
+	}
+
+	alg, err := getHashAlg(cmd)
+	if err != nil {
+		return ExitStatus_Fatal, err
+	}
+
+	srcDirs := args[:len(args)-1]
+	targetDir := args[len(args)-1]
+	for _, d := range args {
+		isDir, e := IsDirectory(d)
+		if e != nil {
+			return ExitStatus_Fatal, e
+		}
+		if !isDir {
+			return ExitStatus_Fatal, fmt.Errorf("not a directory : %s", d)
+		}
+	}
+
+	target, err := loadHashData([]string{targetDir}, alg)
+	if err != nil {
+		return ExitStatus_Fatal, err
+	}
+
+	// paths of files to be copied in dry run
+	planned := make(map[string]bool)
+	taken := func(p string) bool {
+		if planned[p] {
+			return true
+		}
+		_, err := os.Lstat(p)
+		return err == nil
+	}
+
+	// files which could not be hashed are counted as failures
+	summary := core.NewRunSummary()
+	var copied, exists int
+	var copiedBytes int64
+	for _, srcDir := range srcDirs {
+		srcRoot, err := filepath.Abs(srcDir)
+		if err != nil {
+			return ExitStatus_Fatal, err
+		}
+		src := core.NewHashStore()
+		if err := src.AppendHashDataWithSummary(srcRoot, alg, summary); err != nil {
+			return ExitStatus_Fatal, err
+		}
+
+		for _, hash := range src.Values() {
+			if len(target.Get(hash.String())) > 0 {
+				exists++
+				continue
+			}
+
+			dst, err := syncDestPath(hash.Path, srcRoot, targetDir, layout)
+			if err != nil {
+				summary.AddFailed(hash.Path, core.ErrorOp_Stat, err)
+				continue
+			}
+			dst = core.UniquePath(dst, taken)
+
+			if dryRun {
+				planned[dst] = true
+				target.Put(core.NewHash(dst, alg, hash.Value, hash.ModTime))
+			} else {
+				c, err := core.CopyVerified(hash.Path, dst, hash)
+				if err != nil {
+					// the copy is returned even if only writing attributes fails
+					if c == nil {
+						summary.AddFailed(hash.Path, core.ErrorOp_Copy, err)
+						continue
+					}
+					ShowWarn("Failed to update attribute : %s", err.Error())
+				}
+				target.Put(c)
+			}
+			fmt.Printf("%s\t%s\n", hash.Path, dst)
+			copied++
+			copiedBytes += hash.Size
+		}
+	}
+
+	showSummaryErrors(summary)
+	failed := len(summary.Errors())
+	if dryRun {
+		fmt.Fprintf(os.Stderr, "%d files would be copied (%s), %d already exist, %d failed\n", copied, FormatSize(copiedBytes), exists, failed)
+	} else {
+		fmt.Fprintf(os.Stderr, "%d files copied (%s), %d already exist, %d failed\n", copied, FormatSize(copiedBytes), exists, failed)
+	}
+	if failed > 0 {
+		err := &core.PartialFailureError{NumOfErrors: failed}
+		return exitStatusOf(err), err
+	}
+	return ExitStatus_OK, nil
+}
+
+// syncDestPath returns the path where the source file is copied in the target directory.
+func syncDestPath(path string, srcRoot string, targetDir string, layout string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(srcRoot, path)
+	if err != nil {
+		return "", err
+	}
+	return core.SyncDestPath(targetDir, rel, info.ModTime(), layout)
+}
@@ -16,8 +16,12 @@ limitations under the License.
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 
 	. "github.com/little-forest/hasher/common" // nolint:staticcheck
 	"github.com/little-forest/hasher/core"
@@ -25,19 +29,61 @@ import (
 )
 
 const Flag_Update_ForceUpdate = "force-update"
+const Flag_Update_Jobs = "jobs"
+const Flag_Update_HddJobs = "hdd-jobs"
+const Flag_Update_MaxRate = "max-rate"
+const Flag_Update_MaxLoad = "max-load"
+const Flag_Update_Nice = "nice"
+const Flag_Update_IONice = "ionice"
+const Flag_Update_Resume = "resume"
+const Flag_Update_ErrorReport = "error-report"
 
 // updateCmd represents the update command
 var updateCmd = &cobra.Command{
-	Use:   "update",
+	Use:   "update [-r] [-j JOBS] [--resume] [--max-rate RATE] [--max-load LOAD] TARGET...",
 	Short: "Calculate file hash and save to extended attribute",
-	Long:  ``,
-	RunE:  statusWrapper.RunE(runUpdateHash),
+	Long: `Calculates hash values and saves them to extended attributes.
+
+With -r, files are hashed by multiple workers in parallel.
+Files are grouped by the device they reside on, and each device is processed
+in its own lane, so that several disks are read in parallel.
+Each rotational disk (HDD) is read by only --hdd-jobs workers at once
+to avoid random seeks, while other devices are read by all workers.
+The total number of running workers is limited by --jobs.
+
+The progress of recursive update is recorded in a journal under the user cache directory.
+When the update is interrupted by Ctrl-C, running workers finish their files
+and the journal is saved. Run the same command with --resume to continue
+where the last run stopped.
+
+To run in the background without starving other workloads,
+--max-rate limits the total read bandwidth of all workers (e.g. 50MB/s),
+and --max-load pauses reading while the 1 minute load average exceeds LOAD.
+--nice and --ionice lower the CPU and I/O scheduling priority (Linux only).
+
+After the update, a summary of scanned, hashed, failed and skipped files is shown.
+With --error-report, the summary and all errors are also written to the file in JSON.
+
+Exit status:
+  0 : all files are updated
+  1 : some files could not be updated
+  2 : fatal error, or interrupted
+`,
+	RunE: statusWrapper.RunE(runUpdateHash),
 }
 
 func init() {
 	rootCmd.AddCommand(updateCmd)
 
 	updateCmd.Flags().BoolP(Flag_Update_ForceUpdate, "f", false, "Force update")
+	updateCmd.Flags().IntP(Flag_Update_Jobs, "j", 0, fmt.Sprintf("number of workers for recursive update (default: %d)", core.DefaultNumOfWorkers()))
+	updateCmd.Flags().Int(Flag_Update_HddJobs, core.DefaultRotationalJobs, "number of workers reading the same rotational disk at once")
+	updateCmd.Flags().String(Flag_Update_MaxRate, "", "max read bandwidth of all workers (e.g. 50MB/s)")
+	updateCmd.Flags().Float64(Flag_Update_MaxLoad, 0, "pause while the load average exceeds this value")
+	updateCmd.Flags().Int(Flag_Update_Nice, 0, "niceness to run with (-20 to 19)")
+	updateCmd.Flags().Bool(Flag_Update_Resume, false, "resume the interrupted recursive update")
+	updateCmd.Flags().String(Flag_Update_IONice, "", "I/O priority to run with : idle or best-effort[:0-7]")
+	updateCmd.Flags().String(Flag_Update_ErrorReport, "", "write the summary and errors to the file in JSON")
 }
 
 func runUpdateHash(cmd *cobra.Command, args []string) (int, error) {
@@ -45,9 +91,18 @@ func runUpdateHash(cmd *cobra.Command, args []string) (int, error) {
 	verbose, _ := cmd.Flags().GetBool(Flag_root_Verbose)
 	recuesive, _ := cmd.Flags().GetBool(Flag_root_Recursive)
 
-	alg := core.NewDefaultHashAlg()
+	reportPath, _ := cmd.Flags().GetString(Flag_Update_ErrorReport)
+
+	algs, err := getHashAlgs(cmd)
+	if err != nil {
+		return ExitStatus_Fatal, err
+	}
+
+	if err := setupThrottle(cmd); err != nil {
+		return ExitStatus_Fatal, err
+	}
 
-	status := 0
+	summary := core.NewRunSummary()
 	var errorStatus error
 
 	if !recuesive {
@@ -56,46 +111,66 @@ func runUpdateHash(cmd *cobra.Command, args []string) (int, error) {
 			isDir, err := IsDirectory(p)
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+				summary.AddFailed(p, core.ErrorOp_Stat, err)
 				continue
 			}
 
 			if isDir {
 				// skip dir
 				fmt.Fprintf(os.Stderr, "Skip directory : %s\n", p)
+				summary.AddSkipped(p, "directory")
 				continue
-			} else {
-				// update file
-				changed, hash, err := core.UpdateHash(p, alg, forceUpdate) // nolint:govet
-				if err == nil && verbose {
-					mark := ""
-					if changed {
-						mark = "*"
-					}
-					fmt.Fprintf(os.Stdout, "%s  %s %s\n", p, hash.String(), mark) // nolint:errcheck
-				}
 			}
 
-			if err != nil { // nolint:govet
+			// update file
+			summary.AddScanned()
+			changed, hashes, err := core.UpdateHashesStrictly(p, algs, forceUpdate)
+			if err != nil {
+				if errors.As(err, core.Err_updateError) {
+					err = fmt.Errorf("failed to update attribute : %s", err.Error())
+					summary.AddFailed(p, core.ErrorOp_Update, err)
+				} else {
+					summary.AddFailed(p, core.ErrorOp_Hash, err)
+				}
 				fmt.Fprintf(os.Stderr, "%s\n", err.Error())
-				errorStatus = err
-				status = 1
 				continue
 			}
+			summary.AddHashed(changed)
+			if verbose {
+				mark := ""
+				if changed {
+					mark = "*"
+				}
+				hashValues := make([]string, len(hashes))
+				for i, h := range hashes {
+					hashValues[i] = h.String()
+				}
+				fmt.Fprintf(os.Stdout, "%s  %s %s\n", p, strings.Join(hashValues, " "), mark) // nolint:errcheck
+			}
 		}
 	} else {
 		// recursive update, directory only
-		err := updateHashConcurrently(args, alg, forceUpdate, verbose)
-		if err != nil {
-			errorStatus = err
-			status = 1
+		jobs, _ := cmd.Flags().GetInt(Flag_Update_Jobs)
+		if jobs <= 0 {
+			jobs = core.DefaultNumOfWorkers()
 		}
+		hddJobs, _ := cmd.Flags().GetInt(Flag_Update_HddJobs)
+		resume, _ := cmd.Flags().GetBool(Flag_Update_Resume)
+
+		errorStatus = updateHashConcurrently(args, algs, forceUpdate, resume, core.NewIOScheduler(jobs, hddJobs), summary, verbose)
+	}
+	if errorStatus == nil {
+		errorStatus = summary.Err()
+	}
+
+	if err := finishRunSummary(summary, reportPath); err != nil {
+		return ExitStatus_Fatal, err
 	}
-	return status, errorStatus
+	return exitStatusOf(errorStatus), errorStatus
 }
 
-func updateHashConcurrently(dirPaths []string, alg *core.HashAlg, forceUpdate bool, verbose bool) error {
-	numOfWorkers := 1
-	notifier := NewHasherProgressNotifier(numOfWorkers, verbose)
+func updateHashConcurrently(dirPaths []string, algs []*core.HashAlg, forceUpdate bool, resume bool, sched *core.IOScheduler, summary *core.RunSummary, verbose bool) error {
+	notifier := NewHasherProgressNotifier(sched.NumOfWorkers(), verbose)
 
 	paths := make([]string, 0)
 	for _, p := range dirPaths {
@@ -104,16 +179,123 @@ func updateHashConcurrently(dirPaths []string, alg *core.HashAlg, forceUpdate bo
 		} else {
 			if err == nil {
 				fmt.Fprintf(os.Stderr, "Not a directory, skip. : %s\n", p)
+				summary.AddSkipped(p, "not a directory")
 			} else {
 				fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+				summary.AddFailed(p, core.ErrorOp_Stat, err)
 			}
 		}
 	}
 
-	if len(paths) > 0 {
-		err := core.ConcurrentUpdateHash(paths, alg, numOfWorkers, forceUpdate, notifier)
-		return err
-	} else {
+	if len(paths) == 0 {
 		return nil
 	}
+
+	journal, err := openUpdateJournal(paths, algs, resume)
+	if err != nil {
+		return err
+	}
+
+	// stop gracefully on Ctrl-C
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	finished := make(chan struct{})
+	go func() {
+		select {
+		case <-sigCh:
+			// interrupt immediately on the next signal
+			signal.Stop(sigCh)
+			sched.Stop()
+		case <-finished:
+		}
+	}()
+
+	err = core.ConcurrentUpdateHash(paths, algs, forceUpdate, sched, journal, summary, notifier)
+	close(finished)
+	signal.Stop(sigCh)
+
+	if closeErr := journal.Close(err != core.ErrInterrupted); closeErr != nil {
+		ShowWarn("Failed to save journal : %s", closeErr.Error())
+	}
+	if err == core.ErrInterrupted && journal != nil {
+		return fmt.Errorf("interrupted. run with --%s to continue", Flag_Update_Resume)
+	}
+	return err
+}
+
+// openUpdateJournal opens the journal of the recursive update.
+// When the journal is not available, the update runs without it.
+func openUpdateJournal(paths []string, algs []*core.HashAlg, resume bool) (*core.UpdateJournal, error) {
+	path, err := core.DefaultJournalPath(paths)
+	if err != nil {
+		ShowWarn("Journal is not available : %s", err.Error())
+		return nil, nil
+	}
+
+	if resume {
+		journal, resumeErr := core.ResumeUpdateJournal(path, paths, algs)
+		if resumeErr == nil {
+			return journal, nil
+		}
+		if !os.IsNotExist(resumeErr) {
+			return nil, resumeErr
+		}
+		ShowWarn("No interrupted update is found. Start from the beginning.")
+	} else if _, statErr := os.Stat(path); statErr == nil {
+		ShowWarn("Previous update was interrupted. Start from the beginning. (use --%s to continue)", Flag_Update_Resume)
+	}
+
+	journal, err := core.NewUpdateJournal(path, paths, algs)
+	if err != nil {
+		ShowWarn("Journal is not available : %s", err.Error())
+		return nil, nil
+	}
+	return journal, nil
+}
+
+// setupThrottle lowers priorities of the process and sets the throttle of reading files.
+func setupThrottle(cmd *cobra.Command) error {
+	if cmd.Flags().Changed(Flag_Update_Nice) {
+		nice, _ := cmd.Flags().GetInt(Flag_Update_Nice)
+		if err := SetNiceness(nice); err != nil {
+			return fmt.Errorf("failed to set niceness : %s", err.Error())
+		}
+	}
+
+	if v, _ := cmd.Flags().GetString(Flag_Update_IONice); v != "" {
+		class, level, err := ParseIOPriority(v)
+		if err != nil {
+			return err
+		}
+		if err := SetIOPriority(class, level); err != nil {
+			return fmt.Errorf("failed to set I/O priority : %s", err.Error())
+		}
+	}
+
+	var rate int64
+	if v, _ := cmd.Flags().GetString(Flag_Update_MaxRate); v != "" {
+		r, err := ParseRate(v)
+		if err != nil {
+			return err
+		}
+		if r <= 0 {
+			return fmt.Errorf("invalid rate : %s", v)
+		}
+		rate = r
+	}
+
+	maxLoad, _ := cmd.Flags().GetFloat64(Flag_Update_MaxLoad)
+	if maxLoad < 0 {
+		return fmt.Errorf("invalid load average : %v", maxLoad)
+	}
+	if maxLoad > 0 {
+		if _, err := LoadAverage(); err != nil {
+			return err
+		}
+	}
+
+	if rate > 0 || maxLoad > 0 {
+		core.SetThrottle(core.NewThrottle(rate, maxLoad))
+	}
+	return nil
 }
@@ -0,0 +1,164 @@
+/*
+Copyright © 2022 Yusuke KOMORI
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	. "github.com/little-forest/hasher/common" // nolint:staticcheck
+	"github.com/little-forest/hasher/core"
+	"github.com/spf13/cobra"
+)
+
+// Exit status when corrupted files are detected
+const Status_Corrupted = 3
+
+var Mark_Corrupted = fmt.Sprintf("[%s]", C_red.Apply("CORRUPTED"))
+var Mark_Modified = fmt.Sprintf("[%s]", C_yellow.Apply("MODIFIED"))
+var Mark_NoHash = fmt.Sprintf("[%s]", C_gray.Apply("NO HASH"))
+
+// verifyCmd represents the verify command
+var verifyCmd = &cobra.Command{
+	Use:   "verify [-r] TARGET...",
+	Short: "Verify file contents with stored hash values",
+	Long: `Recalculates hash values and compares them with stored values without overwriting them.
+Files whose contents changed while size and mtime stayed the same are reported as CORRUPTED.
+
+  [OK]        : hash value matches
+  [CORRUPTED] : contents changed while size and mtime are unchanged
+  [MODIFIED]  : size or mtime changed after hash was calculated
+  [NO HASH]   : hash value has not been calculated yet
+
+Exit status:
+  0 : all files are OK
+  1 : some files could not be verified
+  2 : fatal error
+  3 : corrupted files are found
+`,
+	RunE: statusWrapper.RunE(runVerify),
+}
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+}
+
+type verifySummary struct {
+	counts map[core.VerifyStatus]int
+	errors int
+}
+
+func newVerifySummary() *verifySummary {
+	return &verifySummary{counts: make(map[core.VerifyStatus]int)}
+}
+
+func (s verifySummary) status() int {
+	if s.counts[core.VERIFY_CORRUPTED] > 0 {
+		return Status_Corrupted
+	}
+	if s.errors > 0 {
+		return ExitStatus_PartialFailure
+	}
+	return ExitStatus_OK
+}
+
+func (s verifySummary) show() {
+	fmt.Fprintf(os.Stderr, "OK: %d, CORRUPTED: %d, MODIFIED: %d, NO HASH: %d, ERROR: %d\n",
+		s.counts[core.VERIFY_OK], s.counts[core.VERIFY_CORRUPTED], s.counts[core.VERIFY_MODIFIED],
+		s.counts[core.VERIFY_NO_HASH], s.errors)
+}
+
+func runVerify(cmd *cobra.Command, args []string) (int, error) {
+	verbose, _ := cmd.Flags().GetBool(Flag_root_Verbose)
+	recursive, _ := cmd.Flags().GetBool(Flag_root_Recursive)
+
+	algs, err := getHashAlgs(cmd)
+	if err != nil {
+		return ExitStatus_Fatal, err
+	}
+
+	summary := newVerifySummary()
+	for _, p := range args {
+		isDir, err := IsDirectory(p)
+		if err != nil {
+			ShowError(err)
+			summary.errors++
+			continue
+		}
+
+		if !isDir {
+			verifyFile(p, algs, summary, verbose)
+			continue
+		}
+
+		if !recursive {
+			// skip dir
+			fmt.Fprintf(os.Stderr, "Skip directory : %s\n", p)
+			continue
+		}
+		err = WalkDir(p, func(f *os.File) error {
+			verifyFile(f.Name(), algs, summary, verbose)
+			return nil
+		})
+		if err != nil {
+			ShowError(err)
+			summary.errors++
+		}
+	}
+
+	if verbose {
+		summary.show()
+	}
+	return summary.status(), nil
+}
+
+func verifyFile(path string, algs []*core.HashAlg, summary *verifySummary, verbose bool) {
+	result, err := core.VerifyHash(path, algs)
+	summary.report(path, result, err, verbose)
+}
+
+// report shows the result of core.VerifyHash and counts it.
+func (s *verifySummary) report(path string, result *core.VerifyResult, err error, verbose bool) {
+	if err != nil {
+		if result == nil {
+			ShowErrorMsg("Failed to verify : %s", err.Error())
+			s.errors++
+			return
+		}
+		// verified, but failed to update checked time
+		ShowWarn("Failed to update attribute : %s", err.Error())
+	}
+	s.counts[result.Status]++
+
+	switch result.Status {
+	case core.VERIFY_OK:
+		if verbose {
+			fmt.Printf("%s %s\n", Mark_OK, path)
+		}
+	case core.VERIFY_CORRUPTED:
+		fmt.Printf("%s %s\n", Mark_Corrupted, path)
+		expected, actual := result.Mismatches()
+		for i, e := range expected {
+			fmt.Printf("    %s: expected %s, actual %s\n", e.Alg.AlgName, e.String(), actual[i].String())
+		}
+	case core.VERIFY_MODIFIED:
+		fmt.Printf("%s %s\n", Mark_Modified, path)
+	case core.VERIFY_NO_HASH:
+		if verbose {
+			fmt.Printf("%s %s\n", Mark_NoHash, path)
+		}
+	}
+}
@@ -143,12 +143,12 @@ func EnsureRegularFile(path string) error {
 }
 
 func IsSymbolicLink(path string) (bool, error) {
-	ftype, err := CheckFileType(path)
+	info, err := os.Lstat(path)
 	if err != nil {
 		return false, err
 	}
 
-	return ftype == SymbolicLink, nil
+	return info.Mode()&os.ModeSymlink == os.ModeSymlink, nil
 }
 
 func CleanPath(path string) (string, error) {
@@ -163,55 +163,6 @@ func CleanPath(path string) (string, error) {
 	return filepath.Clean(path), nil
 }
 
-// CountAllFiles counts files under the specified file or directory.
-func CountAllFiles(paths []string, verbose bool) int {
-	threshold := 1000
-
-	if verbose {
-		fmt.Print(C_cyan.Apply("Counting files... "))
-		HideCursor()
-	}
-
-	count := 0
-	for _, p := range paths {
-		t, err := CheckFileType(p)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "[%s] %s\n", C_red.Apply("ERROR"), err.Error())
-			continue
-		}
-
-		switch t {
-		case RegularFile:
-			count++
-			if verbose && (count%threshold) == 0 {
-				fmt.Printf("\x1b7%d\x1b8", count)
-			}
-
-		case Directory:
-			err := WalkDir(p, func(f *os.File) error {
-				count++
-				if verbose && (count%threshold) == 0 {
-					fmt.Printf("\x1b7%d\x1b8", count)
-				}
-				return nil
-			})
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "[%s] %s\n", C_red.Apply("ERROR"), err.Error())
-				continue
-			}
-		default:
-			fmt.Fprintf(os.Stderr, "[%s] Ignored : %s\n", C_yellow.Apply("WARN"), p)
-		}
-
-	}
-
-	if verbose {
-		fmt.Printf("%d\n", count)
-		ShowCursor()
-	}
-	return count
-}
-
 func ShowCursor() {
 	fmt.Print("\x1b[?25h")
 }
@@ -0,0 +1,29 @@
+//go:build linux
+
+package common
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// IsRotationalDevice returns true if given device is a rotational disk (HDD).
+// When it can't be determined (e.g. network or virtual filesystems), it returns false.
+func IsRotationalDevice(dev uint64) bool {
+	dir, err := filepath.EvalSymlinks(fmt.Sprintf("/sys/dev/block/%d:%d", unix.Major(dev), unix.Minor(dev)))
+	if err != nil {
+		return false
+	}
+	// partitions don't have queue attributes, see the parent device
+	for _, d := range []string{dir, filepath.Dir(dir)} {
+		b, err := os.ReadFile(filepath.Join(d, "queue", "rotational"))
+		if err == nil {
+			return strings.TrimSpace(string(b)) == "1"
+		}
+	}
+	return false
+}
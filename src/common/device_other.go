@@ -0,0 +1,9 @@
+//go:build !linux
+
+package common
+
+// IsRotationalDevice returns true if given device is a rotational disk (HDD).
+// It is supported only on Linux, and always returns false on other platforms.
+func IsRotationalDevice(dev uint64) bool {
+	return false
+}
@@ -0,0 +1,159 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// Max number of directories read at once by FileCounter
+const fileCounterParallelism = 8
+
+// FileCounter counts files and their total size under directories in the background,
+// so that the total can be shown while processing them.
+//
+// Directories are read in parallel without opening files,
+// with the same filter as WalkDirFiltered.
+// The count is an estimate, because symbolic links to directories are not followed
+// and mount points are not checked.
+type FileCounter struct {
+	// limits the number of goroutines reading directories
+	sem     chan struct{}
+	done    chan struct{}
+	wg      sync.WaitGroup
+	count   atomic.Int64
+	bytes   atomic.Int64
+	stopped atomic.Bool
+}
+
+// StartCountingFiles starts counting files under given paths.
+func StartCountingFiles(paths []string) *FileCounter {
+	numOfReaders := fileCounterParallelism
+	if n := runtime.NumCPU(); n < numOfReaders {
+		numOfReaders = n
+	}
+
+	c := &FileCounter{
+		sem:  make(chan struct{}, numOfReaders),
+		done: make(chan struct{}),
+	}
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			continue
+		}
+		if !info.IsDir() {
+			if info.Mode().IsRegular() {
+				c.add(info)
+			}
+			continue
+		}
+		f := NewPathFilter(p)
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			c.countDir(f, p)
+		}()
+	}
+
+	go func() {
+		c.wg.Wait()
+		close(c.done)
+	}()
+	return c
+}
+
+// Count returns the number of files counted so far.
+func (c *FileCounter) Count() int {
+	return int(c.count.Load())
+}
+
+// Bytes returns the total size of files counted so far.
+func (c *FileCounter) Bytes() int64 {
+	return c.bytes.Load()
+}
+
+// IsDone returns true if all files have been counted.
+func (c *FileCounter) IsDone() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Wait waits until all files are counted, and returns the number of them.
+func (c *FileCounter) Wait() int {
+	<-c.done
+	return c.Count()
+}
+
+// Stop stops counting. Count returns the number of files counted before it.
+func (c *FileCounter) Stop() {
+	c.stopped.Store(true)
+}
+
+func (c *FileCounter) countDir(f *PathFilter, dir string) {
+	if c.stopped.Load() {
+		return
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+
+	for _, e := range entries {
+		p := filepath.Join(dir, e.Name())
+		var info os.FileInfo
+		if e.Type()&os.ModeSymlink != 0 {
+			if !followSymlinks {
+				continue
+			}
+			// links to directories are not followed
+			if info, err = os.Stat(p); err != nil || !info.Mode().IsRegular() {
+				continue
+			}
+		} else if !e.IsDir() && !e.Type().IsRegular() {
+			continue
+		}
+		if f.IsExcluded(p, e) {
+			continue
+		}
+
+		if !e.IsDir() {
+			if IsHasherFile(p) {
+				continue
+			}
+			if info == nil {
+				if info, err = e.Info(); err != nil {
+					continue
+				}
+			}
+			c.add(info)
+			continue
+		}
+
+		// read the subdirectory in another goroutine if possible
+		select {
+		case c.sem <- struct{}{}:
+			c.wg.Add(1)
+			go func(p string) {
+				defer func() {
+					<-c.sem
+					c.wg.Done()
+				}()
+				c.countDir(f, p)
+			}(p)
+		default:
+			c.countDir(f, p)
+		}
+	}
+}
+
+func (c *FileCounter) add(info os.FileInfo) {
+	c.count.Add(1)
+	c.bytes.Add(info.Size())
+}
@@ -0,0 +1,46 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileCounter(t *testing.T) {
+	dir := t.TempDir()
+	files := make(map[string]string)
+	for i := 0; i < 30; i++ {
+		files[fmt.Sprintf("d%d/e%d/f%d.txt", i%3, i%5, i)] = "x"
+	}
+	files["d0/skip.log"] = "x"
+	files["d1/"+SidecarFileName] = "x"
+	makeFiles(t, dir, files)
+	assert.NoError(t, os.Symlink("d0", filepath.Join(dir, "link")))
+
+	single := filepath.Join(dir, "d2", "e2", "f2.txt")
+	missing := filepath.Join(dir, "missing")
+
+	c := StartCountingFiles([]string{dir, single, missing})
+	assert.Equal(t, 32, c.Wait())
+	assert.Equal(t, int64(32), c.Bytes())
+
+	// the count is the same as the number of walked files
+	assert.NoError(t, SetWalkFilter(WalkFilter{Excludes: []string{"*.log"}}))
+	t.Cleanup(func() { SetWalkFilter(WalkFilter{}) }) // nolint:errcheck
+	c = StartCountingFiles([]string{dir})
+	assert.Equal(t, 30, c.Wait())
+	assert.True(t, c.IsDone())
+	assert.Len(t, walkFiltered(t, dir, WalkFilter{Excludes: []string{"*.log"}}), 30)
+}
+
+func TestFileCounter_stop(t *testing.T) {
+	dir := t.TempDir()
+	makeFiles(t, dir, map[string]string{"a/b.txt": "x"})
+
+	c := StartCountingFiles([]string{dir})
+	c.Stop()
+	assert.LessOrEqual(t, c.Wait(), 1)
+}
@@ -0,0 +1,13 @@
+package common
+
+import "fmt"
+
+// FileId identifies a file on the system by device and inode number.
+type FileId struct {
+	Dev uint64
+	Ino uint64
+}
+
+func (id FileId) String() string {
+	return fmt.Sprintf("%d:%d", id.Dev, id.Ino)
+}
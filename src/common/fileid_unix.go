@@ -0,0 +1,28 @@
+//go:build !windows
+
+package common
+
+import (
+	"os"
+	"syscall"
+)
+
+// GetFileId returns device and inode number of given file info.
+// When they are not available, it will return false.
+func GetFileId(info os.FileInfo) (FileId, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return FileId{}, false
+	}
+	return FileId{Dev: uint64(stat.Dev), Ino: uint64(stat.Ino)}, true // nolint:unconvert
+}
+
+// GetNumOfLinks returns the number of hard links of given file info.
+// When it is not available, it will return false.
+func GetNumOfLinks(info os.FileInfo) (uint64, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return uint64(stat.Nlink), true // nolint:unconvert
+}
@@ -0,0 +1,19 @@
+//go:build windows
+
+package common
+
+import (
+	"os"
+)
+
+// GetFileId returns device and inode number of given file info.
+// When they are not available, it will return false.
+func GetFileId(info os.FileInfo) (FileId, bool) {
+	return FileId{}, false
+}
+
+// GetNumOfLinks returns the number of hard links of given file info.
+// When it is not available, it will return false.
+func GetNumOfLinks(info os.FileInfo) (uint64, bool) {
+	return 0, false
+}
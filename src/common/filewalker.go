@@ -4,10 +4,198 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/pkg/errors"
 )
 
+// Name of the sidecar file which stores hash attributes
+const SidecarFileName = ".hasher"
+
+// Suffixes of temporary files, which are hidden files named after the replaced or copied file
+const (
+	TempFileSuffix_Dedupe = ".hasher-dedupe"
+	TempFileSuffix_Sync   = ".hasher-sync"
+)
+
+// IsHasherFile returns true if given path is a file managed by hasher itself,
+// including temporary files left by an interrupted run.
+// Such files are never dealt as targets.
+func IsHasherFile(path string) bool {
+	name := filepath.Base(path)
+	if name == SidecarFileName || strings.HasPrefix(name, SidecarFileName+".") {
+		return true
+	}
+	return strings.HasPrefix(name, ".") &&
+		(strings.HasSuffix(name, TempFileSuffix_Dedupe) || strings.HasSuffix(name, TempFileSuffix_Sync))
+}
+
+var followSymlinks bool
+
+// SetFollowSymlinks sets whether symbolic links are followed while walking directories.
+// By default, symbolic links are skipped.
+func SetFollowSymlinks(follow bool) {
+	followSymlinks = follow
+}
+
+func IsFollowingSymlinks() bool {
+	return followSymlinks
+}
+
+// Reasons why files are skipped while walking directories
+const (
+	SkipReason_Symlink       = "symlink"
+	SkipReason_BrokenSymlink = "broken symlink"
+	SkipReason_SymlinkLoop   = "symlink loop"
+	SkipReason_MountPoint    = "mount point"
+	SkipReason_Excluded      = "excluded"
+	SkipReason_Device        = "device"
+	SkipReason_Fifo          = "fifo"
+	SkipReason_Socket        = "socket"
+	SkipReason_Irregular     = "irregular file"
+)
+
+// specialFileSkipReason returns the reason to skip the file of given mode.
+// When the file is a regular file or a directory, it will return "".
+func specialFileSkipReason(mode fs.FileMode) string {
+	switch {
+	case mode&fs.ModeDevice != 0:
+		return SkipReason_Device
+	case mode&fs.ModeNamedPipe != 0:
+		return SkipReason_Fifo
+	case mode&fs.ModeSocket != 0:
+		return SkipReason_Socket
+	case mode&fs.ModeIrregular != 0:
+		return SkipReason_Irregular
+	}
+	return ""
+}
+
+// dirWalker walks directories in lexical order like filepath.WalkDir.
+// When following symbolic links, files and directories are reported with the path of the link,
+// and fs.DirEntry of the link target.
+type dirWalker struct {
+	filter *PathFilter
+	fn     fs.WalkDirFunc
+	// directories on the current path, to detect loops of symbolic links
+	ancestors map[FileId]bool
+	// device of the root directory
+	rootDev uint64
+	follow  bool
+}
+
+func (w *dirWalker) walkRoot(root string) error {
+	// the root is always followed
+	info, err := os.Stat(root)
+	if err != nil {
+		err = w.fn(root, nil, err)
+	} else if info.IsDir() {
+		if id, ok := GetFileId(info); ok {
+			w.rootDev = id.Dev
+		}
+		err = w.walkDir(root, fs.FileInfoToDirEntry(info), w.rootDev)
+	} else {
+		err = w.fn(root, fs.FileInfoToDirEntry(info), nil)
+	}
+	if err == filepath.SkipDir || err == filepath.SkipAll {
+		return nil
+	}
+	return err
+}
+
+func (w *dirWalker) walk(path string, d fs.DirEntry, parentDev uint64) error {
+	if d.Type()&fs.ModeSymlink != 0 {
+		if !w.follow {
+			w.filter.skip(path, SkipReason_Symlink)
+			return nil
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			ShowWarn("Skip broken symbolic link : %s", path)
+			w.filter.skip(path, SkipReason_BrokenSymlink)
+			return nil
+		}
+		d = fs.FileInfoToDirEntry(info)
+	}
+
+	// devices, pipes and sockets are never walked
+	if reason := specialFileSkipReason(d.Type()); reason != "" {
+		w.filter.skip(path, reason)
+		return nil
+	}
+
+	if w.filter.IsExcluded(path, d) {
+		w.filter.skip(path, SkipReason_Excluded)
+		return nil
+	}
+
+	if !d.IsDir() {
+		return w.fn(path, d, nil)
+	}
+	return w.walkDir(path, d, parentDev)
+}
+
+func (w *dirWalker) walkDir(path string, d fs.DirEntry, parentDev uint64) error {
+	dev := parentDev
+	if info, err := d.Info(); err == nil {
+		if id, ok := GetFileId(info); ok {
+			if w.ancestors[id] {
+				ShowWarn("Skip symbolic link loop : %s", path)
+				w.filter.skip(path, SkipReason_SymlinkLoop)
+				return nil
+			}
+			if id.Dev != parentDev {
+				if reason, skip := w.isSkippedMountPoint(id.Dev); skip {
+					addSkippedMountPoint(path, reason)
+					w.filter.skip(path, SkipReason_MountPoint)
+					return nil
+				}
+			}
+			dev = id.Dev
+			w.ancestors[id] = true
+			defer delete(w.ancestors, id)
+		}
+	}
+
+	if err := w.fn(path, d, nil); err != nil {
+		if err == filepath.SkipDir {
+			return nil
+		}
+		return err
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		if err = w.fn(path, d, err); err == filepath.SkipDir {
+			return nil
+		}
+		return err
+	}
+
+	for _, e := range entries {
+		if err := w.walk(filepath.Join(path, e.Name()), e, dev); err != nil {
+			if err == filepath.SkipDir {
+				// skip remaining files in the directory
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// isSkippedMountPoint returns true with the reason,
+// if the filesystem mounted on the directory should not be walked.
+func (w *dirWalker) isSkippedMountPoint(dev uint64) (string, bool) {
+	if w.filter.filter.oneFileSystem && dev != w.rootDev {
+		return "other filesystem", true
+	}
+	if m, ok := GetMountInfo(dev); ok && !w.filter.filter.isWalkedFsType(m.FsType) {
+		return m.FsType, true
+	}
+	return "", false
+}
+
 type FileWalker interface {
 	Deal(file *os.File) error
 }
@@ -19,21 +207,12 @@ func WalkDirsWithWalker(dirPaths []string, walker FileWalker) error {
 }
 
 func WalkDir(dirPath string, dealFile func(file *os.File) error) error {
-	err := filepath.WalkDir(dirPath, func(path string, info fs.DirEntry, err error) error {
+	err := WalkDirFiltered(dirPath, func(path string, info fs.DirEntry, err error) error {
 		if err != nil {
 			return errors.Wrap(err, "failed to filepath.Walk")
 		}
 
-		if info.IsDir() {
-			return nil
-		}
-
-		// don't follow symbolic link
-		isSymlink, err := IsSymbolicLink(path)
-		if err != nil {
-			return err
-		}
-		if isSymlink {
+		if info.IsDir() || IsHasherFile(path) {
 			return nil
 		}
 
@@ -0,0 +1,69 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func makeSymlinkTree(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	makeFiles(t, dir, map[string]string{
+		"real/a.txt": "a",
+		"real/b.txt": "b",
+	})
+	assert.NoError(t, os.Symlink("a.txt", filepath.Join(dir, "real", "link.txt")))
+	assert.NoError(t, os.Symlink("../real", filepath.Join(dir, "real", "loop")))
+	assert.NoError(t, os.Symlink("real", filepath.Join(dir, "linkdir")))
+	assert.NoError(t, os.Symlink("not_exist", filepath.Join(dir, "broken")))
+	return dir
+}
+
+func walkPaths(t *testing.T, dir string) []string {
+	t.Helper()
+	paths := make([]string, 0)
+	err := WalkDir(dir, func(file *os.File) error {
+		rel, _ := filepath.Rel(dir, file.Name())
+		paths = append(paths, filepath.ToSlash(rel))
+		return nil
+	})
+	assert.NoError(t, err)
+	return paths
+}
+
+func TestIsHasherFile(t *testing.T) {
+	assert.True(t, IsHasherFile(filepath.Join("dir", SidecarFileName)))
+	assert.True(t, IsHasherFile(filepath.Join("dir", SidecarFileName+".tmp")))
+	assert.True(t, IsHasherFile(filepath.Join("dir", ".a.txt.0123abcd"+TempFileSuffix_Dedupe)))
+	assert.True(t, IsHasherFile(filepath.Join("dir", ".a.txt.0123abcd"+TempFileSuffix_Sync)))
+	assert.False(t, IsHasherFile(filepath.Join("dir", "a.txt")))
+	assert.False(t, IsHasherFile(filepath.Join("dir", "a.txt"+TempFileSuffix_Sync)))
+}
+
+func TestWalkDir_skipSymlinks(t *testing.T) {
+	dir := makeSymlinkTree(t)
+
+	assert.Equal(t, []string{"real/a.txt", "real/b.txt"}, walkPaths(t, dir))
+}
+
+func TestWalkDir_followSymlinks(t *testing.T) {
+	dir := makeSymlinkTree(t)
+	SetFollowSymlinks(true)
+	t.Cleanup(func() { SetFollowSymlinks(false) })
+
+	// loops are walked only once
+	assert.Equal(t, []string{
+		"linkdir/a.txt", "linkdir/b.txt", "linkdir/link.txt",
+		"real/a.txt", "real/b.txt", "real/link.txt",
+	}, walkPaths(t, dir))
+}
+
+func TestWalkDir_symlinkRoot(t *testing.T) {
+	dir := makeSymlinkTree(t)
+
+	// the root is always followed
+	assert.Equal(t, []string{"a.txt", "b.txt"}, walkPaths(t, filepath.Join(dir, "linkdir")))
+}
@@ -0,0 +1,33 @@
+//go:build unix
+
+package common
+
+import (
+	"io/fs"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sys/unix"
+)
+
+func TestPathFilter_OnSkip(t *testing.T) {
+	dir := makeSymlinkTree(t)
+	assert.NoError(t, unix.Mkfifo(filepath.Join(dir, "real", "fifo"), 0o644))
+
+	skipped := make(map[string]string)
+	f := NewPathFilter(dir)
+	f.OnSkip(func(path string, reason string) {
+		rel, _ := filepath.Rel(dir, path)
+		skipped[filepath.ToSlash(rel)] = reason
+	})
+	assert.NoError(t, f.WalkDir(func(path string, d fs.DirEntry, err error) error { return err }))
+
+	assert.Equal(t, map[string]string{
+		"broken":        SkipReason_Symlink,
+		"linkdir":       SkipReason_Symlink,
+		"real/fifo":     SkipReason_Fifo,
+		"real/link.txt": SkipReason_Symlink,
+		"real/loop":     SkipReason_Symlink,
+	}, skipped)
+}
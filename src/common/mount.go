@@ -0,0 +1,81 @@
+package common
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MountInfo describes a mounted filesystem.
+type MountInfo struct {
+	// mount point
+	Path   string
+	FsType string
+}
+
+// Filesystem types which are never walked unless allowed explicitly
+var DefaultExcludedFsTypes = []string{
+	"proc", "sysfs", "devtmpfs", "devpts", "cgroup", "cgroup2", "debugfs", "tracefs",
+	"securityfs", "pstore", "bpf", "configfs", "fusectl", "mqueue", "hugetlbfs",
+	"binfmt_misc", "autofs", "efivarfs", "rpc_pipefs", "nsfs",
+}
+
+var (
+	mountInfoOnce sync.Once
+	// device -> mounted filesystem
+	mountInfos map[uint64]MountInfo
+)
+
+// GetMountInfo returns the filesystem mounted on given device.
+// When it is not available, it will return false.
+func GetMountInfo(dev uint64) (MountInfo, bool) {
+	mountInfoOnce.Do(func() {
+		mountInfos = loadMountInfo()
+	})
+	m, ok := mountInfos[dev]
+	return m, ok
+}
+
+// matchFsType returns true if the filesystem type matches any of the patterns.
+// A pattern is a glob, and also matches subtypes (e.g. "fuse" matches "fuse.sshfs").
+func matchFsType(patterns []string, fsType string) bool {
+	for _, p := range patterns {
+		if p == fsType || strings.HasPrefix(fsType, p+".") {
+			return true
+		}
+		if ok, _ := path.Match(p, fsType); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ------------------------------------------------------------------------------
+
+var (
+	skippedMountsMu sync.Mutex
+	// mount point -> reason
+	skippedMounts = make(map[string]string)
+)
+
+func addSkippedMountPoint(path string, reason string) {
+	skippedMountsMu.Lock()
+	defer skippedMountsMu.Unlock()
+	skippedMounts[path] = reason
+}
+
+// SkippedMountPoints returns mount points skipped while walking directories,
+// with the reason in parentheses.
+func SkippedMountPoints() []string {
+	skippedMountsMu.Lock()
+	defer skippedMountsMu.Unlock()
+
+	points := make([]string, 0, len(skippedMounts))
+	for p, reason := range skippedMounts {
+		points = append(points, fmt.Sprintf("%s (%s)", p, reason))
+	}
+	sort.Strings(points)
+	return points
+}
@@ -0,0 +1,74 @@
+//go:build linux
+
+package common
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// loadMountInfo reads /proc/self/mountinfo.
+func loadMountInfo() map[uint64]MountInfo {
+	mounts := make(map[uint64]MountInfo)
+
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return mounts
+	}
+	// nolint:errcheck
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// ID PARENT_ID MAJOR:MINOR ROOT MOUNT_POINT OPTIONS [OPTIONAL_FIELDS...] - FS_TYPE SOURCE SUPER_OPTIONS
+		fields := strings.Fields(scanner.Text())
+		sep := -1
+		for i, f := range fields {
+			if f == "-" {
+				sep = i
+				break
+			}
+		}
+		if len(fields) < 5 || sep == -1 || sep+1 >= len(fields) {
+			continue
+		}
+
+		major, minor, ok := strings.Cut(fields[2], ":")
+		if !ok {
+			continue
+		}
+		ma, err1 := strconv.ParseUint(major, 10, 32)
+		mi, err2 := strconv.ParseUint(minor, 10, 32)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		mounts[unix.Mkdev(uint32(ma), uint32(mi))] = MountInfo{
+			Path:   unescapeMountPath(fields[4]),
+			FsType: fields[sep+1],
+		}
+	}
+	return mounts
+}
+
+// unescapeMountPath decodes octal escapes (e.g. "\040" for space) in mountinfo.
+func unescapeMountPath(p string) string {
+	if !strings.Contains(p, "\\") {
+		return p
+	}
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		if p[i] == '\\' && i+3 < len(p) {
+			if n, err := strconv.ParseUint(p[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(p[i])
+	}
+	return b.String()
+}
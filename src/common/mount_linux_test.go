@@ -0,0 +1,13 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnescapeMountPath(t *testing.T) {
+	assert.Equal(t, "/mnt/data", unescapeMountPath("/mnt/data"))
+	assert.Equal(t, "/mnt/my disk", unescapeMountPath("/mnt/my\\040disk"))
+	assert.Equal(t, "/mnt/a\\b", unescapeMountPath("/mnt/a\\134b"))
+}
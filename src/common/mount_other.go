@@ -0,0 +1,9 @@
+//go:build !linux
+
+package common
+
+// loadMountInfo returns mounted filesystems.
+// It is supported only on Linux, and always returns no filesystem on other platforms.
+func loadMountInfo() map[uint64]MountInfo {
+	return make(map[uint64]MountInfo)
+}
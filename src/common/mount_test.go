@@ -0,0 +1,52 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchFsType(t *testing.T) {
+	assert.True(t, matchFsType([]string{"nfs"}, "nfs"))
+	assert.True(t, matchFsType([]string{"fuse"}, "fuse.sshfs"))
+	assert.True(t, matchFsType([]string{"nfs*"}, "nfs4"))
+	assert.False(t, matchFsType([]string{"nfs"}, "nfs4"))
+	assert.False(t, matchFsType([]string{"fuse"}, "fuseblk.x"))
+	assert.False(t, matchFsType(nil, "ext4"))
+}
+
+func TestIsWalkedFsType(t *testing.T) {
+	defer SetWalkFilter(WalkFilter{}) // nolint:errcheck
+
+	assert.NoError(t, SetWalkFilter(WalkFilter{}))
+	assert.True(t, walkFilter.isWalkedFsType("ext4"))
+	assert.False(t, walkFilter.isWalkedFsType("proc"))
+	assert.False(t, walkFilter.isWalkedFsType("sysfs"))
+
+	assert.NoError(t, SetWalkFilter(WalkFilter{FsTypeExcludes: []string{"nfs*", "fuse"}}))
+	assert.True(t, walkFilter.isWalkedFsType("ext4"))
+	assert.False(t, walkFilter.isWalkedFsType("nfs4"))
+	assert.False(t, walkFilter.isWalkedFsType("fuse.sshfs"))
+	assert.False(t, walkFilter.isWalkedFsType("proc"))
+
+	// default exclusions are not applied when includes are specified
+	assert.NoError(t, SetWalkFilter(WalkFilter{FsTypeIncludes: []string{"ext4", "proc"}}))
+	assert.True(t, walkFilter.isWalkedFsType("ext4"))
+	assert.True(t, walkFilter.isWalkedFsType("proc"))
+	assert.False(t, walkFilter.isWalkedFsType("xfs"))
+
+	assert.Error(t, SetWalkFilter(WalkFilter{FsTypeExcludes: []string{"["}}))
+}
+
+func TestIsSkippedMountPoint(t *testing.T) {
+	defer SetWalkFilter(WalkFilter{}) // nolint:errcheck
+
+	assert.NoError(t, SetWalkFilter(WalkFilter{OneFileSystem: true}))
+	w := &dirWalker{filter: NewPathFilter("."), rootDev: 1}
+
+	reason, skip := w.isSkippedMountPoint(2)
+	assert.True(t, skip)
+	assert.Equal(t, "other filesystem", reason)
+	_, skip = w.isSkippedMountPoint(1)
+	assert.False(t, skip)
+}
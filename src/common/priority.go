@@ -0,0 +1,61 @@
+package common
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// I/O scheduling classes (see ioprio_set(2))
+type IOPriorityClass uint8
+
+const (
+	IOPriorityClass_BestEffort IOPriorityClass = iota + 2
+	IOPriorityClass_Idle
+)
+
+func (c IOPriorityClass) String() string {
+	switch c {
+	case IOPriorityClass_BestEffort:
+		return "best-effort"
+	case IOPriorityClass_Idle:
+		return "idle"
+	}
+	return "unknown"
+}
+
+// Default priority level of the best-effort class
+const DefaultIOPriorityLevel = 4
+
+// ParseIOPriority parses an I/O priority string in CLASS[:LEVEL] format.
+// CLASS is "idle" or "best-effort", and LEVEL is between 0 (highest) and 7 (lowest).
+// LEVEL is ignored for the idle class.
+//
+//	e.g. "idle", "best-effort:7"
+//
+//	class : IOPriorityClass
+//	level : int
+//	error : error
+func ParseIOPriority(s string) (IOPriorityClass, int, error) {
+	name, levelStr, hasLevel := strings.Cut(strings.ToLower(strings.TrimSpace(s)), ":")
+
+	var class IOPriorityClass
+	switch name {
+	case "idle":
+		return IOPriorityClass_Idle, 0, nil
+	case "best-effort", "be":
+		class = IOPriorityClass_BestEffort
+	default:
+		return 0, 0, fmt.Errorf("invalid I/O priority class : %s", s)
+	}
+
+	level := DefaultIOPriorityLevel
+	if hasLevel {
+		n, err := strconv.Atoi(levelStr)
+		if err != nil || n < 0 || n > 7 {
+			return 0, 0, fmt.Errorf("invalid I/O priority level : %s", s)
+		}
+		level = n
+	}
+	return class, level, nil
+}
@@ -0,0 +1,67 @@
+//go:build linux
+
+package common
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+const ioprioWhoProcess = 1
+const ioprioClassShift = 13
+
+// SetNiceness sets the CPU scheduling priority (nice value) of the current process.
+func SetNiceness(nice int) error {
+	return forEachThread(func(tid int) error {
+		return unix.Setpriority(unix.PRIO_PROCESS, tid, nice)
+	})
+}
+
+// SetIOPriority sets the I/O scheduling priority of the current process.
+func SetIOPriority(class IOPriorityClass, level int) error {
+	prio := int(class)<<ioprioClassShift | level
+	return forEachThread(func(tid int) error {
+		_, _, errno := unix.Syscall(unix.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(tid), uintptr(prio))
+		if errno != 0 {
+			return errno
+		}
+		return nil
+	})
+}
+
+// LoadAverage returns the system load average of the last 1 minute.
+func LoadAverage() (float64, error) {
+	b, err := os.ReadFile("/proc/loadavg")
+	if err != nil {
+		return 0, err
+	}
+	fields := strings.Fields(string(b))
+	if len(fields) == 0 {
+		return 0, os.ErrInvalid
+	}
+	return strconv.ParseFloat(fields[0], 64)
+}
+
+// forEachThread calls fn for all threads of the current process.
+// On Linux, scheduling priorities are attributes of each thread,
+// and threads created later inherit them from the creating thread.
+func forEachThread(fn func(tid int) error) error {
+	entries, err := os.ReadDir("/proc/self/task")
+	if err != nil {
+		return fn(0)
+	}
+	for _, e := range entries {
+		tid, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		// thread may have exited
+		if err := fn(tid); err != nil && err != unix.ESRCH {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+//go:build !linux
+
+package common
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// SetNiceness sets the CPU scheduling priority (nice value) of the current process.
+// It is supported only on Linux.
+func SetNiceness(nice int) error {
+	return fmt.Errorf("setting niceness is not supported on %s", runtime.GOOS)
+}
+
+// SetIOPriority sets the I/O scheduling priority of the current process.
+// It is supported only on Linux.
+func SetIOPriority(class IOPriorityClass, level int) error {
+	return fmt.Errorf("setting I/O priority is not supported on %s", runtime.GOOS)
+}
+
+// LoadAverage returns the system load average of the last 1 minute.
+// It is supported only on Linux.
+func LoadAverage() (float64, error) {
+	return 0, fmt.Errorf("load average is not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,31 @@
+//go:build linux
+
+package common
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// CloneFile creates dst as a reflink (copy-on-write clone) of src with FICLONE ioctl.
+// It is supported only on filesystems such as Btrfs and XFS, and dst must not exist.
+func CloneFile(src string, dst string) error {
+	s, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	// nolint:errcheck
+	defer s.Close()
+
+	d, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	if err := unix.IoctlFileClone(int(d.Fd()), int(s.Fd())); err != nil {
+		d.Close()      // nolint:errcheck
+		os.Remove(dst) // nolint:errcheck
+		return &os.PathError{Op: "clone", Path: dst, Err: err}
+	}
+	return d.Close()
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package common
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// CloneFile creates dst as a reflink (copy-on-write clone) of src.
+// It is supported only on Linux.
+func CloneFile(src string, dst string) error {
+	return fmt.Errorf("reflink is not supported on %s", runtime.GOOS)
+}
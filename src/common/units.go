@@ -0,0 +1,108 @@
+package common
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseDuration parses a duration string.
+// In addition to the format of time.ParseDuration, "d" (day) and "w" (week) units are accepted.
+//
+//	e.g. "30d", "2w", "1d12h", "90m"
+func ParseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	var total time.Duration
+	rest := s
+	for {
+		pos := strings.IndexAny(rest, "dw")
+		if pos == -1 {
+			break
+		}
+		n, err := strconv.ParseFloat(rest[:pos], 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration : %s", s)
+		}
+		unit := 24 * time.Hour
+		if rest[pos] == 'w' {
+			unit *= 7
+		}
+		total += time.Duration(n * float64(unit))
+		rest = rest[pos+1:]
+	}
+	if rest != "" {
+		d, err := time.ParseDuration(rest)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration : %s", s)
+		}
+		total += d
+	}
+	return total, nil
+}
+
+// ParseSize parses a byte size string.
+// Units are binary (1K = 1024 bytes) and "B" or "iB" suffix is optional.
+//
+//	e.g. "512", "100M", "1.5GiB", "2TB"
+func ParseSize(s string) (int64, error) {
+	str := strings.ToUpper(strings.TrimSpace(s))
+	str = strings.TrimSuffix(str, "IB")
+	str = strings.TrimSuffix(str, "B")
+
+	units := map[byte]int64{
+		'K': 1 << 10,
+		'M': 1 << 20,
+		'G': 1 << 30,
+		'T': 1 << 40,
+		'P': 1 << 50,
+	}
+
+	multiplier := int64(1)
+	if len(str) > 0 {
+		if m, ok := units[str[len(str)-1]]; ok {
+			multiplier = m
+			str = str[:len(str)-1]
+		}
+	}
+
+	n, err := strconv.ParseFloat(strings.TrimSpace(str), 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size : %s", s)
+	}
+	return int64(n * float64(multiplier)), nil
+}
+
+// ParseRate parses a transfer rate string in bytes per second.
+// It accepts the same format as ParseSize with an optional "/s" suffix.
+//
+//	e.g. "50MB/s", "512K", "1GiB/s"
+func ParseRate(s string) (int64, error) {
+	str := strings.TrimSpace(s)
+	if strings.HasSuffix(strings.ToLower(str), "/s") {
+		str = str[:len(str)-2]
+	}
+	n, err := ParseSize(str)
+	if err != nil {
+		return 0, fmt.Errorf("invalid rate : %s", s)
+	}
+	return n, nil
+}
+
+// FormatSize formats a byte size in the form which ParseSize accepts.
+// Units are binary and the value is rounded to one decimal place.
+//
+//	e.g. "512B", "1.5KB", "100.0MB"
+func FormatSize(n int64) string {
+	const units = "KMGTP"
+	if n < 1024 {
+		return fmt.Sprintf("%dB", n)
+	}
+	v := float64(n) / 1024
+	i := 0
+	for v >= 1024 && i < len(units)-1 {
+		v /= 1024
+		i++
+	}
+	return fmt.Sprintf("%.1f%cB", v, units[i])
+}
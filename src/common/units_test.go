@@ -0,0 +1,87 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDuration(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected time.Duration
+	}{
+		{"30d", 30 * 24 * time.Hour},
+		{"2w", 14 * 24 * time.Hour},
+		{"1d12h", 36 * time.Hour},
+		{"90m", 90 * time.Minute},
+	}
+	for _, c := range cases {
+		d, err := ParseDuration(c.input)
+		assert.NoError(t, err, c.input)
+		assert.Equal(t, c.expected, d, c.input)
+	}
+
+	_, err := ParseDuration("3x")
+	assert.Error(t, err)
+}
+
+func TestParseSize(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected int64
+	}{
+		{"512", 512},
+		{"100M", 100 * 1024 * 1024},
+		{"1.5GiB", 1536 * 1024 * 1024},
+		{"2TB", 2 * 1024 * 1024 * 1024 * 1024},
+		{"64k", 64 * 1024},
+	}
+	for _, c := range cases {
+		n, err := ParseSize(c.input)
+		assert.NoError(t, err, c.input)
+		assert.Equal(t, c.expected, n, c.input)
+	}
+
+	_, err := ParseSize("abc")
+	assert.Error(t, err)
+}
+
+func TestParseRate(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected int64
+	}{
+		{"50MB/s", 50 * 1024 * 1024},
+		{"512k/S", 512 * 1024},
+		{"1GiB", 1024 * 1024 * 1024},
+	}
+	for _, c := range cases {
+		n, err := ParseRate(c.input)
+		assert.NoError(t, err, c.input)
+		assert.Equal(t, c.expected, n, c.input)
+	}
+
+	_, err := ParseRate("fast/s")
+	assert.Error(t, err)
+}
+
+func TestFormatSize(t *testing.T) {
+	cases := []struct {
+		expected string
+		input    int64
+	}{
+		{"0B", 0},
+		{"512B", 512},
+		{"1.5KB", 1536},
+		{"100.0MB", 100 * 1024 * 1024},
+		{"2.0TB", 2 * 1024 * 1024 * 1024 * 1024},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.expected, FormatSize(c.input))
+		n, err := ParseSize(c.expected)
+		assert.NoError(t, err)
+		assert.Equal(t, c.input, n)
+	}
+}
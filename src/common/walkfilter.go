@@ -0,0 +1,353 @@
+package common
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Name of the file which lists patterns of files ignored under the directory
+const IgnoreFileName = ".hasherignore"
+
+// WalkFilter specifies files to be walked.
+//
+// Patterns are gitignore style globs.
+// A pattern without '/' matches the name of a file or directory at any depth,
+// otherwise it matches the path relative to the walked directory.
+// A pattern ending with '/' matches only directories,
+// "**" matches any number of directories, and "!" negates the pattern.
+//
+// In addition, patterns in .hasherignore files are applied to files under the directory
+// where the file is located, in the same way as .gitignore.
+//
+// Directories on other filesystems are walked unless OneFileSystem is set.
+// Filesystem types are also globs, and a type matches its subtypes as well
+// (e.g. "fuse" matches "fuse.sshfs"). When FsTypeIncludes is not specified,
+// pseudo filesystems in DefaultExcludedFsTypes are never walked.
+type WalkFilter struct {
+	// files or directories matching these patterns are not walked
+	Excludes []string
+	// when specified, only files matching any of these patterns are walked
+	Includes []string
+	// when specified, only filesystems of these types are walked
+	FsTypeIncludes []string
+	// filesystems of these types are not walked
+	FsTypeExcludes []string
+	// files smaller than this are not walked (0 : unlimited)
+	MinSize int64
+	// files larger than this are not walked (0 : unlimited)
+	MaxSize int64
+	// when true, directories on filesystems other than the root's are not walked
+	OneFileSystem bool
+}
+
+type compiledWalkFilter struct {
+	excludes       []*ignoreRule
+	includes       []*ignoreRule
+	fsTypeIncludes []string
+	fsTypeExcludes []string
+	minSize        int64
+	maxSize        int64
+	oneFileSystem  bool
+}
+
+var walkFilter = &compiledWalkFilter{}
+
+// SetWalkFilter sets the filter applied to all directory walks.
+func SetWalkFilter(f WalkFilter) error {
+	c := &compiledWalkFilter{
+		fsTypeIncludes: f.FsTypeIncludes,
+		fsTypeExcludes: f.FsTypeExcludes,
+		minSize:        f.MinSize,
+		maxSize:        f.MaxSize,
+		oneFileSystem:  f.OneFileSystem,
+	}
+	for _, p := range append(append([]string{}, f.FsTypeIncludes...), f.FsTypeExcludes...) {
+		if _, err := path.Match(p, ""); err != nil || p == "" {
+			return fmt.Errorf("invalid filesystem type : %s", p)
+		}
+	}
+	for _, p := range f.Excludes {
+		r, err := parseIgnoreRule(p)
+		if err != nil {
+			return err
+		}
+		if r != nil {
+			c.excludes = append(c.excludes, r)
+		}
+	}
+	for _, p := range f.Includes {
+		r, err := parseIgnoreRule(p)
+		if err != nil {
+			return err
+		}
+		if r != nil {
+			c.includes = append(c.includes, r)
+		}
+	}
+	if f.MaxSize > 0 && f.MinSize > f.MaxSize {
+		return fmt.Errorf("min size is larger than max size")
+	}
+	walkFilter = c
+	return nil
+}
+
+// isWalkedFsType returns true if filesystems of the type are walked.
+func (c *compiledWalkFilter) isWalkedFsType(fsType string) bool {
+	if len(c.fsTypeIncludes) > 0 {
+		if !matchFsType(c.fsTypeIncludes, fsType) {
+			return false
+		}
+	} else if matchFsType(DefaultExcludedFsTypes, fsType) {
+		return false
+	}
+	return !matchFsType(c.fsTypeExcludes, fsType)
+}
+
+// ------------------------------------------------------------------------------
+
+type ignoreRule struct {
+	// elements of the pattern separated by '/'
+	elements []string
+	negate   bool
+	dirOnly  bool
+}
+
+// parseIgnoreRule parses a line of ignore patterns.
+// When the line is blank or a comment, it will return nil.
+func parseIgnoreRule(line string) (*ignoreRule, error) {
+	p := strings.TrimRight(line, " \t\r")
+	if p == "" || strings.HasPrefix(p, "#") {
+		return nil, nil
+	}
+
+	r := &ignoreRule{}
+	if strings.HasPrefix(p, "!") {
+		r.negate = true
+		p = p[1:]
+	} else if strings.HasPrefix(p, "\\!") || strings.HasPrefix(p, "\\#") {
+		p = p[1:]
+	}
+	if strings.HasSuffix(p, "/") {
+		r.dirOnly = true
+		p = strings.TrimRight(p, "/")
+	}
+	if p == "" {
+		return nil, fmt.Errorf("invalid pattern : %s", line)
+	}
+
+	// pattern without '/' matches at any depth
+	anchored := strings.Contains(p, "/")
+	p = strings.TrimPrefix(p, "/")
+	if !anchored {
+		p = "**/" + p
+	}
+
+	r.elements = strings.Split(p, "/")
+	for _, e := range r.elements {
+		if _, err := path.Match(e, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern : %s", line)
+		}
+	}
+	return r, nil
+}
+
+// match returns true if the relative path (separated by '/') matches the rule.
+func (r *ignoreRule) match(relPath string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	return matchElements(r.elements, strings.Split(relPath, "/"))
+}
+
+func matchElements(pattern []string, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			pattern = pattern[1:]
+			if len(pattern) == 0 {
+				// "dir/**" matches everything inside dir
+				return len(name) > 0
+			}
+			for i := 0; i <= len(name); i++ {
+				if matchElements(pattern, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern = pattern[1:]
+		name = name[1:]
+	}
+	return len(name) == 0
+}
+
+// loadIgnoreFile reads rules of the ignore file in the directory.
+// When the file doesn't exist, it will return nil.
+func loadIgnoreFile(dir string) ([]*ignoreRule, error) {
+	f, err := os.Open(filepath.Join(dir, IgnoreFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	// nolint:errcheck
+	defer f.Close()
+
+	rules := make([]*ignoreRule, 0)
+	scanner := bufio.NewScanner(f)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		r, err := parseIgnoreRule(scanner.Text())
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %s", f.Name(), lineNo, err.Error())
+		}
+		if r != nil {
+			rules = append(rules, r)
+		}
+	}
+	return rules, scanner.Err()
+}
+
+// ------------------------------------------------------------------------------
+
+// PathFilter decides whether files under the root directory are walked,
+// with the filter set by SetWalkFilter and .hasherignore files.
+// IsExcluded is safe for concurrent use.
+type PathFilter struct {
+	filter *compiledWalkFilter
+	// directory -> rules of the ignore file (nil : no ignore file)
+	ignoreRules map[string][]*ignoreRule
+	// called with files which are not walked (nil : not notified)
+	onSkip func(path string, reason string)
+	root   string
+	mu     sync.Mutex
+}
+
+func NewPathFilter(root string) *PathFilter {
+	return &PathFilter{
+		filter:      walkFilter,
+		ignoreRules: make(map[string][]*ignoreRule),
+		root:        root,
+	}
+}
+
+// IsExcluded returns true if the file or directory should not be walked.
+// The root directory itself is never excluded.
+// It assumes that ancestors of the path are not excluded.
+func (f *PathFilter) IsExcluded(p string, d fs.DirEntry) bool {
+	p = filepath.Clean(p)
+	rel, err := filepath.Rel(f.root, p)
+	if err != nil || rel == "." {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+	isDir := d.IsDir()
+
+	// later rules override earlier ones
+	excluded := false
+	for _, r := range f.filter.excludes {
+		if r.match(rel, isDir) {
+			excluded = !r.negate
+		}
+	}
+	elements := strings.Split(rel, "/")
+	dir := f.root
+	for i := range elements {
+		for _, r := range f.getIgnoreRules(dir) {
+			if r.match(strings.Join(elements[i:], "/"), isDir) {
+				excluded = !r.negate
+			}
+		}
+		dir = filepath.Join(dir, elements[i])
+	}
+	if excluded {
+		return true
+	}
+	if isDir {
+		return false
+	}
+
+	if len(f.filter.includes) > 0 {
+		included := false
+		for _, r := range f.filter.includes {
+			if r.match(rel, false) {
+				included = true
+				break
+			}
+		}
+		if !included {
+			return true
+		}
+	}
+
+	if f.filter.minSize > 0 || f.filter.maxSize > 0 {
+		info, err := d.Info()
+		if err != nil {
+			return false
+		}
+		if info.Size() < f.filter.minSize || (f.filter.maxSize > 0 && info.Size() > f.filter.maxSize) {
+			return true
+		}
+	}
+	return false
+}
+
+// OnSkip sets the function called with files and directories which are not walked,
+// and the reason (SkipReason_*).
+func (f *PathFilter) OnSkip(fn func(path string, reason string)) {
+	f.onSkip = fn
+}
+
+func (f *PathFilter) skip(path string, reason string) {
+	if f.onSkip != nil {
+		f.onSkip(path, reason)
+	}
+}
+
+func (f *PathFilter) getIgnoreRules(dir string) []*ignoreRule {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	rules, ok := f.ignoreRules[dir]
+	if ok {
+		return rules
+	}
+	rules, err := loadIgnoreFile(dir)
+	if err != nil {
+		ShowWarn("Failed to read ignore file : %s", err.Error())
+	}
+	f.ignoreRules[dir] = rules
+	return rules
+}
+
+// WalkDirFiltered walks the directory in the same way as filepath.WalkDir,
+// except that files and directories excluded by the PathFilter are skipped.
+// Symbolic links are followed only when it is enabled by SetFollowSymlinks.
+func WalkDirFiltered(root string, fn fs.WalkDirFunc) error {
+	return NewPathFilter(root).WalkDir(fn)
+}
+
+// WalkDir walks the root directory of the filter.
+// See WalkDirFiltered.
+func (f *PathFilter) WalkDir(fn fs.WalkDirFunc) error {
+	w := &dirWalker{
+		filter:    f,
+		fn:        fn,
+		ancestors: make(map[FileId]bool),
+		follow:    followSymlinks,
+	}
+	return w.walkRoot(f.root)
+}
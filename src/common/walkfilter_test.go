@@ -0,0 +1,100 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func makeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		assert.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		assert.NoError(t, os.WriteFile(p, []byte(content), 0o644))
+	}
+}
+
+func walkFiltered(t *testing.T, dir string, f WalkFilter) []string {
+	t.Helper()
+	assert.NoError(t, SetWalkFilter(f))
+	t.Cleanup(func() { SetWalkFilter(WalkFilter{}) }) // nolint:errcheck
+
+	paths := make([]string, 0)
+	err := WalkDir(dir, func(file *os.File) error {
+		rel, _ := filepath.Rel(dir, file.Name())
+		paths = append(paths, filepath.ToSlash(rel))
+		return nil
+	})
+	assert.NoError(t, err)
+	return paths
+}
+
+func TestWalkDirFiltered_exclude(t *testing.T) {
+	dir := t.TempDir()
+	makeFiles(t, dir, map[string]string{
+		"a.txt":                 "a",
+		"b.tmp":                 "b",
+		"keep.tmp":              "k",
+		".git/config":           "c",
+		"src/node_modules/x.js": "x",
+		"src/main.go":           "m",
+		"build/out.bin":         "o",
+		"src/build/gen.go":      "g",
+	})
+
+	paths := walkFiltered(t, dir, WalkFilter{Excludes: []string{"*.tmp", "!keep.tmp", ".git/", "node_modules", "/build"}})
+	assert.Equal(t, []string{"a.txt", "keep.tmp", "src/build/gen.go", "src/main.go"}, paths)
+}
+
+func TestWalkDirFiltered_include(t *testing.T) {
+	dir := t.TempDir()
+	makeFiles(t, dir, map[string]string{
+		"a.jpg":       "a",
+		"b.txt":       "b",
+		"sub/c.jpg":   "c",
+		"sub/d/e.jpg": "e",
+	})
+
+	paths := walkFiltered(t, dir, WalkFilter{Includes: []string{"*.jpg"}, Excludes: []string{"sub/**/e.jpg"}})
+	assert.Equal(t, []string{"a.jpg", "sub/c.jpg"}, paths)
+}
+
+func TestWalkDirFiltered_size(t *testing.T) {
+	dir := t.TempDir()
+	makeFiles(t, dir, map[string]string{
+		"small":  "1",
+		"medium": "12345",
+		"large":  "1234567890",
+	})
+
+	paths := walkFiltered(t, dir, WalkFilter{MinSize: 2, MaxSize: 5})
+	assert.Equal(t, []string{"medium"}, paths)
+}
+
+func TestWalkDirFiltered_ignoreFile(t *testing.T) {
+	dir := t.TempDir()
+	makeFiles(t, dir, map[string]string{
+		IgnoreFileName:            "# comment\n*.log\n",
+		"a.log":                   "a",
+		"a.txt":                   "a",
+		"sub/" + IgnoreFileName:   "/cache/\n!keep.log\n",
+		"sub/keep.log":            "k",
+		"sub/x.log":               "x",
+		"sub/cache/c.txt":         "c",
+		"sub/deep/cache/d.txt":    "d",
+		"other/" + IgnoreFileName: "",
+		"other/o.txt":             "o",
+	})
+
+	paths := walkFiltered(t, dir, WalkFilter{})
+	assert.Equal(t, []string{IgnoreFileName, "a.txt", "other/" + IgnoreFileName, "other/o.txt",
+		"sub/" + IgnoreFileName, "sub/deep/cache/d.txt", "sub/keep.log"}, paths)
+}
+
+func TestSetWalkFilter_invalid(t *testing.T) {
+	assert.Error(t, SetWalkFilter(WalkFilter{Excludes: []string{"[a-"}}))
+	assert.Error(t, SetWalkFilter(WalkFilter{MinSize: 10, MaxSize: 5}))
+}
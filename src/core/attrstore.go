@@ -0,0 +1,194 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+
+	"github.com/pkg/errors"
+	"github.com/pkg/xattr"
+)
+
+// AttrStore is a storage backend of hash attributes (user.hasher.*).
+type AttrStore interface {
+	// Get returns the attribute value. When it doesn't exist, it returns empty string.
+	Get(file *os.File, attrName string) string
+	Set(file *os.File, attrName string, value string) error
+	Remove(file *os.File, attrName string) error
+	// Clear removes all hash attributes of the file.
+	Clear(file *os.File) error
+	// Flush writes pending changes.
+	Flush() error
+}
+
+const (
+	AttrStore_Xattr   = "xattr"
+	AttrStore_Sidecar = "sidecar"
+	AttrStore_Auto    = "auto"
+)
+
+var attrStore AttrStore = &XattrStore{}
+
+// NewAttrStore returns AttrStore by it's name.
+func NewAttrStore(name string) (AttrStore, error) {
+	switch name {
+	case AttrStore_Xattr:
+		return &XattrStore{}, nil
+	case AttrStore_Sidecar:
+		return NewSidecarStore(), nil
+	case AttrStore_Auto:
+		return NewAutoStore(), nil
+	}
+	return nil, fmt.Errorf("unknown attribute store : %s", name)
+}
+
+// SetAttrStore changes the storage backend used by all hash operations.
+// Pending changes of the current backend are flushed.
+func SetAttrStore(s AttrStore) error {
+	if err := attrStore.Flush(); err != nil {
+		return err
+	}
+	attrStore = s
+	return nil
+}
+
+func GetAttr(file *os.File, attrName string) string {
+	return attrStore.Get(file, attrName)
+}
+
+func SetAttr(file *os.File, attrName string, value string) error {
+	return attrStore.Set(file, attrName, value)
+}
+
+func RemoveAttr(file *os.File, attrName string) error {
+	return attrStore.Remove(file, attrName)
+}
+
+func ClearAttr(file *os.File) error {
+	return attrStore.Clear(file)
+}
+
+func FlushAttrStore() error {
+	return attrStore.Flush()
+}
+
+// ------------------------------------------------------------------------------
+
+// XattrStore stores attributes to extended attributes of each file.
+type XattrStore struct {
+}
+
+func (s *XattrStore) Get(file *os.File, attrName string) string {
+	return GetXattr(file, attrName)
+}
+
+func (s *XattrStore) Set(file *os.File, attrName string, value string) error {
+	return SetXattr(file, attrName, value)
+}
+
+func (s *XattrStore) Remove(file *os.File, attrName string) error {
+	return RemoveXattr(file, attrName)
+}
+
+func (s *XattrStore) Clear(file *os.File) error {
+	return ClearXattr(file)
+}
+
+func (s *XattrStore) Flush() error {
+	return nil
+}
+
+// ------------------------------------------------------------------------------
+
+// AutoStore stores attributes to extended attributes.
+// In directories where extended attributes are not supported,
+// attributes are stored to sidecar files instead.
+type AutoStore struct {
+	xattr   *XattrStore
+	sidecar *SidecarStore
+	// directories where extended attributes are not supported
+	fallbackDirs map[string]bool
+	mu           sync.Mutex
+}
+
+func NewAutoStore() *AutoStore {
+	return &AutoStore{
+		xattr:        &XattrStore{},
+		sidecar:      NewSidecarStore(),
+		fallbackDirs: make(map[string]bool),
+	}
+}
+
+func (s *AutoStore) Get(file *os.File, attrName string) string {
+	if xattr.XATTR_SUPPORTED {
+		if v := s.xattr.Get(file, attrName); v != "" {
+			return v
+		}
+	}
+	return s.sidecar.Get(file, attrName)
+}
+
+func (s *AutoStore) Set(file *os.File, attrName string, value string) error {
+	if s.isFallback(file) {
+		return s.sidecar.Set(file, attrName, value)
+	}
+
+	err := s.xattr.Set(file, attrName, value)
+	if err != nil && isXattrUnsupported(err) {
+		s.markFallback(file)
+		return s.sidecar.Set(file, attrName, value)
+	}
+	return err
+}
+
+func (s *AutoStore) Remove(file *os.File, attrName string) error {
+	if !s.isFallback(file) {
+		if err := s.xattr.Remove(file, attrName); err != nil && !isXattrNotFound(err) && !isXattrUnsupported(err) {
+			return err
+		}
+	}
+	return s.sidecar.Remove(file, attrName)
+}
+
+func (s *AutoStore) Clear(file *os.File) error {
+	if !s.isFallback(file) {
+		if err := s.xattr.Clear(file); err != nil && !isXattrUnsupported(err) {
+			return err
+		}
+	}
+	return s.sidecar.Clear(file)
+}
+
+func (s *AutoStore) Flush() error {
+	return s.sidecar.Flush()
+}
+
+func (s *AutoStore) isFallback(file *os.File) bool {
+	if !xattr.XATTR_SUPPORTED {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fallbackDirs[filepath.Dir(file.Name())]
+}
+
+func (s *AutoStore) markFallback(file *os.File) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fallbackDirs[filepath.Dir(file.Name())] = true
+}
+
+// Returns true if the error means that the filesystem doesn't support extended attributes.
+func isXattrUnsupported(err error) bool {
+	return errors.Is(err, syscall.ENOTSUP) || errors.Is(err, syscall.EOPNOTSUPP) || errors.Is(err, syscall.ENOSYS)
+}
+
+func isXattrNotFound(err error) bool {
+	return errors.Is(err, xattr.ENOATTR)
+}
+
+// check implementation
+var _ AttrStore = &XattrStore{}
+var _ AttrStore = &AutoStore{}
@@ -0,0 +1,523 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/little-forest/hasher/common" // nolint:staticcheck
+	bolt "go.etcd.io/bbolt"
+)
+
+// Number of entries written to the catalog in a single transaction
+const catalogBatchSize = 1000
+
+// bbolt meta page magic number
+const catalogMagic = 0xED0CDAED
+
+var (
+	catalogBucket_files  = []byte("files")
+	catalogBucket_inodes = []byte("inodes")
+	catalogBucket_hashes = []byte("hashes")
+)
+
+// Catalog is a persistent database of hash values.
+// It keeps hash values of files even after their disk is unmounted.
+//
+// Buckets:
+//
+//	files  : PATH -> CatalogEntry (JSON)
+//	inodes : DEV:INO -> PATH
+//	hashes : ALG:VALUE \x00 PATH -> (empty)
+type Catalog struct {
+	db      *bolt.DB
+	pending []*CatalogEntry
+	mu      sync.Mutex
+}
+
+type CatalogEntry struct {
+	// algorithm name -> hash value
+	Hashes map[string]string `json:"hashes"`
+	Path   string            `json:"path"`
+	Dev    uint64            `json:"dev"`
+	Ino    uint64            `json:"ino"`
+	Size   int64             `json:"size"`
+	// modified time (UNIX time nano)
+	ModTime int64 `json:"mtime"`
+	// hash checked time (UNIX time nano)
+	CheckedTime int64 `json:"htime"`
+}
+
+// Hash returns the hash value of given algorithm.
+// When the entry doesn't have it, it will return nil.
+func (e CatalogEntry) Hash(alg *HashAlg) *Hash {
+	v, ok := e.Hashes[alg.AlgName]
+	if !ok {
+		return nil
+	}
+	h, err := NewHashFromString(e.Path, alg, v, time.Unix(0, e.ModTime).Unix())
+	if err != nil {
+		return nil
+	}
+	h.ModTimeNano = e.ModTime
+	h.Size = e.Size
+	h.CheckedTime = e.CheckedTime
+	return h
+}
+
+var (
+	catalog *Catalog
+	// path of the catalog which is opened when hash values are recorded first
+	catalogPath string
+	catalogMu   sync.Mutex
+)
+
+// SetCatalog sets the catalog where the hash values are recorded when they are updated.
+// Specify nil to stop recording.
+func SetCatalog(c *Catalog) {
+	catalogMu.Lock()
+	defer catalogMu.Unlock()
+	catalog = c
+	catalogPath = ""
+}
+
+// SetCatalogPath sets the catalog where the hash values are recorded when they are updated.
+// The catalog is not opened until hash values are recorded first,
+// since opening it for writing locks out other processes.
+// Specify "" to stop recording.
+func SetCatalogPath(path string) {
+	catalogMu.Lock()
+	defer catalogMu.Unlock()
+	catalog = nil
+	catalogPath = path
+}
+
+// GetCatalog returns the catalog where the hash values are recorded.
+// It returns nil until the catalog is opened.
+func GetCatalog() *Catalog {
+	catalogMu.Lock()
+	defer catalogMu.Unlock()
+	return catalog
+}
+
+// GetCatalogPath returns the path of the catalog where the hash values are recorded,
+// whether it has been opened or not.
+// If recording is disabled, it returns "".
+func GetCatalogPath() string {
+	catalogMu.Lock()
+	defer catalogMu.Unlock()
+	if catalog != nil {
+		return catalog.Path()
+	}
+	return catalogPath
+}
+
+// activeCatalog returns the catalog where the hash values are recorded,
+// opening it if it has not been opened yet.
+// If recording is disabled, it returns nil.
+func activeCatalog() (*Catalog, error) {
+	catalogMu.Lock()
+	defer catalogMu.Unlock()
+	if catalog == nil && catalogPath != "" {
+		c, err := OpenCatalog(catalogPath)
+		if err != nil {
+			return nil, err
+		}
+		catalog = c
+	}
+	return catalog, nil
+}
+
+// OpenCatalog opens the catalog for writing.
+// It is locked exclusively until closed, and the database is created if it doesn't exist.
+func OpenCatalog(path string) (*Catalog, error) {
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open catalog : %s (%s)", path, err.Error())
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, b := range [][]byte{catalogBucket_files, catalogBucket_inodes, catalogBucket_hashes} {
+			if _, e := tx.CreateBucketIfNotExists(b); e != nil {
+				return e
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close() // nolint:errcheck
+		return nil, err
+	}
+
+	return &Catalog{db: db}, nil
+}
+
+// OpenCatalogReadOnly opens the catalog only for queries.
+// Unlike OpenCatalog, it can be opened by multiple processes at the same time.
+func OpenCatalogReadOnly(path string) (*Catalog, error) {
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: time.Second, ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open catalog : %s (%s)", path, err.Error())
+	}
+
+	err = db.View(func(tx *bolt.Tx) error {
+		for _, b := range [][]byte{catalogBucket_files, catalogBucket_inodes, catalogBucket_hashes} {
+			if tx.Bucket(b) == nil {
+				return fmt.Errorf("not a catalog : %s", path)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close() // nolint:errcheck
+		return nil, err
+	}
+
+	return &Catalog{db: db}, nil
+}
+
+// Path returns the file path of the catalog database.
+func (c *Catalog) Path() string {
+	return c.db.Path()
+}
+
+// IsCatalogFile returns true if given file is a catalog database.
+func IsCatalogFile(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	// nolint:errcheck
+	defer f.Close()
+
+	// page header (16 bytes) followed by meta magic
+	header := make([]byte, 20)
+	if _, err := io.ReadFull(f, header); err != nil {
+		return false
+	}
+	return binary.LittleEndian.Uint32(header[16:]) == catalogMagic
+}
+
+// Close writes pending entries and closes the catalog.
+func (c *Catalog) Close() error {
+	err := c.Flush()
+	if closeErr := c.db.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Record records hash values of the file.
+// The checked time is taken from the hashes, or the current time if it is not set.
+// Entries are written in batches, call Flush() or Close() to write them.
+func (c *Catalog) Record(file *os.File, hashes []*Hash) error {
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	absPath, err := filepath.Abs(file.Name())
+	if err != nil {
+		return err
+	}
+
+	entry := &CatalogEntry{
+		Path:    absPath,
+		Size:    info.Size(),
+		ModTime: info.ModTime().UnixNano(),
+		Hashes:  make(map[string]string, len(hashes)),
+	}
+	if id, ok := GetFileId(info); ok {
+		entry.Dev = id.Dev
+		entry.Ino = id.Ino
+	}
+	for _, h := range hashes {
+		entry.Hashes[h.Alg.AlgName] = h.String()
+		if entry.CheckedTime == 0 {
+			entry.CheckedTime = h.CheckedTime
+		}
+	}
+	if entry.CheckedTime == 0 {
+		entry.CheckedTime = time.Now().UTC().UnixNano()
+	}
+
+	c.mu.Lock()
+	c.pending = append(c.pending, entry)
+	flush := len(c.pending) >= catalogBatchSize
+	c.mu.Unlock()
+
+	if flush {
+		return c.Flush()
+	}
+	return nil
+}
+
+// Flush writes pending entries.
+func (c *Catalog) Flush() error {
+	c.mu.Lock()
+	entries := c.pending
+	c.pending = nil
+	c.mu.Unlock()
+
+	if len(entries) == 0 {
+		return nil
+	}
+
+	return c.db.Update(func(tx *bolt.Tx) error {
+		for _, e := range entries {
+			if err := c.put(tx, e); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (c *Catalog) put(tx *bolt.Tx, entry *CatalogEntry) error {
+	files := tx.Bucket(catalogBucket_files)
+	hashes := tx.Bucket(catalogBucket_hashes)
+
+	// merge hash values of other algorithms while the file is unchanged
+	if old := c.getEntry(tx, entry.Path); old != nil {
+		if old.Size == entry.Size && old.ModTime == entry.ModTime {
+			for alg, v := range old.Hashes {
+				if _, ok := entry.Hashes[alg]; !ok {
+					entry.Hashes[alg] = v
+				}
+			}
+		}
+		for alg, v := range old.Hashes {
+			if err := hashes.Delete(catalogHashKey(alg, v, old.Path)); err != nil {
+				return err
+			}
+		}
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err := files.Put([]byte(entry.Path), data); err != nil {
+		return err
+	}
+	for alg, v := range entry.Hashes {
+		if err := hashes.Put(catalogHashKey(alg, v, entry.Path), []byte{}); err != nil {
+			return err
+		}
+	}
+	if entry.Ino != 0 {
+		id := FileId{Dev: entry.Dev, Ino: entry.Ino}
+		if err := tx.Bucket(catalogBucket_inodes).Put([]byte(id.String()), []byte(entry.Path)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Catalog) getEntry(tx *bolt.Tx, path string) *CatalogEntry {
+	data := tx.Bucket(catalogBucket_files).Get([]byte(path))
+	if data == nil {
+		return nil
+	}
+	entry := &CatalogEntry{}
+	if err := json.Unmarshal(data, entry); err != nil {
+		return nil
+	}
+	return entry
+}
+
+func catalogHashKey(algName string, value string, path string) []byte {
+	return []byte(algName + ":" + value + "\x00" + path)
+}
+
+// Get returns the entry of given path.
+// When the path is not recorded, it will return nil.
+func (c *Catalog) Get(path string) (*CatalogEntry, error) {
+	if err := c.Flush(); err != nil {
+		return nil, err
+	}
+
+	var entry *CatalogEntry
+	err := c.db.View(func(tx *bolt.Tx) error {
+		entry = c.getEntry(tx, path)
+		return nil
+	})
+	return entry, err
+}
+
+// GetByFileId returns the entry of given device and inode number.
+// When it is not recorded, it will return nil.
+func (c *Catalog) GetByFileId(id FileId) (*CatalogEntry, error) {
+	if err := c.Flush(); err != nil {
+		return nil, err
+	}
+
+	var entry *CatalogEntry
+	err := c.db.View(func(tx *bolt.Tx) error {
+		path := tx.Bucket(catalogBucket_inodes).Get([]byte(id.String()))
+		if path != nil {
+			entry = c.getEntry(tx, string(path))
+		}
+		return nil
+	})
+	return entry, err
+}
+
+// FindByHash returns entries which have the same hash value as given hash.
+func (c *Catalog) FindByHash(hash *Hash) ([]*CatalogEntry, error) {
+	if err := c.Flush(); err != nil {
+		return nil, err
+	}
+
+	entries := make([]*CatalogEntry, 0)
+	err := c.db.View(func(tx *bolt.Tx) error {
+		prefix := []byte(hash.Alg.AlgName + ":" + hash.String() + "\x00")
+		cur := tx.Bucket(catalogBucket_hashes).Cursor()
+		for k, _ := cur.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = cur.Next() {
+			if e := c.getEntry(tx, string(k[len(prefix):])); e != nil {
+				entries = append(entries, e)
+			}
+		}
+		return nil
+	})
+	return entries, err
+}
+
+// Walk calls fn for each entry under given path prefix in order of path.
+// When prefix is empty, all entries are visited.
+func (c *Catalog) Walk(prefix string, fn func(entry *CatalogEntry) error) error {
+	if err := c.Flush(); err != nil {
+		return err
+	}
+
+	return c.db.View(func(tx *bolt.Tx) error {
+		cur := tx.Bucket(catalogBucket_files).Cursor()
+		for k, v := cur.Seek([]byte(prefix)); k != nil && bytes.HasPrefix(k, []byte(prefix)); k, v = cur.Next() {
+			entry := &CatalogEntry{}
+			if err := json.Unmarshal(v, entry); err != nil {
+				return fmt.Errorf("broken catalog entry : %s", string(k))
+			}
+			if err := fn(entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// AppendHashDataFromCatalog appends all hash values of given algorithm in the catalog.
+func (s *HashStore) AppendHashDataFromCatalog(c *Catalog, alg *HashAlg) error {
+	return c.Walk("", func(entry *CatalogEntry) error {
+		if h := entry.Hash(alg); h != nil {
+			s.Put(h)
+		}
+		return nil
+	})
+}
+
+// Remove removes entries under given path prefix.
+//
+//	number of removed entries : int
+func (c *Catalog) Remove(prefix string) (int, error) {
+	if err := c.Flush(); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	err := c.db.Update(func(tx *bolt.Tx) error {
+		paths := make([]string, 0)
+		cur := tx.Bucket(catalogBucket_files).Cursor()
+		for k, _ := cur.Seek([]byte(prefix)); k != nil && bytes.HasPrefix(k, []byte(prefix)); k, _ = cur.Next() {
+			paths = append(paths, string(k))
+		}
+		for _, p := range paths {
+			entry := c.getEntry(tx, p)
+			if entry != nil {
+				for alg, v := range entry.Hashes {
+					if err := tx.Bucket(catalogBucket_hashes).Delete(catalogHashKey(alg, v, p)); err != nil {
+						return err
+					}
+				}
+				if entry.Ino != 0 {
+					id := []byte(FileId{Dev: entry.Dev, Ino: entry.Ino}.String())
+					inodes := tx.Bucket(catalogBucket_inodes)
+					if string(inodes.Get(id)) == p {
+						if err := inodes.Delete(id); err != nil {
+							return err
+						}
+					}
+				}
+			}
+			if err := tx.Bucket(catalogBucket_files).Delete([]byte(p)); err != nil {
+				return err
+			}
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// Diff compares files recorded under two directories.
+// Files are paired by relative path, and the Basename of each FileDiff is the relative path.
+// The result is sorted by relative path.
+func (c *Catalog) Diff(baseDir string, targetDir string, alg *HashAlg) ([]*FileDiff, error) {
+	baseDir = normalizeDirPath(baseDir)
+	targetDir = normalizeDirPath(targetDir)
+
+	collect := func(dir string) (map[string]*FileDiff, error) {
+		files := make(map[string]*FileDiff)
+		err := c.Walk(dir, func(entry *CatalogEntry) error {
+			h := entry.Hash(alg)
+			if h == nil {
+				return nil
+			}
+			relPath := strings.TrimPrefix(entry.Path, dir)
+			files[relPath] = &FileDiff{
+				Basename:  relPath,
+				HashValue: h.Value,
+				ModTime:   time.Unix(0, entry.ModTime),
+				Status:    UNKNOWN,
+			}
+			return nil
+		})
+		return files, err
+	}
+
+	baseFiles, err := collect(baseDir)
+	if err != nil {
+		return nil, err
+	}
+	targetFiles, err := collect(targetDir)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*FileDiff, 0, len(baseFiles))
+	for relPath, bf := range baseFiles {
+		if tf, ok := targetFiles[relPath]; ok {
+			bf.Compare(tf)
+		} else {
+			bf.Status = ADDED
+		}
+		result = append(result, bf)
+	}
+	for relPath, tf := range targetFiles {
+		if _, ok := baseFiles[relPath]; !ok {
+			tf.Status = REMOVED
+			result = append(result, tf)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Basename < result[j].Basename
+	})
+	return result, nil
+}
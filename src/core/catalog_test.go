@@ -0,0 +1,169 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/little-forest/hasher/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func useCatalog(t *testing.T) *Catalog {
+	t.Helper()
+
+	c, err := OpenCatalog(filepath.Join(t.TempDir(), "catalog.db"))
+	assert.NoError(t, err)
+	SetCatalog(c)
+	t.Cleanup(func() {
+		SetCatalog(nil)
+		c.Close() // nolint:errcheck
+	})
+	return c
+}
+
+func TestCatalog_Record(t *testing.T) {
+	c := useCatalog(t)
+	alg := NewDefaultHashAlg()
+
+	dir := t.TempDir()
+	path1 := filepath.Join(dir, "file1.txt")
+	path2 := filepath.Join(dir, "file2.txt")
+	hash1 := makeDummyFile(t, path1, alg)
+	makeDummyFile(t, path2, alg)
+
+	for _, p := range []string{path1, path2} {
+		_, _, err := UpdateHash(p, alg, false)
+		assert.NoError(t, err)
+	}
+
+	assert.True(t, IsCatalogFile(c.Path()))
+	assert.False(t, IsCatalogFile(path1))
+
+	entry, err := c.Get(path1)
+	assert.NoError(t, err)
+	if assert.NotNil(t, entry) {
+		assert.Equal(t, hash1, entry.Hashes[alg.AlgName])
+		info, _ := os.Stat(path1)
+		assert.Equal(t, info.Size(), entry.Size)
+
+		// checked time is the one stored in the file, not the time of recording
+		f1, openErr := os.Open(path1)
+		assert.NoError(t, openErr)
+		htime, ok := GetHashCheckedTime(f1)
+		f1.Close() // nolint:errcheck
+		assert.True(t, ok)
+		assert.Equal(t, htime.UnixNano(), entry.CheckedTime)
+	}
+
+	h, err := NewHashFromString(path1, alg, hash1, 0)
+	assert.NoError(t, err)
+	found, err := c.FindByHash(h)
+	assert.NoError(t, err)
+	if assert.Equal(t, 1, len(found)) {
+		assert.Equal(t, path1, found[0].Path)
+	}
+
+	paths := make([]string, 0)
+	err = c.Walk(dir+string(filepath.Separator), func(entry *CatalogEntry) error {
+		paths = append(paths, entry.Path)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{path1, path2}, paths)
+
+	// hashes without checked time are recorded with the current time
+	f, err := os.Open(path2)
+	assert.NoError(t, err)
+	defer f.Close() // nolint:errcheck
+	h2, err := NewHashFromString(path2, alg, hash1, 0)
+	assert.NoError(t, err)
+	before := time.Now().UnixNano()
+	assert.NoError(t, c.Record(f, []*Hash{h2}))
+	assert.NoError(t, c.Flush())
+	entry, err = c.Get(path2)
+	assert.NoError(t, err)
+	if assert.NotNil(t, entry) {
+		assert.GreaterOrEqual(t, entry.CheckedTime, before)
+	}
+}
+
+func TestCatalog_Diff(t *testing.T) {
+	c := useCatalog(t)
+	alg := NewDefaultHashAlg()
+
+	baseDir := t.TempDir()
+	targetDir := t.TempDir()
+	makeDummyFile(t, filepath.Join(baseDir, "same.txt"), alg)
+	copyFile(t, baseDir, targetDir, "same.txt")
+	makeDummyFile(t, filepath.Join(baseDir, "added.txt"), alg)
+	makeDummyFile(t, filepath.Join(targetDir, "removed.txt"), alg)
+
+	for _, d := range []string{baseDir, targetDir} {
+		assert.NoError(t, common.WalkDirs([]string{d}, func(f *os.File) error {
+			_, _, err := UpdateHash(f.Name(), alg, false)
+			return err
+		}))
+	}
+
+	diffs, err := c.Diff(baseDir, targetDir, alg)
+	assert.NoError(t, err)
+	if assert.Equal(t, 3, len(diffs)) {
+		assert.Equal(t, "added.txt", diffs[0].Basename)
+		assert.Equal(t, ADDED, diffs[0].Status)
+		assert.Equal(t, "removed.txt", diffs[1].Basename)
+		assert.Equal(t, REMOVED, diffs[1].Status)
+		assert.Equal(t, "same.txt", diffs[2].Basename)
+		assert.Equal(t, SAME, diffs[2].Status)
+	}
+
+	count, err := c.Remove(targetDir + string(filepath.Separator))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	entry, err := c.Get(filepath.Join(targetDir, "same.txt"))
+	assert.NoError(t, err)
+	assert.Nil(t, entry)
+}
+
+func TestCatalog_openLazily(t *testing.T) {
+	alg := NewDefaultHashAlg()
+	catalogPath := filepath.Join(t.TempDir(), "catalog.db")
+	SetCatalogPath(catalogPath)
+	t.Cleanup(func() {
+		if c := GetCatalog(); c != nil {
+			c.Close() // nolint:errcheck
+		}
+		SetCatalogPath("")
+	})
+
+	// not opened until hash values are recorded
+	assert.Nil(t, GetCatalog())
+	assert.Equal(t, catalogPath, GetCatalogPath())
+	assert.NoFileExists(t, catalogPath)
+
+	path := filepath.Join(t.TempDir(), "file.txt")
+	makeDummyFile(t, path, alg)
+	_, hash, err := UpdateHash(path, alg, false)
+	assert.NoError(t, err)
+	c := GetCatalog()
+	if assert.NotNil(t, c) {
+		assert.NoError(t, c.Close())
+	}
+	SetCatalogPath(catalogPath)
+
+	// read-only catalogs can be opened at the same time
+	c1, err := OpenCatalogReadOnly(catalogPath)
+	assert.NoError(t, err)
+	// nolint:errcheck
+	defer c1.Close()
+	c2, err := OpenCatalogReadOnly(catalogPath)
+	assert.NoError(t, err)
+	// nolint:errcheck
+	defer c2.Close()
+
+	entries, err := c2.FindByHash(hash)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(entries))
+}
@@ -0,0 +1,296 @@
+package core
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	. "github.com/little-forest/hasher/common" // nolint:staticcheck
+)
+
+// Output formats of hash values
+const (
+	// hash file format (see hash.go)
+	HashFormat_Tsv = "tsv"
+	// sha256sum compatible format : VALUE  PATH
+	HashFormat_Coreutils = "coreutils"
+	// BSD style format : ALG (PATH) = VALUE
+	HashFormat_Bsd = "bsd"
+)
+
+// HashFormatNames returns names of all output formats.
+func HashFormatNames() []string {
+	return []string{HashFormat_Tsv, HashFormat_Coreutils, HashFormat_Bsd}
+}
+
+// CheckHashFormat returns an error when hash values of given algorithms can't be written in the format.
+// In addition to the formats above, JSON output formats are accepted.
+func CheckHashFormat(format string, algs []*HashAlg) error {
+	switch format {
+	case HashFormat_Tsv, HashFormat_Bsd, OutputFormat_Json, OutputFormat_Ndjson:
+		return nil
+	case HashFormat_Coreutils:
+		if len(algs) > 1 {
+			return fmt.Errorf("%s format doesn't support multiple hash algorithms", format)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown format : %s", format)
+}
+
+// FormatHashes returns lines of given hash values of the same file.
+// path is used as the file path for the coreutils and bsd formats,
+// while the tsv format uses Path of the hash values.
+func FormatHashes(format string, path string, hashes []*Hash) string {
+	switch format {
+	case HashFormat_Coreutils:
+		prefix, p := escapeChecksumPath(path)
+		return fmt.Sprintf("%s%s  %s", prefix, hashes[0].String(), p)
+	case HashFormat_Bsd:
+		prefix, p := escapeChecksumPath(path)
+		lines := make([]string, len(hashes))
+		for i, h := range hashes {
+			lines[i] = fmt.Sprintf("%s%s (%s) = %s", prefix, bsdTag(h.Alg), p, h.String())
+		}
+		return strings.Join(lines, "\n")
+	}
+	return HashesTsv(hashes)
+}
+
+func bsdTag(alg *HashAlg) string {
+	return strings.ToUpper(alg.AlgName)
+}
+
+// escapeChecksumPath escapes the path in the same way as coreutils.
+// When the path contains backslash or newline, the line starts with a backslash.
+//
+//	line prefix : string
+//	escaped path : string
+func escapeChecksumPath(path string) (string, string) {
+	if !strings.ContainsAny(path, "\\\n") {
+		return "", path
+	}
+	r := strings.NewReplacer("\\", "\\\\", "\n", "\\n")
+	return "\\", r.Replace(path)
+}
+
+func unescapeChecksumPath(path string) string {
+	r := strings.NewReplacer("\\\\", "\\", "\\n", "\n")
+	return r.Replace(path)
+}
+
+// ------------------------------------------------------------------------------
+
+type ChecksumEntry struct {
+	Alg   *HashAlg
+	Path  string
+	Value []byte
+	// line number in the checksum file
+	Line int
+}
+
+func (e ChecksumEntry) String() string {
+	return fmt.Sprintf("%x", e.Value)
+}
+
+// ReadChecksumFile reads a checksum file written in the coreutils or bsd format.
+// Specify "-" to read from stdin.
+// The hash algorithm of coreutils format lines is decided by alg if it is not nil,
+// otherwise it is guessed by GuessHashAlg with preferredAlg.
+// Improperly formatted lines are returned as errors.
+//
+//	entries : []*ChecksumEntry
+//	errors of improperly formatted lines : []error
+//	error : error
+func ReadChecksumFile(path string, alg *HashAlg, preferredAlg *HashAlg) ([]*ChecksumEntry, []error, error) {
+	var r io.Reader
+	if path == "-" {
+		r = os.Stdin
+	} else {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		// nolint:errcheck
+		defer f.Close()
+		r = f
+	}
+
+	entries := make([]*ChecksumEntry, 0)
+	lineErrors := make([]error, 0)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		entry, err := parseChecksumLine(line, path, alg, preferredAlg)
+		if err != nil {
+			lineErrors = append(lineErrors, fmt.Errorf("%s:%d: %s", path, lineNo, err.Error()))
+			continue
+		}
+		entry.Line = lineNo
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	return entries, lineErrors, nil
+}
+
+func parseChecksumLine(line string, checksumPath string, alg *HashAlg, preferredAlg *HashAlg) (*ChecksumEntry, error) {
+	escaped := strings.HasPrefix(line, "\\")
+	if escaped {
+		line = line[1:]
+	}
+
+	var algName, path, value string
+	if pos := strings.LastIndex(line, ") = "); pos != -1 && strings.Contains(line[:pos], " (") {
+		// bsd format
+		start := strings.Index(line, " (")
+		algName = line[:start]
+		path = line[start+2 : pos]
+		value = line[pos+4:]
+	} else {
+		// coreutils format
+		pos := strings.Index(line, " ")
+		if pos == -1 || pos+2 > len(line) || (line[pos+1] != ' ' && line[pos+1] != '*') {
+			return nil, fmt.Errorf("improperly formatted checksum line")
+		}
+		value = line[:pos]
+		path = line[pos+2:]
+	}
+
+	if escaped {
+		path = unescapeChecksumPath(path)
+	}
+	v, err := hex.DecodeString(value)
+	if err != nil || len(v) == 0 {
+		return nil, fmt.Errorf("invalid hash value : %s", value)
+	}
+
+	entry := &ChecksumEntry{
+		Path:  path,
+		Value: v,
+	}
+	if algName != "" {
+		entry.Alg = NewHashAlgFromString(strings.ToLower(strings.ReplaceAll(algName, "-", "")))
+		if entry.Alg == nil {
+			return nil, fmt.Errorf("unsupported hash algorithm : %s", algName)
+		}
+	} else if alg != nil {
+		entry.Alg = alg
+	} else {
+		entry.Alg = GuessHashAlg(checksumPath, len(v), preferredAlg)
+		if entry.Alg == nil {
+			return nil, fmt.Errorf("can't guess hash algorithm. specify it with --algorithm option")
+		}
+	}
+	if len(v) != entry.Alg.Size {
+		return nil, fmt.Errorf("invalid hash length for %s : %s", entry.Alg.AlgName, value)
+	}
+	return entry, nil
+}
+
+// Priority of algorithms when guessing by digest length
+var guessPriority = []string{"sha1", "sha256", "sha512", "xxh64", "crc32c"}
+
+// GuessHashAlg guesses the hash algorithm of a checksum file.
+// At first, the algorithm name contained in the file name is used (e.g. "FILE.sha256", "SHA256SUMS").
+// Otherwise, it is guessed from the digest length in bytes,
+// preferring preferredAlg when it has the same length.
+// When it can't be guessed, it will return nil.
+func GuessHashAlg(checksumPath string, size int, preferredAlg *HashAlg) *HashAlg {
+	names := HashAlgNames()
+	// check longer names first
+	sort.SliceStable(names, func(i, j int) bool {
+		return len(names[i]) > len(names[j])
+	})
+	base := strings.ToLower(filepath.Base(checksumPath))
+	for _, n := range names {
+		if alg := NewHashAlgFromString(n); strings.Contains(base, n) && alg.Size == size {
+			return alg
+		}
+	}
+
+	if preferredAlg != nil && preferredAlg.Size == size {
+		return preferredAlg
+	}
+	for _, n := range guessPriority {
+		if alg := NewHashAlgFromString(n); alg != nil && alg.Size == size {
+			return alg
+		}
+	}
+	return nil
+}
+
+// CheckChecksum checks the file with the hash value of the checksum entry.
+// The stored hash value is reused if size and mtime of the file are unchanged,
+// unless full is true.
+//
+//	matched : bool
+//	error : error
+func CheckChecksum(entry *ChecksumEntry, full bool) (bool, error) {
+	var hash *Hash
+	if full {
+		h, err := CalcHash(entry.Path, entry.Alg)
+		if err != nil {
+			return false, err
+		}
+		hash = h
+	} else {
+		h, err := getValidStoredHash(entry.Path, entry.Alg)
+		if err != nil {
+			return false, err
+		}
+		if h == nil {
+			if h, err = CalcHash(entry.Path, entry.Alg); err != nil {
+				return false, err
+			}
+		}
+		hash = h
+	}
+	return hash.HasSameHashValue(&Hash{Value: entry.Value}), nil
+}
+
+// getValidStoredHash returns the stored hash value
+// only when size and mtime of the file have not changed since it was calculated.
+// Otherwise it will return nil.
+func getValidStoredHash(path string, alg *HashAlg) (*Hash, error) {
+	file, err := OpenFile(path)
+	if err != nil {
+		return nil, err
+	}
+	// nolint:errcheck
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	if GetAttr(file, Xattr_size) != fmt.Sprint(info.Size()) ||
+		GetAttr(file, Xattr_modifiedTime) != strconv.FormatInt(info.ModTime().UnixNano(), 10) {
+		return nil, nil
+	}
+	v := GetAttr(file, alg.AttrName)
+	if v == "" {
+		return nil, nil
+	}
+	hash, err := NewHashFromString(path, alg, v, info.ModTime().Unix())
+	if err != nil {
+		return nil, nil
+	}
+	return hash, nil
+}
@@ -0,0 +1,102 @@
+package core
+
+import (
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatHashes(t *testing.T) {
+	sha1 := NewHashAlgFromString("sha1")
+	sha256 := NewHashAlgFromString("sha256")
+	h1, _ := NewHashFromString("/abs/file.txt", sha1, "da39a3ee5e6b4b0d3255bfef95601890afd80709", 0)
+	h2, _ := NewHashFromString("/abs/file.txt", sha256, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", 0)
+
+	assert.Equal(t, "da39a3ee5e6b4b0d3255bfef95601890afd80709  dir/file.txt",
+		FormatHashes(HashFormat_Coreutils, "dir/file.txt", []*Hash{h1}))
+	assert.Equal(t, "\\da39a3ee5e6b4b0d3255bfef95601890afd80709  a\\\\b\\nc",
+		FormatHashes(HashFormat_Coreutils, "a\\b\nc", []*Hash{h1}))
+	assert.Equal(t, "SHA1 (f) = da39a3ee5e6b4b0d3255bfef95601890afd80709\nSHA256 (f) = e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+		FormatHashes(HashFormat_Bsd, "f", []*Hash{h1, h2}))
+
+	assert.Error(t, CheckHashFormat(HashFormat_Coreutils, []*HashAlg{sha1, sha256}))
+	assert.NoError(t, CheckHashFormat(HashFormat_Bsd, []*HashAlg{sha1, sha256}))
+	assert.Error(t, CheckHashFormat("unknown", []*HashAlg{sha1}))
+}
+
+func TestReadChecksumFile(t *testing.T) {
+	sha1 := NewHashAlgFromString("sha1")
+	sha256 := NewHashAlgFromString("sha256")
+	blake3 := NewHashAlgFromString("blake3")
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "SHA256SUMS")
+	content := "" +
+		"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855  empty.txt\r\n" +
+		"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855 *binary.bin\n" +
+		"\\e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855  new\\nline\n" +
+		"SHA1 (with space (1).txt) = da39a3ee5e6b4b0d3255bfef95601890afd80709\n" +
+		"# comment\n" +
+		"broken line\n"
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0644))
+
+	entries, lineErrors, err := ReadChecksumFile(path, nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(lineErrors))
+	if assert.Equal(t, 4, len(entries)) {
+		assert.Equal(t, "empty.txt", entries[0].Path)
+		assert.Equal(t, sha256, entries[0].Alg)
+		assert.Equal(t, "binary.bin", entries[1].Path)
+		assert.Equal(t, "new\nline", entries[2].Path)
+		assert.Equal(t, "with space (1).txt", entries[3].Path)
+		assert.Equal(t, sha1, entries[3].Alg)
+		assert.Equal(t, "da39a3ee5e6b4b0d3255bfef95601890afd80709", entries[3].String())
+	}
+
+	// explicitly specified algorithm
+	entries, _, err = ReadChecksumFile(path, blake3, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, blake3, entries[0].Alg)
+	assert.Equal(t, sha1, entries[3].Alg)
+}
+
+func TestGuessHashAlg(t *testing.T) {
+	sha1 := NewHashAlgFromString("sha1")
+	sha256 := NewHashAlgFromString("sha256")
+	blake3 := NewHashAlgFromString("blake3")
+
+	assert.Equal(t, sha256, GuessHashAlg("files.sha256", 32, nil))
+	assert.Equal(t, blake3, GuessHashAlg("files.blake3", 32, sha256))
+	assert.Equal(t, blake3, GuessHashAlg("files.txt", 32, blake3))
+	assert.Equal(t, sha256, GuessHashAlg("files.txt", 32, sha1))
+	assert.Equal(t, sha1, GuessHashAlg("files.txt", 20, nil))
+	assert.Nil(t, GuessHashAlg("files.txt", 3, nil))
+}
+
+func TestCheckChecksum(t *testing.T) {
+	alg := NewDefaultHashAlg()
+	path, expected := makeSingleDummyFile(t, alg)
+
+	entry := &ChecksumEntry{Path: path, Alg: alg}
+	entry.Value, _ = hex.DecodeString(expected)
+
+	// stored hash value is not calculated yet
+	matched, err := CheckChecksum(entry, false)
+	assert.NoError(t, err)
+	assert.True(t, matched)
+
+	_, _, err = UpdateHash(path, alg, false)
+	assert.NoError(t, err)
+
+	matched, err = CheckChecksum(entry, false)
+	assert.NoError(t, err)
+	assert.True(t, matched)
+
+	entry.Value[0] ^= 0xff
+	matched, err = CheckChecksum(entry, true)
+	assert.NoError(t, err)
+	assert.False(t, matched)
+}
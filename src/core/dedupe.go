@@ -0,0 +1,287 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	. "github.com/little-forest/hasher/common" // nolint:staticcheck
+)
+
+// Actions to deduplicate files
+const (
+	// replace duplicates with hard links of the kept file
+	DedupeAction_Hardlink = "hardlink"
+	// replace duplicates with reflinks (copy-on-write clones) of the kept file
+	DedupeAction_Reflink = "reflink"
+	// delete duplicates
+	DedupeAction_Delete = "delete"
+)
+
+// Orders to choose the file to keep from a group of duplicates
+const (
+	KeepPolicy_Oldest   = "oldest"
+	KeepPolicy_Newest   = "newest"
+	KeepPolicy_Shortest = "shortest"
+)
+
+// KeepPolicy decides which file of each group of duplicates is kept.
+type KeepPolicy struct {
+	// one of KeepPolicy_* to choose among the rest
+	Order string
+	// files under these directories are kept in preference to others,
+	// and the former directory has priority
+	PreferDirs []string
+}
+
+func NewKeepPolicy(order string, preferDirs []string) (*KeepPolicy, error) {
+	switch order {
+	case KeepPolicy_Oldest, KeepPolicy_Newest, KeepPolicy_Shortest:
+	default:
+		return nil, fmt.Errorf("unsupported keep policy : %s", order)
+	}
+
+	p := &KeepPolicy{
+		Order:      order,
+		PreferDirs: make([]string, len(preferDirs)),
+	}
+	for i, d := range preferDirs {
+		abs, err := filepath.Abs(d)
+		if err != nil {
+			return nil, err
+		}
+		p.PreferDirs[i] = abs
+	}
+	return p, nil
+}
+
+// preference returns the index of the preferred directory which contains the path.
+// If none of them contains it, it returns the number of them.
+func (p *KeepPolicy) preference(path string) int {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return len(p.PreferDirs)
+	}
+	for i, d := range p.PreferDirs {
+		rel, err := filepath.Rel(d, abs)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return i
+		}
+	}
+	return len(p.PreferDirs)
+}
+
+// sort sorts files in order of priority to be kept.
+func (p *KeepPolicy) sort(files []*Hash, infos map[string]os.FileInfo) {
+	sort.SliceStable(files, func(i, j int) bool {
+		fi, fj := files[i], files[j]
+		if pi, pj := p.preference(fi.Path), p.preference(fj.Path); pi != pj {
+			return pi < pj
+		}
+		ti, tj := infos[fi.Path].ModTime(), infos[fj.Path].ModTime()
+		switch p.Order {
+		case KeepPolicy_Oldest:
+			if !ti.Equal(tj) {
+				return ti.Before(tj)
+			}
+		case KeepPolicy_Newest:
+			if !ti.Equal(tj) {
+				return ti.After(tj)
+			}
+		case KeepPolicy_Shortest:
+			if len(fi.Path) != len(fj.Path) {
+				return len(fi.Path) < len(fj.Path)
+			}
+		}
+		return fi.Path < fj.Path
+	})
+}
+
+// DedupeStep is an operation to deduplicate a file.
+type DedupeStep struct {
+	// file to be replaced or deleted
+	Target string
+	// file which is kept
+	Keep   string
+	Action string
+	Size   int64
+}
+
+// ValidateDedupeAction returns an error if the action is not one of DedupeAction_*.
+func ValidateDedupeAction(action string) error {
+	switch action {
+	case DedupeAction_Hardlink, DedupeAction_Reflink, DedupeAction_Delete:
+		return nil
+	default:
+		return fmt.Errorf("unsupported dedupe action : %s", action)
+	}
+}
+
+// PlanDedupe returns steps to leave only one file of each group according to the policy.
+// Files which don't exist anymore are ignored, and so are empty files.
+func PlanDedupe(groups []*DuplicateGroup, action string, policy *KeepPolicy) ([]DedupeStep, error) {
+	if err := ValidateDedupeAction(action); err != nil {
+		return nil, err
+	}
+
+	steps := make([]DedupeStep, 0)
+	for _, g := range groups {
+		if g.Size == 0 {
+			continue
+		}
+		files := make([]*Hash, 0, len(g.Files))
+		infos := make(map[string]os.FileInfo, len(g.Files))
+		for _, f := range g.Files {
+			if info, err := os.Stat(f.Path); err == nil {
+				files = append(files, f)
+				infos[f.Path] = info
+			}
+		}
+		if len(files) < 2 {
+			continue
+		}
+
+		policy.sort(files, infos)
+		for _, f := range files[1:] {
+			steps = append(steps, DedupeStep{
+				Target: f.Path,
+				Keep:   files[0].Path,
+				Action: action,
+				Size:   g.Size,
+			})
+		}
+	}
+	return steps, nil
+}
+
+// Apply deduplicates the target file.
+// Contents of the target and the kept file are compared byte-for-byte before anything is changed,
+// and the target is replaced atomically so that it never disappears on failure.
+func (s DedupeStep) Apply() error {
+	keepInfo, err := os.Stat(s.Keep)
+	if err != nil {
+		return err
+	}
+	targetInfo, err := os.Stat(s.Target)
+	if err != nil {
+		return err
+	}
+	if os.SameFile(keepInfo, targetInfo) {
+		// already deduplicated
+		return nil
+	}
+
+	same, err := hasSameContents(s.Keep, s.Target)
+	if err != nil {
+		return err
+	}
+	if !same {
+		return fmt.Errorf("contents differ from %s : %s", s.Keep, s.Target)
+	}
+
+	switch s.Action {
+	case DedupeAction_Delete:
+		return os.Remove(s.Target)
+	case DedupeAction_Hardlink:
+		return replaceFile(s.Target, func(tmp string) error {
+			return os.Link(s.Keep, tmp)
+		}, nil)
+	case DedupeAction_Reflink:
+		return replaceFile(s.Target, func(tmp string) error {
+			return CloneFile(s.Keep, tmp)
+		}, func(tmp string) error {
+			if err := os.Chmod(tmp, targetInfo.Mode().Perm()); err != nil {
+				return err
+			}
+			return os.Chtimes(tmp, targetInfo.ModTime(), targetInfo.ModTime())
+		})
+	default:
+		return fmt.Errorf("unsupported dedupe action : %s", s.Action)
+	}
+}
+
+// Max number of attempts to create a temporary file of a unique name
+const maxTempAttempts = 10
+
+// createTempFile creates a new file by create at a temporary path of a unique name
+// in the same directory as the target, and returns the path.
+// create must fail without leaving anything if the temporary path already exists.
+func createTempFile(target string, suffix string, create func(tmp string) error) (string, error) {
+	for i := 0; ; i++ {
+		tmp := filepath.Join(filepath.Dir(target), fmt.Sprintf(".%s.%08x%s", filepath.Base(target), rand.Uint32(), suffix))
+		err := create(tmp)
+		if err == nil {
+			return tmp, nil
+		}
+		if !os.IsExist(err) || i+1 >= maxTempAttempts {
+			return "", err
+		}
+	}
+}
+
+// replaceFile creates a new file by create at a temporary path in the same directory as the target,
+// prepares it by setup if not nil, and renames it to the target.
+//
+// create must fail without leaving anything if the temporary path already exists.
+// The temporary file is removed on failure only after this call has created it,
+// so that an existing file is never removed.
+func replaceFile(target string, create func(tmp string) error, setup func(tmp string) error) error {
+	tmp, err := createTempFile(target, TempFileSuffix_Dedupe, create)
+	if err != nil {
+		return err
+	}
+
+	if setup != nil {
+		if err := setup(tmp); err != nil {
+			os.Remove(tmp) // nolint:errcheck
+			return err
+		}
+	}
+	if err := os.Rename(tmp, target); err != nil {
+		os.Remove(tmp) // nolint:errcheck
+		return err
+	}
+	return nil
+}
+
+// hasSameContents compares contents of two files byte-for-byte.
+func hasSameContents(path1 string, path2 string) (bool, error) {
+	f1, err := OpenFile(path1)
+	if err != nil {
+		return false, err
+	}
+	// nolint:errcheck
+	defer f1.Close()
+
+	f2, err := OpenFile(path2)
+	if err != nil {
+		return false, err
+	}
+	// nolint:errcheck
+	defer f2.Close()
+
+	buf1 := make([]byte, hashBufSize)
+	buf2 := make([]byte, hashBufSize)
+	for {
+		n1, err1 := io.ReadFull(f1, buf1)
+		if err1 != nil && err1 != io.EOF && err1 != io.ErrUnexpectedEOF {
+			return false, err1
+		}
+		n2, err2 := io.ReadFull(f2, buf2)
+		if err2 != nil && err2 != io.EOF && err2 != io.ErrUnexpectedEOF {
+			return false, err2
+		}
+		if !bytes.Equal(buf1[:n1], buf2[:n2]) {
+			return false, nil
+		}
+		if err1 != nil || err2 != nil {
+			// both reached the end since the same number of bytes have been read
+			return true, nil
+		}
+	}
+}
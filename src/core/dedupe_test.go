@@ -0,0 +1,186 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// makeDuplicateGroup makes files of the same contents with given mtime offsets,
+// and returns a group of them.
+func makeDuplicateGroup(t *testing.T, dir string, files map[string]time.Duration) *DuplicateGroup {
+	t.Helper()
+
+	alg := NewDefaultHashAlg()
+	base := time.Now().Add(-time.Hour)
+	store := NewHashStore()
+	for name, delta := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		assert.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		assert.NoError(t, os.WriteFile(p, []byte("duplicated"), 0o644))
+		assert.NoError(t, os.Chtimes(p, base, base.Add(delta)))
+		h, err := CalcHash(p, alg)
+		assert.NoError(t, err)
+		store.Put(h)
+	}
+	groups := FindDuplicateGroups(store)
+	assert.Len(t, groups, 1)
+	return groups[0]
+}
+
+func TestPlanDedupe_keepPolicy(t *testing.T) {
+	dir := t.TempDir()
+	g := makeDuplicateGroup(t, dir, map[string]time.Duration{
+		"a/long-name.txt": 0,
+		"b/1.txt":         time.Minute,
+		"c/22.txt":        2 * time.Minute,
+	})
+
+	cases := []struct {
+		order    string
+		expected string
+		prefer   []string
+	}{
+		{KeepPolicy_Oldest, "a/long-name.txt", nil},
+		{KeepPolicy_Newest, "c/22.txt", nil},
+		{KeepPolicy_Shortest, "b/1.txt", nil},
+		{KeepPolicy_Oldest, "c/22.txt", []string{filepath.Join(dir, "c")}},
+		{KeepPolicy_Newest, "a/long-name.txt", []string{filepath.Join(dir, "a"), filepath.Join(dir, "b")}},
+	}
+	for _, c := range cases {
+		policy, err := NewKeepPolicy(c.order, c.prefer)
+		assert.NoError(t, err)
+		steps, err := PlanDedupe([]*DuplicateGroup{g}, DedupeAction_Delete, policy)
+		assert.NoError(t, err)
+		if assert.Len(t, steps, 2, c.order) {
+			for _, s := range steps {
+				assert.Equal(t, filepath.Join(dir, filepath.FromSlash(c.expected)), s.Keep, c.order)
+				assert.NotEqual(t, s.Keep, s.Target)
+				assert.Equal(t, int64(10), s.Size)
+			}
+		}
+	}
+
+	_, err := NewKeepPolicy("largest", nil)
+	assert.Error(t, err)
+	policy, _ := NewKeepPolicy(KeepPolicy_Oldest, nil)
+	_, err = PlanDedupe([]*DuplicateGroup{g}, "move", policy)
+	assert.Error(t, err)
+}
+
+func TestValidateDedupeAction(t *testing.T) {
+	for _, action := range []string{DedupeAction_Hardlink, DedupeAction_Reflink, DedupeAction_Delete} {
+		assert.NoError(t, ValidateDedupeAction(action))
+	}
+	assert.Error(t, ValidateDedupeAction("bogus"))
+
+	_, err := PlanDedupe(nil, "bogus", &KeepPolicy{Order: KeepPolicy_Oldest})
+	assert.Error(t, err)
+}
+
+func TestDedupeStep_Apply(t *testing.T) {
+	dir := t.TempDir()
+	g := makeDuplicateGroup(t, dir, map[string]time.Duration{
+		"keep.txt":   0,
+		"link.txt":   time.Minute,
+		"delete.txt": time.Minute,
+	})
+	policy, _ := NewKeepPolicy(KeepPolicy_Oldest, nil)
+	steps, err := PlanDedupe([]*DuplicateGroup{g}, DedupeAction_Hardlink, policy)
+	assert.NoError(t, err)
+	assert.Len(t, steps, 2)
+
+	keep := filepath.Join(dir, "keep.txt")
+	link := filepath.Join(dir, "link.txt")
+	del := filepath.Join(dir, "delete.txt")
+
+	s := DedupeStep{Target: link, Keep: keep, Action: DedupeAction_Hardlink}
+	assert.NoError(t, s.Apply())
+	keepInfo, _ := os.Stat(keep)
+	linkInfo, _ := os.Stat(link)
+	assert.True(t, os.SameFile(keepInfo, linkInfo))
+	// applying again does nothing
+	assert.NoError(t, s.Apply())
+
+	s = DedupeStep{Target: del, Keep: keep, Action: DedupeAction_Delete}
+	assert.NoError(t, s.Apply())
+	assert.NoFileExists(t, del)
+
+	// a file changed after planning is never touched
+	assert.NoError(t, os.WriteFile(del, []byte("duplicatex"), 0o644))
+	assert.Error(t, s.Apply())
+	assert.FileExists(t, del)
+	s.Action = DedupeAction_Hardlink
+	assert.Error(t, s.Apply())
+	b, _ := os.ReadFile(del)
+	assert.Equal(t, "duplicatex", string(b))
+	entries, _ := os.ReadDir(dir)
+	assert.Len(t, entries, 3)
+}
+
+func TestReplaceFile_existingTemp(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "b.txt")
+	assert.NoError(t, os.WriteFile(target, []byte("target"), 0o644))
+	// left by an older version
+	precious := filepath.Join(dir, ".b.txt.hasher-dedupe")
+	assert.NoError(t, os.WriteFile(precious, []byte("precious"), 0o644))
+
+	// every temporary path is taken by another file
+	taken := make([]string, 0)
+	err := replaceFile(target, func(tmp string) error {
+		assert.NoError(t, os.WriteFile(tmp, []byte("precious"), 0o644))
+		taken = append(taken, tmp)
+		return os.Link(target, tmp)
+	}, nil)
+	assert.True(t, os.IsExist(err))
+	assert.Len(t, taken, maxTempAttempts)
+	for _, p := range append(taken, precious) {
+		b, err := os.ReadFile(p)
+		assert.NoError(t, err)
+		assert.Equal(t, "precious", string(b))
+	}
+	b, _ := os.ReadFile(target)
+	assert.Equal(t, "target", string(b))
+}
+
+func TestDedupeStep_Apply_reflink(t *testing.T) {
+	dir := t.TempDir()
+	keep := filepath.Join(dir, "keep.txt")
+	target := filepath.Join(dir, "target.txt")
+	assert.NoError(t, os.WriteFile(keep, []byte("duplicated"), 0o644))
+	assert.NoError(t, os.WriteFile(target, []byte("duplicated"), 0o600))
+	mtime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	assert.NoError(t, os.Chtimes(target, mtime, mtime))
+
+	s := DedupeStep{Target: target, Keep: keep, Action: DedupeAction_Reflink}
+	if err := s.Apply(); err != nil {
+		t.Skipf("reflink is not supported : %s", err.Error())
+	}
+	info, err := os.Stat(target)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	assert.True(t, mtime.Equal(info.ModTime()))
+	b, _ := os.ReadFile(target)
+	assert.Equal(t, "duplicated", string(b))
+}
+
+func TestHasSameContents(t *testing.T) {
+	dir := t.TempDir()
+	p1 := filepath.Join(dir, "1")
+	p2 := filepath.Join(dir, "2")
+	p3 := filepath.Join(dir, "3")
+	assert.NoError(t, os.WriteFile(p1, make([]byte, hashBufSize*2), 0o644))
+	assert.NoError(t, os.WriteFile(p2, make([]byte, hashBufSize*2), 0o644))
+	assert.NoError(t, os.WriteFile(p3, make([]byte, hashBufSize*2+1), 0o644))
+
+	same, err := hasSameContents(p1, p2)
+	assert.NoError(t, err)
+	assert.True(t, same)
+	same, err = hasSameContents(p1, p3)
+	assert.NoError(t, err)
+	assert.False(t, same)
+}
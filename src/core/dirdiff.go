@@ -143,6 +143,11 @@ func (me *DirDiff) Compare(other *DirDiff) {
 }
 
 func NewDirDiff(dirPath string, alg *HashAlg) (*DirDiff, error) {
+	return newDirDiff(dirPath, alg, common.NewPathFilter(dirPath))
+}
+
+// newDirDiff lists files in the directory which are not excluded by the filter.
+func newDirDiff(dirPath string, alg *HashAlg, filter *common.PathFilter) (*DirDiff, error) {
 	dir, err := os.Open(dirPath)
 	if err != nil {
 		return nil, err
@@ -159,12 +164,23 @@ func NewDirDiff(dirPath string, alg *HashAlg) (*DirDiff, error) {
 	}
 
 	for _, fileInfo := range fileInfos {
-		if !fileInfo.IsDir() {
-			filePath := filepath.Join(dirPath, fileInfo.Name())
-			if fileInfo.Type() == fs.ModeSymlink {
+		filePath := filepath.Join(dirPath, fileInfo.Name())
+		if fileInfo.Type() == fs.ModeSymlink {
+			if !common.IsFollowingSymlinks() {
 				common.ShowWarn("Skip symbolic link %s", filePath)
 				continue
 			}
+			info, err := os.Stat(filePath)
+			if err != nil {
+				common.ShowWarn("Skip broken symbolic link %s", filePath)
+				continue
+			}
+			fileInfo = fs.FileInfoToDirEntry(info)
+		}
+		if !fileInfo.IsDir() {
+			if common.IsHasherFile(filePath) || filter.IsExcluded(filePath, fileInfo) {
+				continue
+			}
 			f, err := NewFileDiff(filePath, alg)
 			if err != nil {
 				common.ShowWarn("Failed to calc hash %s", err.Error())
@@ -178,17 +194,17 @@ func NewDirDiff(dirPath string, alg *HashAlg) (*DirDiff, error) {
 	return dirDiff, nil
 }
 
-func DirDiffRecursively(baseDir string, targetDir string) ([]*DirPair, error) {
-	alg := NewDefaultHashAlg()
-
+func DirDiffRecursively(baseDir string, targetDir string, alg *HashAlg) ([]*DirPair, error) {
 	// list directories
 	baseDir = normalizeDirPath(baseDir)
-	baseDirList, err := listDirectories(baseDir)
+	baseFilter := common.NewPathFilter(baseDir)
+	baseDirList, err := listDirectories(baseDir, baseFilter)
 	if err != nil {
 		return nil, err
 	}
 	targetDir = normalizeDirPath(targetDir)
-	targetDirList, err := listDirectories(targetDir)
+	targetFilter := common.NewPathFilter(targetDir)
+	targetDirList, err := listDirectories(targetDir, targetFilter)
 	if err != nil {
 		return nil, err
 	}
@@ -198,7 +214,7 @@ func DirDiffRecursively(baseDir string, targetDir string) ([]*DirPair, error) {
 	// directories in `baseDir` (added)
 	baseonly := baseDirList.Difference(targetDirList)
 	for p := range baseonly.Iterator().C {
-		dd, err := NewDirDiff(filepath.Join(baseDir, p), alg)
+		dd, err := newDirDiff(filepath.Join(baseDir, p), alg, baseFilter)
 		if err != nil {
 			// TODO:
 			fmt.Fprintln(os.Stderr, err.Error())
@@ -211,7 +227,7 @@ func DirDiffRecursively(baseDir string, targetDir string) ([]*DirPair, error) {
 	// directories in `targetDir` (removed)
 	removedDirList := targetDirList.Difference(baseDirList)
 	for p := range removedDirList.Iterator().C {
-		dd, err := NewDirDiff(filepath.Join(targetDir, p), alg)
+		dd, err := newDirDiff(filepath.Join(targetDir, p), alg, targetFilter)
 		if err != nil {
 			// TODO:
 			fmt.Fprintln(os.Stderr, err.Error())
@@ -223,11 +239,11 @@ func DirDiffRecursively(baseDir string, targetDir string) ([]*DirPair, error) {
 
 	// check intersect directories
 	for p := range baseDirList.Intersect(targetDirList).Iterator().C {
-		baseDirDiff, err := NewDirDiff(filepath.Join(baseDir, p), alg)
+		baseDirDiff, err := newDirDiff(filepath.Join(baseDir, p), alg, baseFilter)
 		if err != nil {
 			return nil, err
 		}
-		targetDirDiff, err := NewDirDiff(filepath.Join(targetDir, p), alg)
+		targetDirDiff, err := newDirDiff(filepath.Join(targetDir, p), alg, targetFilter)
 		if err != nil {
 			return nil, err
 		}
@@ -251,10 +267,10 @@ func normalizeDirPath(dirpath string) string {
 	return dirpath
 }
 
-func listDirectories(dir string) (mapset.Set[string], error) {
+func listDirectories(dir string, filter *common.PathFilter) (mapset.Set[string], error) {
 	dirlist := mapset.NewSet[string]()
 
-	err := filepath.WalkDir(dir, func(path string, info fs.DirEntry, err error) error {
+	err := filter.WalkDir(func(path string, info fs.DirEntry, err error) error {
 		if err != nil {
 			return errors.Wrap(err, "failed to filepath.Walk")
 		}
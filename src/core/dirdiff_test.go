@@ -171,9 +171,9 @@ func prepareDirDiffTest_01(t *testing.T, alg *HashAlg) (string, string) {
 	path3 := filepath.Join(meDir, "test03")
 
 	// me
-	makeDummyFile(t, path1, &alg.Alg)
-	makeDummyFile(t, path2, &alg.Alg)
-	makeDummyFile(t, path3, &alg.Alg)
+	makeDummyFile(t, path1, alg)
+	makeDummyFile(t, path2, alg)
+	makeDummyFile(t, path3, alg)
 
 	copyFile(t, meDir, otherDir, "test01")
 	copyFile(t, meDir, otherDir, "test02")
@@ -208,21 +208,21 @@ func prepareDirDiffTest_02(t *testing.T, alg *HashAlg) (string, string) {
 	oPath6 := filepath.Join(otherDir, "test06")
 
 	// me
-	makeDummyFile(t, mPath1, &alg.Alg)
-	makeDummyFile(t, mPath2, &alg.Alg)
-	makeDummyFile(t, mPath3, &alg.Alg)
-	makeDummyFile(t, mPath4, &alg.Alg)
-	makeDummyFile(t, mPath5, &alg.Alg)
+	makeDummyFile(t, mPath1, alg)
+	makeDummyFile(t, mPath2, alg)
+	makeDummyFile(t, mPath3, alg)
+	makeDummyFile(t, mPath4, alg)
+	makeDummyFile(t, mPath5, alg)
 
 	// other
 	copyFile(t, meDir, otherDir, "test01")
-	makeDummyFile(t, oPath2, &alg.Alg)
+	makeDummyFile(t, oPath2, alg)
 	touchDelta(t, mPath2, oPath2, time.Minute)
-	makeDummyFile(t, oPath3, &alg.Alg)
+	makeDummyFile(t, oPath3, alg)
 	touchDelta(t, mPath3, oPath3, -time.Minute)
-	makeDummyFile(t, oPath4, &alg.Alg)
+	makeDummyFile(t, oPath4, alg)
 	touchDelta(t, mPath4, oPath4, 0)
-	makeDummyFile(t, oPath6, &alg.Alg)
+	makeDummyFile(t, oPath6, alg)
 
 	return meDir, otherDir
 }
@@ -243,9 +243,9 @@ func prepareDirDiffTest_03(t *testing.T, alg *HashAlg) (string, string) {
 	path3 := filepath.Join(meDir, "test03")
 
 	// me
-	makeDummyFile(t, path1, &alg.Alg)
-	makeDummyFile(t, path2, &alg.Alg)
-	makeDummyFile(t, path3, &alg.Alg)
+	makeDummyFile(t, path1, alg)
+	makeDummyFile(t, path2, alg)
+	makeDummyFile(t, path3, alg)
 
 	return meDir, otherDir
 }
@@ -266,9 +266,9 @@ func prepareDirDiffTest_04(t *testing.T, alg *HashAlg) (string, string) {
 	path3 := filepath.Join(otherDir, "test03")
 
 	// other
-	makeDummyFile(t, path1, &alg.Alg)
-	makeDummyFile(t, path2, &alg.Alg)
-	makeDummyFile(t, path3, &alg.Alg)
+	makeDummyFile(t, path1, alg)
+	makeDummyFile(t, path2, alg)
+	makeDummyFile(t, path3, alg)
 
 	return meDir, otherDir
 }
@@ -289,9 +289,9 @@ func prepareDirDiffTest_05(t *testing.T, alg *HashAlg) (string, string) {
 	path3 := filepath.Join(otherDir, "test03")
 
 	// other
-	makeDummyFile(t, path1, &alg.Alg)
-	makeDummyFile(t, path2, &alg.Alg)
-	makeDummyFile(t, path3, &alg.Alg)
+	makeDummyFile(t, path1, alg)
+	makeDummyFile(t, path2, alg)
+	makeDummyFile(t, path3, alg)
 
 	// me
 	copyFile(t, otherDir, meDir, "test01")
@@ -332,22 +332,22 @@ func prepareDirDiffTest_06(t *testing.T, alg *HashAlg) (string, string) {
 	oPath6 := filepath.Join(otherDir, "test06")
 
 	// me
-	makeDummyFile(t, mPath1, &alg.Alg)
-	makeDummyFile(t, mPath2, &alg.Alg)
-	makeDummyFile(t, mPath4, &alg.Alg)
-	makeDummyFile(t, mPath5, &alg.Alg)
+	makeDummyFile(t, mPath1, alg)
+	makeDummyFile(t, mPath2, alg)
+	makeDummyFile(t, mPath4, alg)
+	makeDummyFile(t, mPath5, alg)
 
 	// other
 	copyFile(t, meDir, otherDir, "test01")
-	makeDummyFile(t, oPath2, &alg.Alg)
+	makeDummyFile(t, oPath2, alg)
 	touchDelta(t, mPath2, oPath2, time.Minute)
-	makeDummyFile(t, oPath3, &alg.Alg)
+	makeDummyFile(t, oPath3, alg)
 	copyFile(t, otherDir, meDir, "test03")
 	// nolint:errcheck
 	os.Rename(filepath.Join(meDir, "test03"), filepath.Join(meDir, "test07"))
-	makeDummyFile(t, oPath4, &alg.Alg)
+	makeDummyFile(t, oPath4, alg)
 	touchDelta(t, mPath4, oPath4, 0)
-	makeDummyFile(t, oPath6, &alg.Alg)
+	makeDummyFile(t, oPath6, alg)
 
 	return meDir, otherDir
 }
@@ -0,0 +1,144 @@
+package core
+
+import (
+	"os"
+	"sort"
+
+	. "github.com/little-forest/hasher/common" // nolint:staticcheck
+)
+
+// DuplicateGroup is a group of files which have the same hash value.
+//
+// Hard links of the same file are already deduplicated,
+// so only one of them is in Files and the others are in HardLinks.
+type DuplicateGroup struct {
+	// distinct files sorted by path
+	Files []*Hash
+	// hard links of files in Files
+	HardLinks []*Hash
+	// size of each file
+	Size int64
+}
+
+// NumOfDuplicates returns the number of files which can be removed
+// leaving only one of them.
+func (g *DuplicateGroup) NumOfDuplicates() int {
+	return len(g.Files) - 1
+}
+
+// TotalBytes returns bytes occupied by all files of the group.
+func (g *DuplicateGroup) TotalBytes() int64 {
+	return g.Size * int64(len(g.Files))
+}
+
+// WastedBytes returns bytes which can be freed leaving only one file.
+func (g *DuplicateGroup) WastedBytes() int64 {
+	return g.Size * int64(g.NumOfDuplicates())
+}
+
+// FindDuplicateGroups returns groups of identical files in the store,
+// sorted in descending order of wasted bytes.
+// Groups which consist of hard links of only one file are excluded.
+func FindDuplicateGroups(store *HashStore) []*DuplicateGroup {
+	groups := make([]*DuplicateGroup, 0)
+	for _, key := range store.KeySet() {
+		sames := store.Get(key)
+		if len(sames) < 2 {
+			continue
+		}
+		if g := newDuplicateGroup(sames); len(g.Files) > 1 {
+			groups = append(groups, g)
+		}
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		wi, wj := groups[i].WastedBytes(), groups[j].WastedBytes()
+		if wi != wj {
+			return wi > wj
+		}
+		return groups[i].Files[0].Path < groups[j].Files[0].Path
+	})
+	return groups
+}
+
+// newDuplicateGroup makes a group of given files having the same hash value.
+// The same path is counted only once, and files which don't exist are treated as distinct files.
+func newDuplicateGroup(sames []*Hash) *DuplicateGroup {
+	hashes := make([]*Hash, 0, len(sames))
+	paths := make(map[string]bool)
+	for _, h := range sames {
+		if !paths[h.Path] {
+			paths[h.Path] = true
+			hashes = append(hashes, h)
+		}
+	}
+	sort.Slice(hashes, func(i, j int) bool {
+		return hashes[i].Path < hashes[j].Path
+	})
+
+	g := &DuplicateGroup{
+		Files:     make([]*Hash, 0, len(hashes)),
+		HardLinks: make([]*Hash, 0),
+	}
+	ids := make(map[FileId]bool)
+	for _, h := range hashes {
+		info, err := os.Stat(h.Path)
+		if err != nil {
+			g.Files = append(g.Files, h)
+			continue
+		}
+		if g.Size == 0 {
+			g.Size = info.Size()
+		}
+		if id, ok := GetFileId(info); ok {
+			if ids[id] {
+				g.HardLinks = append(g.HardLinks, h)
+				continue
+			}
+			ids[id] = true
+		}
+		g.Files = append(g.Files, h)
+	}
+
+	// size recorded in the hash list or the catalog
+	if g.Size == 0 {
+		for _, h := range hashes {
+			if h.Size > 0 {
+				g.Size = h.Size
+				break
+			}
+		}
+	}
+	return g
+}
+
+// DuplicateGroupRecord is the JSON representation of a result of the dupes sub-command.
+type DuplicateGroupRecord struct {
+	Algorithm string       `json:"algorithm"`
+	Hash      string       `json:"hash"`
+	Files     []HashRecord `json:"files"`
+	// hard links of files, which are already deduplicated
+	HardLinks []HashRecord `json:"hardlinks"`
+	Size      int64        `json:"size"`
+	Total     int64        `json:"total"`
+	Wasted    int64        `json:"wasted"`
+}
+
+func NewDuplicateGroupRecord(g *DuplicateGroup) DuplicateGroupRecord {
+	r := DuplicateGroupRecord{
+		Files:     make([]HashRecord, len(g.Files)),
+		HardLinks: make([]HashRecord, len(g.HardLinks)),
+		Algorithm: g.Files[0].Alg.AlgName,
+		Hash:      g.Files[0].String(),
+		Size:      g.Size,
+		Total:     g.TotalBytes(),
+		Wasted:    g.WastedBytes(),
+	}
+	for i, f := range g.Files {
+		r.Files[i] = NewHashRecord(f.Path, []*Hash{f})
+	}
+	for i, h := range g.HardLinks {
+		r.HardLinks[i] = NewHashRecord(h.Path, []*Hash{h})
+	}
+	return r
+}
@@ -0,0 +1,68 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindDuplicateGroups(t *testing.T) {
+	alg := NewDefaultHashAlg()
+	dir := t.TempDir()
+	files := map[string]string{
+		"a/1.txt":    "small",
+		"b/1.txt":    "small",
+		"a/2.txt":    "larger content",
+		"b/2.txt":    "larger content",
+		"c/2.txt":    "larger content",
+		"a/uniq.txt": "unique",
+		"a/3.txt":    "only hard links",
+	}
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		assert.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		assert.NoError(t, os.WriteFile(p, []byte(content), 0o644))
+	}
+	assert.NoError(t, os.Link(filepath.Join(dir, "a", "2.txt"), filepath.Join(dir, "c", "2-link.txt")))
+	assert.NoError(t, os.Link(filepath.Join(dir, "a", "3.txt"), filepath.Join(dir, "c", "3-link.txt")))
+
+	store := NewHashStore()
+	assert.NoError(t, store.AppendHashDataFromDirectory(dir, alg, false))
+	// the same tree given twice
+	assert.NoError(t, store.AppendHashDataFromDirectory(filepath.Join(dir, "b"), alg, false))
+
+	groups := FindDuplicateGroups(store)
+	if !assert.Len(t, groups, 2) {
+		return
+	}
+
+	g := groups[0]
+	assert.Equal(t, []string{"a/2.txt", "b/2.txt", "c/2.txt"}, relPaths(dir, g.Files))
+	assert.Equal(t, []string{"c/2-link.txt"}, relPaths(dir, g.HardLinks))
+	assert.Equal(t, int64(14), g.Size)
+	assert.Equal(t, 2, g.NumOfDuplicates())
+	assert.Equal(t, int64(42), g.TotalBytes())
+	assert.Equal(t, int64(28), g.WastedBytes())
+
+	g = groups[1]
+	assert.Equal(t, []string{"a/1.txt", "b/1.txt"}, relPaths(dir, g.Files))
+	assert.Empty(t, g.HardLinks)
+	assert.Equal(t, int64(5), g.WastedBytes())
+
+	r := NewDuplicateGroupRecord(groups[0])
+	assert.Len(t, r.Files, 3)
+	assert.Len(t, r.HardLinks, 1)
+	assert.Equal(t, int64(28), r.Wasted)
+	assert.Equal(t, alg.AlgName, r.Algorithm)
+}
+
+func relPaths(dir string, hashes []*Hash) []string {
+	paths := make([]string, len(hashes))
+	for i, h := range hashes {
+		rel, _ := filepath.Rel(dir, h.Path)
+		paths[i] = filepath.ToSlash(rel)
+	}
+	return paths
+}
@@ -0,0 +1,212 @@
+package core
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	. "github.com/little-forest/hasher/common" // nolint:staticcheck
+)
+
+// Bytes read from each of the head and the tail of a file
+// to tell candidates of duplicates apart before reading the whole file.
+const partialHashSize = 64 * 1024
+
+// Reasons why files are not hashed while scanning duplicates
+const (
+	SkipReason_UniqueSize    = "unique size"
+	SkipReason_UniquePartial = "unique head/tail"
+)
+
+// dupScanEntry is a file found while scanning duplicates.
+type dupScanEntry struct {
+	path string
+	id   FileId
+	// false if the file id is not available
+	hasId bool
+}
+
+// AppendDuplicateCandidates calculates hash values of files under given directories
+// which may have identical files, and appends them to the store.
+// Unlike AppendHashDataFromDirectory, files which can't be duplicated are not hashed.
+//
+// To avoid reading data which can't be duplicated, files are narrowed down in stages:
+//
+//  1. Files whose size is unique are dropped without being read.
+//  2. Files whose first and last 64 KiB are unique among files of the same size are dropped.
+//  3. Only the remaining files are fully hashed.
+//
+// Valid hash values stored in attributes are reused as UpdateHash does,
+// and when all files of the same size have them, no file is read at all.
+// Errors are recorded to the summary instead of stopping the scan.
+func (s *HashStore) AppendDuplicateCandidates(dirPaths []string, alg *HashAlg, summary *RunSummary) error {
+	bySize, err := groupFilesBySize(dirPaths, summary)
+	if err != nil {
+		return err
+	}
+
+	sizes := make([]int64, 0, len(bySize))
+	for size := range bySize {
+		sizes = append(sizes, size)
+	}
+	sort.Slice(sizes, func(i, j int) bool { return sizes[i] < sizes[j] })
+
+	for _, size := range sizes {
+		entries := bySize[size]
+		if !hasDistinctFiles(entries) {
+			for _, e := range entries {
+				summary.AddSkipped(e.path, SkipReason_UniqueSize)
+			}
+			continue
+		}
+
+		candidates := [][]*dupScanEntry{entries}
+		if size > partialHashSize*2 && !hasValidStoredHashes(entries, alg) {
+			candidates = groupFilesByPartialHash(entries, size, alg, summary)
+		}
+		for _, c := range candidates {
+			for _, e := range c {
+				changed, hash, err := UpdateHash(e.path, alg, false)
+				if err != nil {
+					summary.AddFailed(e.path, ErrorOp_Hash, err)
+					continue
+				}
+				summary.AddHashed(changed)
+				s.Put(hash)
+			}
+		}
+	}
+	return nil
+}
+
+// groupFilesBySize walks given directories and groups found files by their size.
+// The same file is counted only once even if directories overlap.
+func groupFilesBySize(dirPaths []string, summary *RunSummary) (map[int64][]*dupScanEntry, error) {
+	bySize := make(map[int64][]*dupScanEntry)
+	found := make(map[string]bool)
+	for _, dirPath := range dirPaths {
+		err := walkTargets(dirPath, summary, func(path string, d fs.DirEntry) error {
+			if d.IsDir() {
+				return nil
+			}
+			absPath, err := filepath.Abs(path)
+			if err != nil {
+				summary.AddFailed(path, ErrorOp_Stat, err)
+				return nil
+			}
+			if found[absPath] {
+				return nil
+			}
+			found[absPath] = true
+
+			info, err := os.Stat(absPath)
+			if err != nil {
+				summary.AddFailed(absPath, ErrorOp_Stat, err)
+				return nil
+			}
+			summary.AddScanned()
+			e := &dupScanEntry{path: absPath}
+			e.id, e.hasId = GetFileId(info)
+			bySize[info.Size()] = append(bySize[info.Size()], e)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return bySize, nil
+}
+
+// hasDistinctFiles returns true if entries contain at least two files
+// which are not hard links of the same file.
+func hasDistinctFiles(entries []*dupScanEntry) bool {
+	if len(entries) < 2 {
+		return false
+	}
+	first := entries[0]
+	for _, e := range entries[1:] {
+		if !first.hasId || !e.hasId || e.id != first.id {
+			return true
+		}
+	}
+	return false
+}
+
+// hasValidStoredHashes returns true if all files have valid hash values in their attributes,
+// so that they can be compared without being read.
+func hasValidStoredHashes(entries []*dupScanEntry, alg *HashAlg) bool {
+	for _, e := range entries {
+		if h, err := getValidStoredHash(e.path, alg); err != nil || h == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// groupFilesByPartialHash groups files of the same size by hash values of their head and tail,
+// and returns only groups which may have duplicates.
+func groupFilesByPartialHash(entries []*dupScanEntry, size int64, alg *HashAlg, summary *RunSummary) [][]*dupScanEntry {
+	byPartial := make(map[string][]*dupScanEntry)
+	keys := make([]string, 0)
+	for _, e := range entries {
+		key, err := calcPartialHash(e.path, size, alg)
+		if err != nil {
+			summary.AddFailed(e.path, ErrorOp_Hash, err)
+			continue
+		}
+		if _, ok := byPartial[key]; !ok {
+			keys = append(keys, key)
+		}
+		byPartial[key] = append(byPartial[key], e)
+	}
+
+	groups := make([][]*dupScanEntry, 0)
+	for _, key := range keys {
+		sames := byPartial[key]
+		if !hasDistinctFiles(sames) {
+			for _, e := range sames {
+				summary.AddSkipped(e.path, SkipReason_UniquePartial)
+			}
+			continue
+		}
+		groups = append(groups, sames)
+	}
+	return groups
+}
+
+// calcPartialHash calculates a hash value of the first and last 64 KiB of the file.
+// The size must be the one when the file was found, so that a file changed since then doesn't match.
+func calcPartialHash(path string, size int64, alg *HashAlg) (string, error) {
+	file, err := OpenFile(path)
+	if err != nil {
+		return "", err
+	}
+	// nolint:errcheck
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+	if info.Size() != size {
+		return "", fmt.Errorf("file has been changed while scanning : %s", path)
+	}
+
+	h := alg.New()
+	buf := make([]byte, partialHashSize)
+	for _, offset := range []int64{0, size - partialHashSize} {
+		var r io.Reader = io.NewSectionReader(file, offset, partialHashSize)
+		if throttle != nil {
+			r = &throttledReader{r: r, t: throttle}
+		}
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return "", err
+		}
+		h.Write(buf) // nolint:errcheck
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,85 @@
+package core
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAppendDuplicateCandidates(t *testing.T) {
+	alg := NewDefaultHashAlg()
+	dir := t.TempDir()
+
+	large := bytes.Repeat([]byte("0123456789abcdef"), partialHashSize*3/16)
+	middleChanged := append([]byte{}, large...)
+	middleChanged[len(middleChanged)/2] = 'x'
+
+	files := map[string][]byte{
+		"a/large.bin":  large,
+		"b/large.bin":  large,
+		"a/tail.bin":   append(large[:len(large):len(large)], 'a'),
+		"b/tail.bin":   append(large[:len(large):len(large)], 'b'),
+		"a/middle.bin": middleChanged,
+		"a/small.txt":  []byte("small"),
+		"b/small.txt":  []byte("small"),
+		"a/uniq.txt":   []byte("unique size"),
+	}
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		assert.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		assert.NoError(t, os.WriteFile(p, content, 0o644))
+	}
+
+	store := NewHashStore()
+	summary := NewRunSummary()
+	// overlapping directories are scanned only once
+	err := store.AppendDuplicateCandidates([]string{dir, filepath.Join(dir, "b")}, alg, summary)
+	assert.NoError(t, err)
+	assert.NoError(t, summary.Err())
+
+	groups := FindDuplicateGroups(store)
+	if assert.Len(t, groups, 2) {
+		assert.Equal(t, []string{"a/large.bin", "b/large.bin"}, relPaths(dir, groups[0].Files))
+		assert.Equal(t, []string{"a/small.txt", "b/small.txt"}, relPaths(dir, groups[1].Files))
+	}
+
+	// files which can't be duplicated are not hashed
+	for _, name := range []string{"a/uniq.txt", "a/tail.bin", "b/tail.bin"} {
+		h, e := GetHash(filepath.Join(dir, filepath.FromSlash(name)), alg)
+		assert.NoError(t, e)
+		assert.Nil(t, h, name)
+	}
+	// same head and tail, but different contents
+	h, err := GetHash(filepath.Join(dir, "a", "middle.bin"), alg)
+	assert.NoError(t, err)
+	assert.NotNil(t, h)
+
+	assert.Equal(t, 8, summary.Scanned())
+	assert.Equal(t, 5, summary.Hashed())
+	assert.Equal(t, map[string]int{SkipReason_UniqueSize: 1, SkipReason_UniquePartial: 2}, summary.Skipped())
+}
+
+func TestAppendDuplicateCandidates_storedHashes(t *testing.T) {
+	alg := NewDefaultHashAlg()
+	dir := t.TempDir()
+
+	large := bytes.Repeat([]byte("0123456789abcdef"), partialHashSize*3/16)
+	p1 := filepath.Join(dir, "1.bin")
+	p2 := filepath.Join(dir, "2.bin")
+	assert.NoError(t, os.WriteFile(p1, large, 0o644))
+	assert.NoError(t, os.WriteFile(p2, large, 0o644))
+	for _, p := range []string{p1, p2} {
+		_, _, err := UpdateHash(p, alg, false)
+		assert.NoError(t, err)
+	}
+
+	summary := NewRunSummary()
+	store := NewHashStore()
+	assert.NoError(t, store.AppendDuplicateCandidates([]string{dir}, alg, summary))
+	assert.Len(t, FindDuplicateGroups(store), 1)
+	assert.Equal(t, 0, summary.Updated())
+	assert.Equal(t, 2, summary.Unchanged())
+}
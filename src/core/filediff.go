@@ -122,3 +122,25 @@ func (f *FileDiff) StatusMark() string {
 	}
 	return ""
 }
+
+func (s DiffStatus) String() string {
+	switch s {
+	case UNKNOWN:
+		return "unknown"
+	case ADDED:
+		return "added"
+	case SAME:
+		return "same"
+	case NOT_SAME_NEW:
+		return "newer"
+	case NOT_SAME_OLD:
+		return "older"
+	case NOT_SAME:
+		return "different"
+	case RENAMED:
+		return "renamed"
+	case REMOVED:
+		return "removed"
+	}
+	return ""
+}
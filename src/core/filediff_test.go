@@ -11,7 +11,7 @@ import (
 
 func TestNewFileDiff(t *testing.T) {
 	alg := NewDefaultHashAlg()
-	path, expectedHashValue := makeSingleDummyFile(t, &alg.Alg)
+	path, expectedHashValue := makeSingleDummyFile(t, alg)
 	expectedHashBytes, _ := hex.DecodeString(expectedHashValue)
 
 	d, err := NewFileDiff(path, alg)
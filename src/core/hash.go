@@ -2,8 +2,11 @@ package core
 
 import (
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"os"
 	"path/filepath"
+	"time"
 )
 
 // ------------------------------------------------------------------------------
@@ -12,7 +15,9 @@ import (
 //  1: full path
 //  2: file name
 //  3: file modified timestamp (UNIX time)
-//  4: sha1 hash
+//  4: hash value (ALG:VALUE)
+//  5...: additional hash values when multiple algorithms are specified
+//         or additional properties (KEY:VALUE) written by export
 // ===============================================================================
 
 type Hash struct {
@@ -20,6 +25,12 @@ type Hash struct {
 	Alg     *HashAlg
 	Value   []byte
 	ModTime int64 // unix time
+	// modified time (unix time nano, 0 if unknown)
+	ModTimeNano int64
+	// file size (0 if unknown)
+	Size int64
+	// hash checked time (unix time nano, 0 if unknown)
+	CheckedTime int64
 }
 
 func NewHash(path string, alg *HashAlg, value []byte, modTime int64) *Hash {
@@ -50,7 +61,11 @@ func (h Hash) String() string {
 }
 
 func (h Hash) Json() string {
-	return fmt.Sprintf("{\"path\": \"%s\", \"hash\": \"%s:%s\"}", h.Path, h.Alg.AlgName, h.String())
+	b, err := json.Marshal(NewHashRecord(h.Path, []*Hash{&h}))
+	if err != nil {
+		return ""
+	}
+	return string(b)
 }
 
 func (h Hash) Tsv() string {
@@ -58,6 +73,15 @@ func (h Hash) Tsv() string {
 	return fmt.Sprintf("%s\t%s\t%d\t%s:%s", h.Path, basename, h.ModTime, h.Alg.AlgName, h.String())
 }
 
+// HashesTsv returns one TSV line containing all given hash values of the same file.
+func HashesTsv(hashes []*Hash) string {
+	line := hashes[0].Tsv()
+	for _, h := range hashes[1:] {
+		line += fmt.Sprintf("\t%s:%s", h.Alg.AlgName, h.String())
+	}
+	return line
+}
+
 func (h Hash) HasSameHashValue(other *Hash) bool {
 	if other == nil {
 		return false
@@ -73,3 +97,52 @@ func (h Hash) HasSameHashValue(other *Hash) bool {
 	}
 	return true
 }
+
+// HashRecord is the JSON representation of hash values of a file.
+type HashRecord struct {
+	Path string `json:"path"`
+	// modified time (see FormatRecordTime)
+	ModTime string `json:"mtime,omitempty"`
+	// hash checked time (see FormatRecordTime)
+	CheckedTime string      `json:"htime,omitempty"`
+	Hashes      []HashValue `json:"hashes"`
+	Size        int64       `json:"size"`
+}
+
+type HashValue struct {
+	Algorithm string `json:"algorithm"`
+	Value     string `json:"value"`
+}
+
+// NewHashRecord returns HashRecord of given hash values of the same file.
+// Size and times are taken from the first hash value.
+func NewHashRecord(path string, hashes []*Hash) HashRecord {
+	r := HashRecord{
+		Path:   path,
+		Hashes: make([]HashValue, len(hashes)),
+	}
+	for i, h := range hashes {
+		r.Hashes[i] = HashValue{Algorithm: h.Alg.AlgName, Value: h.String()}
+	}
+	if len(hashes) > 0 {
+		h := hashes[0]
+		r.Size = h.Size
+		if h.ModTimeNano != 0 {
+			r.ModTime = FormatRecordTime(time.Unix(0, h.ModTimeNano))
+		} else if h.ModTime != 0 {
+			r.ModTime = FormatRecordTime(time.Unix(h.ModTime, 0))
+		}
+		if h.CheckedTime != 0 {
+			r.CheckedTime = FormatRecordTime(time.Unix(0, h.CheckedTime))
+		}
+	}
+	return r
+}
+
+// NewFileRecord returns HashRecord of the file which has no hash value.
+func NewFileRecord(path string, info os.FileInfo) HashRecord {
+	r := NewHashRecord(path, nil)
+	r.Size = info.Size()
+	r.ModTime = FormatRecordTime(info.ModTime())
+	return r
+}
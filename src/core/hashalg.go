@@ -16,39 +16,78 @@ limitations under the License.
 package core
 
 import (
-	"crypto"
-	_ "crypto/sha1"
-	"strings"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"hash"
+	"hash/crc32"
+	"sort"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/zeebo/xxh3"
+	"lukechampine.com/blake3"
 )
 
+const DefaultHashAlgName = "sha1"
+
 type HashAlg struct {
+	// New returns a new hash.Hash computing this algorithm
+	New      func() hash.Hash
 	AlgName  string
 	AttrName string
-	Alg      crypto.Hash
+	// Digest length in bytes
+	Size int
 }
 
-func NewDefaultHashAlg() *HashAlg {
-	return NewHashAlg(crypto.SHA1)
+// hashAlgRegistry holds all available hash algorithms keyed by it's name.
+var hashAlgRegistry = make(map[string]*HashAlg)
+
+func init() {
+	RegisterHashAlg("sha1", sha1.Size, sha1.New)
+	RegisterHashAlg("sha256", sha256.Size, sha256.New)
+	RegisterHashAlg("sha512", sha512.Size, sha512.New)
+	RegisterHashAlg("blake3", 32, func() hash.Hash {
+		return blake3.New(32, nil)
+	})
+	RegisterHashAlg("xxh64", 8, func() hash.Hash {
+		return xxhash.New()
+	})
+	RegisterHashAlg("xxh3", 8, func() hash.Hash {
+		return xxh3.New()
+	})
+	crc32cTable := crc32.MakeTable(crc32.Castagnoli)
+	RegisterHashAlg("crc32c", crc32.Size, func() hash.Hash {
+		return crc32.New(crc32cTable)
+	})
 }
 
-func NewHashAlg(alg crypto.Hash) *HashAlg {
-	algName := strings.ToLower(strings.ReplaceAll(alg.String(), "-", ""))
-	hashAlg := &HashAlg{
-		Alg:      alg,
+// RegisterHashAlg registers a hash algorithm.
+// The hash value is stored to the extended attribute named `user.hasher.<algName>`.
+func RegisterHashAlg(algName string, size int, newFunc func() hash.Hash) {
+	hashAlgRegistry[algName] = &HashAlg{
+		New:      newFunc,
 		AlgName:  algName,
 		AttrName: Xattr_prefix + "." + algName,
+		Size:     size,
 	}
-	return hashAlg
 }
 
-func NewHashAlgFromString(algName string) *HashAlg {
-	switch algName {
-	case "sha1":
-		return NewHashAlg(crypto.SHA1)
-	case "sha256":
-		return NewHashAlg(crypto.SHA256)
-	case "sha512":
-		return NewHashAlg(crypto.SHA512)
+// HashAlgNames returns sorted names of all registered hash algorithms.
+func HashAlgNames() []string {
+	names := make([]string, 0, len(hashAlgRegistry))
+	for n := range hashAlgRegistry {
+		names = append(names, n)
 	}
-	return nil
+	sort.Strings(names)
+	return names
+}
+
+func NewDefaultHashAlg() *HashAlg {
+	return NewHashAlgFromString(DefaultHashAlgName)
+}
+
+// NewHashAlgFromString returns the registered hash algorithm.
+// When given name is not registered, it will return nil.
+func NewHashAlgFromString(algName string) *HashAlg {
+	return hashAlgRegistry[algName]
 }
@@ -0,0 +1,53 @@
+package core
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewHashAlgFromString(t *testing.T) {
+	for _, name := range HashAlgNames() {
+		alg := NewHashAlgFromString(name)
+		assert.NotNil(t, alg, name)
+		assert.Equal(t, name, alg.AlgName)
+		assert.Equal(t, Xattr_prefix+"."+name, alg.AttrName)
+		assert.Equal(t, alg.Size, alg.New().Size(), name)
+	}
+
+	assert.Nil(t, NewHashAlgFromString("unknown"))
+}
+
+func TestHashAlg_digest(t *testing.T) {
+	cases := []struct {
+		algName  string
+		input    string
+		expected string
+	}{
+		{"sha1", "abc", "a9993e364706816aba3e25717850c26c9cd0d89d"},
+		{"sha256", "abc", "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{"blake3", "", "af1349b9f5f9a1a6a0404dea36dcc9499bcb25c9adc112b7cc9a93cae41f3262"},
+		{"xxh64", "", "ef46db3751d8e999"},
+		{"xxh3", "", "2d06800538d394c2"},
+		{"crc32c", "123456789", "e3069283"},
+	}
+
+	for _, c := range cases {
+		alg := NewHashAlgFromString(c.algName)
+		h := alg.New()
+		h.Write([]byte(c.input))
+		assert.Equal(t, c.expected, fmt.Sprintf("%x", h.Sum(nil)), c.algName)
+	}
+}
+
+func TestCalcHash_allAlgorithms(t *testing.T) {
+	for _, name := range HashAlgNames() {
+		alg := NewHashAlgFromString(name)
+		path, expectedHashValue := makeSingleDummyFile(t, alg)
+
+		hash, err := CalcHash(path, alg)
+		assert.NoError(t, err)
+		assert.Equal(t, expectedHashValue, hash.String(), name)
+	}
+}
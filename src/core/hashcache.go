@@ -0,0 +1,117 @@
+package core
+
+import (
+	"os"
+	"sync"
+
+	. "github.com/little-forest/hasher/common" // nolint:staticcheck
+)
+
+// hashCache keeps hash values calculated in this process by file id,
+// so that a file reached by several paths (hard links or followed symbolic links)
+// is read only once.
+//
+// Since any file may be reached by several paths while following symbolic links,
+// the number of entries is limited, and arbitrary entries are evicted when it is exceeded.
+// An evicted file is only read again.
+type hashCache struct {
+	entries map[FileId]*hashCacheEntry
+	// max number of entries
+	limit int
+	mu    sync.Mutex
+}
+
+type hashCacheEntry struct {
+	// algorithm name -> hash value
+	values  map[string][]byte
+	size    int64
+	modTime int64
+}
+
+// Max number of files whose hash values are kept in the cache
+const maxHashCacheEntries = 100000
+
+var calculatedHashes = newHashCache(maxHashCacheEntries)
+
+func newHashCache(limit int) *hashCache {
+	return &hashCache{
+		entries: make(map[FileId]*hashCacheEntry),
+		limit:   limit,
+	}
+}
+
+// isSharedFile returns true if the file may be reached by other paths.
+func isSharedFile(info os.FileInfo) bool {
+	if IsFollowingSymlinks() {
+		return true
+	}
+	n, ok := GetNumOfLinks(info)
+	return ok && n > 1
+}
+
+// get returns cached hash values of all given algorithms.
+// When any of them is not cached, it will return nil.
+func (c *hashCache) get(path string, info os.FileInfo, algs []*HashAlg) []*Hash {
+	if !isSharedFile(info) {
+		return nil
+	}
+	id, ok := GetFileId(info)
+	if !ok {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[id]
+	if !ok || entry.size != info.Size() || entry.modTime != info.ModTime().UnixNano() {
+		return nil
+	}
+	hashes := make([]*Hash, len(algs))
+	for i, alg := range algs {
+		v, ok := entry.values[alg.AlgName]
+		if !ok {
+			return nil
+		}
+		hashes[i] = NewHash(path, alg, v, info.ModTime().Unix())
+		hashes[i].Size = info.Size()
+	}
+	return hashes
+}
+
+func (c *hashCache) put(info os.FileInfo, hashes []*Hash) {
+	if !isSharedFile(info) {
+		return
+	}
+	id, ok := GetFileId(info)
+	if !ok {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[id]
+	if !ok || entry.size != info.Size() || entry.modTime != info.ModTime().UnixNano() {
+		if !ok && len(c.entries) >= c.limit {
+			c.evict()
+		}
+		entry = &hashCacheEntry{
+			values:  make(map[string][]byte),
+			size:    info.Size(),
+			modTime: info.ModTime().UnixNano(),
+		}
+		c.entries[id] = entry
+	}
+	for _, h := range hashes {
+		entry.values[h.Alg.AlgName] = h.Value
+	}
+}
+
+// evict removes an arbitrary entry.
+func (c *hashCache) evict() {
+	for id := range c.entries {
+		delete(c.entries, id)
+		return
+	}
+}
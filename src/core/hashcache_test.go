@@ -0,0 +1,80 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/little-forest/hasher/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateHashes_hardLink(t *testing.T) {
+	alg := NewDefaultHashAlg()
+	path, expected := makeSingleDummyFile(t, alg)
+	link := filepath.Join(t.TempDir(), "link.txt")
+	assert.NoError(t, os.Link(path, link))
+
+	_, _, err := UpdateHashes(path, []*HashAlg{alg}, true)
+	assert.NoError(t, err)
+
+	// hash value of the hard link is already calculated
+	info, err := os.Stat(link)
+	assert.NoError(t, err)
+	cached := calculatedHashes.get(link, info, []*HashAlg{alg})
+	if assert.NotNil(t, cached) {
+		assert.Equal(t, expected, cached[0].String())
+		assert.Equal(t, link, cached[0].Path)
+	}
+
+	_, hashes, err := UpdateHashes(link, []*HashAlg{alg}, true)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, hashes[0].String())
+}
+
+func TestHashCache_limit(t *testing.T) {
+	alg := NewDefaultHashAlg()
+	dir := t.TempDir()
+	c := newHashCache(2)
+
+	common.SetFollowSymlinks(true)
+	t.Cleanup(func() { common.SetFollowSymlinks(false) })
+
+	for i := 0; i < 5; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("file%d.txt", i))
+		makeDummyFile(t, path, alg)
+		hash, err := CalcHash(path, alg)
+		assert.NoError(t, err)
+		info, err := os.Stat(path)
+		assert.NoError(t, err)
+
+		c.put(info, []*Hash{hash})
+		assert.NotNil(t, c.get(path, info, []*HashAlg{alg}))
+		assert.LessOrEqual(t, len(c.entries), 2)
+	}
+}
+
+func TestSplitHardLinks(t *testing.T) {
+	alg := NewDefaultHashAlg()
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src.txt")
+	v := makeDummyFile(t, src, alg)
+	link := filepath.Join(dir, "link.txt")
+	assert.NoError(t, os.Link(src, link))
+	dup := filepath.Join(t.TempDir(), "dup.txt")
+	assert.NoError(t, os.WriteFile(dup, nil, 0o644))
+
+	hash := func(p string) *Hash {
+		h, err := NewHashFromString(p, alg, v, 0)
+		assert.NoError(t, err)
+		return h
+	}
+	duplicates, hardLinks := SplitHardLinks(hash(src), []*Hash{hash(link), hash(dup), hash(filepath.Join(dir, "not_exist"))})
+	if assert.Equal(t, 2, len(duplicates)) {
+		assert.Equal(t, dup, duplicates[0].Path)
+	}
+	if assert.Equal(t, 1, len(hardLinks)) {
+		assert.Equal(t, link, hardLinks[0].Path)
+	}
+}
@@ -3,12 +3,14 @@ package core
 import (
 	"bufio"
 	"fmt"
+	"hash"
 	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strconv"
+	"sync"
 	"time"
 
 	. "github.com/little-forest/hasher/common" // nolint:staticcheck
@@ -35,17 +37,31 @@ const Xattr_hashCheckedTime = Xattr_prefix + ".htime"
 //	hash value : *Hash
 //	error : error
 func UpdateHash(path string, alg *HashAlg, forceUpdate bool) (bool, *Hash, error) {
-	changed, hash, err := UpdateHashStrictly(path, alg, forceUpdate)
+	changed, hashes, err := UpdateHashes(path, []*HashAlg{alg}, forceUpdate)
+	if hashes == nil {
+		return changed, nil, err
+	}
+	return changed, hashes[0], err
+}
+
+// UpdateHashes updates specified file's hash values of all given algorithms.
+// If the update of an attribute fails, a warning is displayed instead of returning an error.
+//
+//	changed : bool
+//	hash values : []*Hash (same order as algs)
+//	error : error
+func UpdateHashes(path string, algs []*HashAlg, forceUpdate bool) (bool, []*Hash, error) {
+	changed, hashes, err := UpdateHashesStrictly(path, algs, forceUpdate)
 	if err != nil {
 		if errors.As(err, Err_updateError) {
 			// Show warning and ignore error
 			ShowWarn("Failed to update attribute : %s", err.Error())
-			return changed, hash, nil
+			return changed, hashes, nil
 		} else {
 			return false, nil, err
 		}
 	}
-	return changed, hash, err
+	return changed, hashes, err
 }
 
 // UpdateHashStictly updates specified file's hash value.
@@ -55,6 +71,27 @@ func UpdateHash(path string, alg *HashAlg, forceUpdate bool) (bool, *Hash, error
 //	hash value : *Hash
 //	error : error
 func UpdateHashStrictly(path string, alg *HashAlg, forceUpdate bool) (bool, *Hash, error) {
+	changed, hashes, err := UpdateHashesStrictly(path, []*HashAlg{alg}, forceUpdate)
+	if hashes == nil {
+		return changed, nil, err
+	}
+	return changed, hashes[0], err
+}
+
+// UpdateHashesStrictly updates specified file's hash values of all given algorithms.
+// Hash values which need to be updated are calculated by reading the file only once.
+// Returns an UpdateError if the update of an attribute fails.
+//
+//	changed : bool
+//	hash values : []*Hash (same order as algs)
+//	error : error
+func UpdateHashesStrictly(path string, algs []*HashAlg, forceUpdate bool) (bool, []*Hash, error) {
+	return updateHashesStrictly(path, algs, forceUpdate, nil)
+}
+
+// updateHashesStrictly is UpdateHashesStrictly which calls onRead with the number of bytes read,
+// while calculating hash values.
+func updateHashesStrictly(path string, algs []*HashAlg, forceUpdate bool, onRead func(n int64)) (bool, []*Hash, error) {
 	file, err := OpenFile(path)
 	if err != nil {
 		return false, nil, err
@@ -69,76 +106,172 @@ func UpdateHashStrictly(path string, alg *HashAlg, forceUpdate bool) (bool, *Has
 	size := fmt.Sprint(info.Size())
 	modTime := strconv.FormatInt(info.ModTime().UnixNano(), 10)
 
-	var changed bool
-	curHash := GetXattr(file, alg.AttrName)
-	if curHash != "" {
-		// check if existing hash value is valid
-		// If the file size and modtime have not changed, it is considered correct.
-		if curSize := GetXattr(file, Xattr_size); size != curSize {
-			changed = true
-		} else if curMtime := GetXattr(file, Xattr_modifiedTime); modTime != curMtime {
-			changed = true
-		}
+	hashes := make([]*Hash, len(algs))
+
+	// check if existing hash values are valid
+	// If the file size and modtime have not changed, they are considered correct.
+	changed := GetAttr(file, Xattr_size) != size || GetAttr(file, Xattr_modifiedTime) != modTime
+
+	targetAlgs := make([]*HashAlg, 0, len(algs))
+	for i, alg := range algs {
 		if !forceUpdate && !changed {
-			// update only checked time
-			err := updateHashCheckedTime(file) // nolint:govet
-			if err != nil {
-				err = NewUpdateError(err)
+			curHash := GetAttr(file, alg.AttrName)
+			if curHash != "" {
+				hashes[i], _ = NewHashFromString(path, alg, curHash, info.ModTime().Unix())
 			}
-			hash, _ := NewHashFromString(path, alg, curHash, info.ModTime().Unix())
-			return false, hash, err
+		}
+		if hashes[i] == nil {
+			targetAlgs = append(targetAlgs, alg)
 		}
 	}
 
-	// do calculate hash value
-	hash, err := CalcHash(path, alg)
-	if err != nil {
-		return false, nil, err
+	if len(targetAlgs) == 0 {
+		// update only checked time
+		err := updateHashCheckedTime(file) // nolint:govet
+		if err != nil {
+			return false, hashes, NewUpdateError(err)
+		}
+		setFileInfo(hashes, file, info)
+		if err := recordToCatalog(file, hashes); err != nil {
+			return false, hashes, NewUpdateError(err)
+		}
+		return false, hashes, nil
+	}
+
+	// do calculate hash values
+	// The same file reached by another path is read only once.
+	calculated := calculatedHashes.get(path, info, targetAlgs)
+	if calculated == nil {
+		calculated, err = calcHashes(path, targetAlgs, onRead)
+		if err != nil {
+			return false, nil, err
+		}
+		calculatedHashes.put(info, calculated)
+	}
+	for i, j := 0, 0; i < len(hashes); i++ {
+		if hashes[i] == nil {
+			hashes[i] = calculated[j]
+			j++
+		}
 	}
 
 	// update attributes
-	if err := SetXattr(file, alg.AttrName, hash.String()); err != nil {
-		return true, hash, NewUpdateError(err)
+	if changed {
+		// hash values of other algorithms are stale,
+		// and would look valid with the new size and mtime
+		if err := ClearAttr(file); err != nil {
+			return true, hashes, NewUpdateError(err)
+		}
+	}
+	for _, hash := range calculated {
+		if err := SetAttr(file, hash.Alg.AttrName, hash.String()); err != nil {
+			return true, hashes, NewUpdateError(err)
+		}
 	}
 	if err := updateHashCheckedTime(file); err != nil {
-		return true, hash, NewUpdateError(err)
+		return true, hashes, NewUpdateError(err)
+	}
+	if err := SetAttr(file, Xattr_size, size); err != nil {
+		return true, hashes, NewUpdateError(err)
+	}
+	if err := SetAttr(file, Xattr_modifiedTime, modTime); err != nil {
+		return true, hashes, NewUpdateError(err)
 	}
-	if err := SetXattr(file, Xattr_size, size); err != nil {
-		return true, hash, NewUpdateError(err)
+	setFileInfo(hashes, file, info)
+	if err := recordToCatalog(file, hashes); err != nil {
+		return true, hashes, NewUpdateError(err)
 	}
-	if err := SetXattr(file, Xattr_modifiedTime, modTime); err != nil {
-		return true, hash, NewUpdateError(err)
+
+	return true, hashes, nil
+}
+
+// setFileInfo sets size and hash checked time of the file to hash values.
+func setFileInfo(hashes []*Hash, file *os.File, info os.FileInfo) {
+	htime, ok := GetHashCheckedTime(file)
+	for _, h := range hashes {
+		h.Size = info.Size()
+		h.ModTimeNano = info.ModTime().UnixNano()
+		if ok {
+			h.CheckedTime = htime.UnixNano()
+		}
 	}
+}
 
-	return true, hash, nil
+// recordToCatalog records hash values to the catalog if it is enabled.
+func recordToCatalog(file *os.File, hashes []*Hash) error {
+	c, err := activeCatalog()
+	if err != nil {
+		return err
+	}
+	if c == nil {
+		return nil
+	}
+	if err = c.Record(file, hashes); err != nil {
+		return fmt.Errorf("failed to record to catalog : %s", err.Error())
+	}
+	return nil
 }
 
 func updateHashCheckedTime(f *os.File) error {
 	htime := strconv.FormatInt(time.Now().UTC().UnixNano(), 10)
-	if err := SetXattr(f, Xattr_hashCheckedTime, htime); err != nil {
+	if err := SetAttr(f, Xattr_hashCheckedTime, htime); err != nil {
 		return err
 	}
 	return nil
 }
 
 func CalcHash(path string, hashAlg *HashAlg) (*Hash, error) {
-	if !hashAlg.Alg.Available() {
-		return nil, fmt.Errorf("no implementation")
+	hashes, err := CalcHashes(path, []*HashAlg{hashAlg})
+	if err != nil {
+		return nil, err
 	}
+	return hashes[0], nil
+}
+
+// CalcHashes calculates hash values of all given algorithms by reading the file only once.
+func CalcHashes(path string, hashAlgs []*HashAlg) ([]*Hash, error) {
+	return calcHashes(path, hashAlgs, nil)
+}
 
+// calcHashes is CalcHashes which calls onRead with the number of bytes read.
+func calcHashes(path string, hashAlgs []*HashAlg, onRead func(n int64)) ([]*Hash, error) {
 	r, err := OpenFile(path)
 	if err != nil {
 		return nil, err
 	}
+	// nolint:errcheck
+	defer r.Close()
 
-	hash := hashAlg.Alg.New()
-	if _, err := io.CopyBuffer(hash, r, make([]byte, hashBufSize)); err != nil {
+	hs := make([]hash.Hash, len(hashAlgs))
+	writers := make([]io.Writer, len(hashAlgs))
+	for i, alg := range hashAlgs {
+		hs[i] = alg.New()
+		writers[i] = hs[i]
+	}
+
+	var src io.Reader = r
+	if throttle != nil {
+		src = &throttledReader{r: r, t: throttle}
+	}
+	if onRead != nil {
+		src = &progressReader{r: src, onRead: onRead}
+	}
+
+	if _, err = io.CopyBuffer(hashingWriter{w: io.MultiWriter(writers...)}, src, make([]byte, hashBufSize)); err != nil {
 		return nil, err
 	}
 
-	info, _ := os.Stat(path)
+	info, err := r.Stat()
+	if err != nil {
+		return nil, err
+	}
 
-	return NewHash(path, hashAlg, hash.Sum(nil), info.ModTime().Unix()), nil
+	hashes := make([]*Hash, len(hashAlgs))
+	for i, alg := range hashAlgs {
+		hashes[i] = NewHash(path, alg, hs[i].Sum(nil), info.ModTime().Unix())
+		hashes[i].Size = info.Size()
+	}
+	return hashes, nil
 }
 
 // Get hash value.
@@ -157,9 +290,12 @@ func GetHash(path string, alg *HashAlg) (*Hash, error) {
 		return nil, err
 	}
 
-	curHash := GetXattr(file, alg.AttrName)
+	curHash := GetAttr(file, alg.AttrName)
 	if curHash != "" {
 		hash, _ := NewHashFromString(path, alg, curHash, info.ModTime().Unix())
+		if hash != nil {
+			setFileInfo([]*Hash{hash}, file, info)
+		}
 		return hash, nil
 	} else {
 		return nil, nil
@@ -168,6 +304,10 @@ func GetHash(path string, alg *HashAlg) (*Hash, error) {
 
 type UpdateTask struct {
 	Path string
+	// index of the root path given to ConcurrentUpdateHash
+	Root int
+	// order of the file in the root (-1 : failed file retried from before the walk position)
+	Seq int
 }
 
 func NewUpdateTask(path string) UpdateTask {
@@ -178,9 +318,9 @@ func NewUpdateTask(path string) UpdateTask {
 
 type UpdateResult struct {
 	Err      error
-	Task     UpdateTask
 	Hash     string
 	Message  string
+	Task     UpdateTask
 	WorkerId int
 }
 
@@ -194,135 +334,247 @@ func NewUpdateResult(workerId int, task UpdateTask, hash string, message string,
 	}
 }
 
-func ConcurrentUpdateHash(paths []string, alg *HashAlg, numOfWorkers int, forceUpdate bool, notifier ProgressNotifier) error {
-	total := CountAllFiles(paths, notifier.IsVerbose())
+// Reason why files are skipped because they have no hash value
+const SkipReason_NoHash = "no hash"
+
+// ErrInterrupted is returned when the update is stopped before all files are done.
+var ErrInterrupted = errors.New("interrupted")
+
+// ConcurrentUpdateHash updates hash values of all files under given paths.
+// Files are processed in a lane of each device by the scheduler,
+// so that files on different devices are read in parallel.
+//
+// When journal is not nil, done files are recorded to it,
+// and files already recorded in it are skipped.
+// When the scheduler is stopped, it waits for running tasks and returns ErrInterrupted.
+//
+// Results and errors of all files are recorded to the summary.
+// When some files could not be processed, it returns PartialFailureError.
+func ConcurrentUpdateHash(paths []string, algs []*HashAlg, forceUpdate bool, sched *IOScheduler, journal *UpdateJournal, summary *RunSummary, notifier ProgressNotifier) error {
+	// the total is estimated while files are listed, unless it is recorded in the journal
+	total := journal.Total()
+	if total < 0 {
+		counter := StartCountingFiles(paths)
+		defer counter.Stop()
+		notifier.SetFileCounter(counter)
+	}
+	completed := journal.Completed()
 
 	notifier.SetTotal(total)
 	notifier.Start()
 
-	numOfWorkers = adjustNumOfWorkers(numOfWorkers, runtime.NumCPU())
-
-	tasks := make(chan UpdateTask, numOfWorkers*3)
 	results := make(chan UpdateResult)
 
 	// run workers
-	for i := 0; i < numOfWorkers; i++ {
-		go updateHashWorker(i, tasks, results, alg, forceUpdate, notifier)
-	}
+	sched.Start(func(workerId int, task UpdateTask) {
+		result := updateHashTask(workerId, task, algs, forceUpdate, summary, notifier)
+		// failed files are retried by a resumed run
+		if result.Err == nil {
+			journal.complete(task, true)
+		} else {
+			journal.fail(task)
+		}
+		results <- result
+	})
 
 	// collect target files
-	inputDone := make(chan int)
-	go listTargetFiles(paths, tasks, inputDone)
+	inputDone := make(chan int, 1)
+	go func() {
+		inputDone <- listTargetFiles(paths, sched, journal, summary)
+		sched.Wait()
+		close(results)
+	}()
 
 	// wait
 	remains := -1
-	done := 0
+	done := completed
+loop:
 	for {
 		select {
-		case <-results:
+		case _, ok := <-results:
+			if !ok {
+				break loop
+			}
 			done++
 			notifier.NotifyProgress(done, remains)
 		case taskNum := <-inputDone:
-			remains = taskNum
-		}
-		if remains >= 0 && done >= remains {
-			break
+			remains = completed + taskNum
+			if total < 0 && !sched.IsStopped() {
+				journal.SetTotal(remains)
+			}
+			notifier.NotifyProgress(done, remains)
 		}
 	}
 
 	notifier.Shutdown()
 
-	return nil
+	if sched.IsStopped() {
+		return ErrInterrupted
+	}
+	return summary.Err()
 }
 
-func listTargetFiles(paths []string, tasks chan<- UpdateTask, inputDone chan<- int) {
+// listTargetFiles submits all files under given paths to the scheduler,
+// and returns the number of submitted files.
+// Each path is walked in parallel, so that lanes of all devices are filled at once.
+func listTargetFiles(paths []string, sched *IOScheduler, journal *UpdateJournal, summary *RunSummary) int {
 	var numFiles int
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for i, p := range paths {
+		wg.Add(1)
+		go func(rootIdx int, p string) {
+			defer wg.Done()
+			n := listTargetFilesInPath(rootIdx, p, sched, journal, summary)
+			mu.Lock()
+			numFiles += n
+			mu.Unlock()
+		}(i, p)
+	}
+	wg.Wait()
+
+	return numFiles
+}
 
-	for _, p := range paths {
-		// skip symbolic link
-		isSym, err := IsSymbolicLink(p)
-		if err != nil || isSym {
-			continue
-		}
+func listTargetFilesInPath(rootIdx int, p string, sched *IOScheduler, journal *UpdateJournal, summary *RunSummary) int {
+	var numFiles int
 
-		s, err := os.Stat(p)
-		if err != nil {
-			// TODO: error handling
-			continue
+	s, err := os.Stat(p)
+	if err != nil {
+		summary.AddError(p, ErrorOp_Stat, err)
+		return 0
+	}
+
+	// files up to this position have been done
+	pos := journal.position(rootIdx)
+
+	if !s.IsDir() {
+		if pos != "" && !journal.isFailed(rootIdx, ".") {
+			return 0
+		}
+		task := NewUpdateTask(p)
+		task.Root = rootIdx
+		if pos != "" {
+			task.Seq = -1
 		}
+		summary.AddScanned()
+		sched.Submit(task, deviceOf(s))
+		return 1
+	}
 
-		if !s.IsDir() {
-			tasks <- NewUpdateTask(p)
-			numFiles++
-			continue
+	// walk directory
+	seq := 0
+	walkTargets(p, summary, func(path string, info fs.DirEntry) error { // nolint:errcheck
+		if sched.IsStopped() {
+			return filepath.SkipAll
 		}
 
-		// walk directory
-		// TODO: error check
-		// nolint:staticcheck,ineffassign
-		err = filepath.WalkDir(p, func(path string, info fs.DirEntry, err error) error {
-			if err != nil {
-				return err
+		rel := ""
+		if journal != nil {
+			if r, err := filepath.Rel(p, path); err == nil {
+				rel = filepath.ToSlash(r)
 			}
-			// skip symbolic link
-			isSym, err := IsSymbolicLink(p)
-			if err != nil {
-				return err
+		}
+
+		if info.IsDir() {
+			// skip directories walked before the position, unless they have failed files
+			if pos != "" && rel != "" && rel != "." && walkOrderLess(rel, pos) && !isAncestorPath(rel, pos) &&
+				!journal.hasFailedIn(rootIdx, rel) {
+				return filepath.SkipDir
 			}
-			// skip symbolic link
-			if isSym {
+			return nil
+		}
+
+		task := NewUpdateTask(path)
+		task.Root = rootIdx
+		if pos != "" && rel != "" && !walkOrderLess(pos, rel) {
+			// failed files before the position are retried out of the sequence
+			if !journal.isFailed(rootIdx, rel) {
 				return nil
 			}
-			if !info.IsDir() {
-				tasks <- NewUpdateTask(path)
-				numFiles++
-			}
+			task.Seq = -1
+		} else {
+			task.Seq = seq
+			seq++
+		}
+		if rel != "" && journal.isDone(rootIdx, rel) {
+			journal.complete(task, false)
 			return nil
-		})
-	}
+		}
 
-	inputDone <- numFiles
+		fi, _ := info.Info()
+		summary.AddScanned()
+		sched.Submit(task, deviceOf(fi))
+		numFiles++
+		return nil
+	})
+	return numFiles
 }
 
-func updateHashWorker(id int, tasks <-chan UpdateTask, results chan<- UpdateResult, alg *HashAlg, forceUpdate bool, notifier ProgressNotifier) {
-	for t := range tasks {
-		notifier.NotifyTaskStart(id, t.Path)
-		changed, hash, err := UpdateHash(t.Path, alg, forceUpdate)
-		hashValue := ""
-		msg := ""
-		if err == nil {
-			hashValue = hash.String()
-			if !changed {
-				msg = Mark_OK
-			} else {
-				msg = "[UPDATED]"
-			}
+func updateHashTask(id int, t UpdateTask, algs []*HashAlg, forceUpdate bool, summary *RunSummary, notifier ProgressNotifier) UpdateResult {
+	notifier.NotifyTaskStart(id, t.Path)
+	var read int64
+	changed, hashes, err := updateHashesStrictly(t.Path, algs, forceUpdate, func(n int64) {
+		read += n
+		notifier.NotifyBytes(id, n, true)
+	})
+	hashValue := ""
+	msg := ""
+	if err == nil {
+		hashValue = hashes[0].String()
+		// the file is not read if its hash values are up to date
+		if skipped := hashes[0].Size - read; skipped > 0 {
+			notifier.NotifyBytes(id, skipped, false)
+		}
+		if !changed {
+			msg = Mark_OK
 		} else {
-			msg = Mark_Failed
-			notifier.NotifyError(id, err.Error())
+			msg = "[UPDATED]"
 		}
-		notifier.NotifyTaskDone(id, msg)
-		results <- NewUpdateResult(id, t, hashValue, msg, err)
+		summary.AddHashed(changed)
+	} else {
+		msg = Mark_Failed
+		if errors.As(err, Err_updateError) {
+			err = fmt.Errorf("failed to update attribute : %s", err.Error())
+			summary.AddFailed(t.Path, ErrorOp_Update, err)
+		} else {
+			summary.AddFailed(t.Path, ErrorOp_Hash, err)
+		}
+		notifier.NotifyError(id, err.Error())
 	}
+	notifier.NotifyTaskDone(id, msg)
+	return NewUpdateResult(id, t, hashValue, msg, err)
 }
 
-func adjustNumOfWorkers(numOfWorkers int, numOfCPU int) int {
-	if numOfWorkers < 1 {
-		numOfWorkers = 1
-	}
+// hashingSlots limits the number of goroutines calculating hash values at once.
+// Only calculation is limited, so that files on different devices are still read in parallel.
+var hashingSlots = make(chan struct{}, numOfHashingCPUs(runtime.NumCPU()))
+
+// numOfHashingCPUs returns the number of CPUs used to calculate hash values,
+// leaving one for others when there are more than two.
+func numOfHashingCPUs(numOfCPU int) int {
 	if numOfCPU <= 2 {
 		return 1
 	}
-	if numOfWorkers > numOfCPU-1 {
-		return numOfCPU - 1
-	}
-	return numOfWorkers
+	return numOfCPU - 1
 }
 
-func ListHash(dirPaths []string, alg *HashAlg, w io.Writer, watcher ProgressNotifier, verbose bool, noCheck bool) error {
-	total := CountAllFiles(dirPaths, watcher.IsVerbose())
+// hashingWriter writes to hash.Hash while holding one of hashingSlots.
+type hashingWriter struct {
+	w io.Writer
+}
+
+func (w hashingWriter) Write(p []byte) (int, error) {
+	hashingSlots <- struct{}{}
+	defer func() { <-hashingSlots }()
+	return w.w.Write(p)
+}
 
-	watcher.SetTotal(total)
+func ListHash(dirPaths []string, alg *HashAlg, w io.Writer, watcher ProgressNotifier, verbose bool, noCheck bool) error {
+	counter := StartCountingFiles(dirPaths)
+	defer counter.Stop()
+	watcher.SetFileCounter(counter)
 	watcher.Start()
 
 	bw := bufio.NewWriterSize(w, 16384)
@@ -332,12 +584,12 @@ func ListHash(dirPaths []string, alg *HashAlg, w io.Writer, watcher ProgressNoti
 	var err error
 	count := 1
 	for _, dp := range dirPaths {
-		err = filepath.WalkDir(dp, func(path string, info fs.DirEntry, e error) error {
+		err = WalkDirFiltered(dp, func(path string, info fs.DirEntry, e error) error {
 			if e != nil {
 				return errors.Wrap(e, "failed to filepath.Walk")
 			}
 
-			if info.IsDir() {
+			if info.IsDir() || IsHasherFile(path) {
 				return nil
 			}
 
@@ -371,7 +623,7 @@ func ListHash(dirPaths []string, alg *HashAlg, w io.Writer, watcher ProgressNoti
 					msg = "[UPDATED]"
 				}
 				watcher.NotifyTaskDone(0, msg)
-				watcher.NotifyProgress(count, total)
+				watcher.NotifyProgress(count, -1)
 			}
 			return nil
 		})
@@ -385,31 +637,57 @@ func ListHash(dirPaths []string, alg *HashAlg, w io.Writer, watcher ProgressNoti
 	return err
 }
 
-func ListHash2(paths []string, alg *HashAlg, w io.Writer, watcher ProgressNotifier, verbose bool, updateHash bool) error {
+// ListHash2 writes hash values of given files and files under given directories.
+// Results and errors of all files are recorded to the summary.
+// When some files could not be processed, it returns PartialFailureError.
+func ListHash2(paths []string, algs []*HashAlg, format string, w io.Writer, summary *RunSummary, watcher ProgressNotifier, verbose bool, updateHash bool) error {
+	if err := CheckHashFormat(format, algs); err != nil {
+		return err
+	}
+
 	if verbose {
 		if watcher == nil || !updateHash {
 			return fmt.Errorf("parameter integrity error (may be bug!)")
 		}
 	}
 
-	var total int
+	// the total is unknown until all files are listed
+	total := -1
 	if verbose {
-		total = CountAllFiles(paths, watcher.IsVerbose())
-
-		watcher.SetTotal(total)
+		counter := StartCountingFiles(paths)
+		defer counter.Stop()
+		watcher.SetFileCounter(counter)
 		watcher.Start()
 	}
 
-	bw := bufio.NewWriterSize(w, 16384)
+	hw := newHashListWriter(w, format)
 	// nolint:errcheck
-	defer bw.Flush()
+	defer hw.close()
 
 	count := 1
+	listFile := func(path string) {
+		summary.AddScanned()
+		watcher.NotifyTaskStart(0, path)
+		updated, hashed, err := listSingleFileHash(path, hw, updateHash, algs, watcher)
+		if err != nil {
+			watcher.NotifyError(0, err.Error())
+			summary.AddFailed(path, ErrorOp_Hash, err)
+		} else if hashed {
+			summary.AddHashed(updated)
+		} else {
+			summary.AddSkipped(path, SkipReason_NoHash)
+		}
+		watcher.NotifyTaskDone(0, getUpdateMessage(updated, err))
+		watcher.NotifyProgress(count, total)
+		count++
+	}
+
 	for _, p := range paths {
 
 		t, err := CheckFileType(p)
 		if err != nil {
 			errMsg := fmt.Sprintf("Failed to stat : %s", err.Error())
+			summary.AddFailed(p, ErrorOp_Stat, err)
 			if verbose {
 				watcher.NotifyTaskStart(0, p)
 				watcher.NotifyError(0, errMsg)
@@ -419,48 +697,32 @@ func ListHash2(paths []string, alg *HashAlg, w io.Writer, watcher ProgressNotifi
 			} else {
 				ShowErrorMsg(errMsg)
 			}
+			continue
 		}
 
 		switch t {
 		case RegularFile:
-			watcher.NotifyTaskStart(0, p)
-			updated, err := listSingleFileHash(p, bw, updateHash, alg)
-			if err != nil {
-				watcher.NotifyError(0, err.Error())
-			}
-			watcher.NotifyTaskDone(0, getUpdateMessage(updated, err))
-			watcher.NotifyProgress(count, total)
-			count++
+			listFile(p)
 		case Directory:
-			err := WalkDir(p, func(f *os.File) error {
-				watcher.NotifyTaskStart(0, f.Name())
-				updated, err := listSingleFileHash(f.Name(), bw, updateHash, alg)
-				if err != nil {
-					watcher.NotifyError(0, err.Error())
+			walkTargets(p, summary, func(path string, d fs.DirEntry) error { // nolint:errcheck
+				if !d.IsDir() {
+					listFile(path)
 				}
-				watcher.NotifyTaskDone(0, getUpdateMessage(updated, err))
-				watcher.NotifyProgress(count, total)
-				count++
 				return nil
 			})
-			if err != nil {
-				errMsg := fmt.Sprintf("Failed to walkdir : %s", err.Error())
-				if verbose {
-					watcher.NotifyError(0, errMsg)
-				} else {
-					ShowErrorMsg(errMsg)
-				}
-			}
 		default:
 			ShowWarn("Unsupported file type : %s", p)
+			summary.AddSkipped(p, SkipReason_Irregular)
 		}
 	}
 
 	if verbose {
+		// all files are listed
+		watcher.NotifyProgress(count-1, count-1)
 		watcher.Shutdown()
 	}
 
-	return nil
+	return summary.Err()
 }
 
 func getUpdateMessage(updated bool, err error) string {
@@ -474,31 +736,50 @@ func getUpdateMessage(updated bool, err error) string {
 	}
 }
 
-// listSingleFileHash shows given file's hash value.
+// listSingleFileHash shows given file's hash values.
 // path is representing a regular file path,
 // When update specified true, if the hash has not been computed,
 // calculate it and return true if it has been updated.
-func listSingleFileHash(path string, writer *bufio.Writer, update bool, alg *HashAlg) (bool, error) {
-	var hash *Hash
+//
+// Processed bytes are notified to the watcher.
+//
+//	updated : bool
+//	hash values are shown : bool
+//	error : error
+func listSingleFileHash(path string, writer *hashListWriter, update bool, algs []*HashAlg, watcher ProgressNotifier) (bool, bool, error) {
+	var hashes []*Hash
 	var changed bool
 	var e error
 	absPath, _ := filepath.Abs(path)
 	if update {
-		changed, hash, e = UpdateHashStrictly(absPath, alg, false)
+		var read int64
+		changed, hashes, e = updateHashesStrictly(absPath, algs, false, func(n int64) {
+			read += n
+			watcher.NotifyBytes(0, n, true)
+		})
 		if e != nil {
-			return false, fmt.Errorf("failed to update hash : %s", e.Error())
+			return false, false, fmt.Errorf("failed to update hash : %s", e.Error())
 		}
-	} else {
-		hash, e = GetHash(absPath, alg)
-		if e != nil {
-			return false, fmt.Errorf("failed to get hash : %s", e.Error())
+		if skipped := hashes[0].Size - read; skipped > 0 {
+			watcher.NotifyBytes(0, skipped, false)
 		}
-		if hash == nil {
-			// no-update mode is not intended for ProgresWatcher
-			ShowWarn("The hash value has not yet been calculated. : %s", absPath)
-			return false, nil
+	} else {
+		hashes = make([]*Hash, 0, len(algs))
+		for _, alg := range algs {
+			hash, e := GetHash(absPath, alg)
+			if e != nil {
+				return false, false, fmt.Errorf("failed to get hash : %s", e.Error())
+			}
+			if hash == nil {
+				// no-update mode is not intended for ProgresWatcher
+				ShowWarn("The hash value has not yet been calculated. : %s (%s)", absPath, alg.AlgName)
+				return false, false, nil
+			}
+			hashes = append(hashes, hash)
 		}
 	}
-	fmt.Fprintf(writer, "%s\n", hash.Tsv()) // nolint:errcheck
-	return changed, nil
+	if err := writer.write(path, hashes); err != nil {
+		return changed, false, err
+	}
+	return changed, true, nil
 }
@@ -1,6 +1,7 @@
 package core
 
 import (
+	"os"
 	"path/filepath"
 	"testing"
 
@@ -9,7 +10,7 @@ import (
 
 func TestUpdateHash(t *testing.T) {
 	alg := NewDefaultHashAlg()
-	path, expectedHash := makeSingleDummyFile(t, &alg.Alg)
+	path, expectedHash := makeSingleDummyFile(t, alg)
 
 	changed, hash, err := UpdateHash(path, alg, false)
 
@@ -26,7 +27,7 @@ func TestUpdateHash(t *testing.T) {
 
 func TestCalcHash(t *testing.T) {
 	alg := NewDefaultHashAlg()
-	path, expectedHashValue := makeSingleDummyFile(t, &alg.Alg)
+	path, expectedHashValue := makeSingleDummyFile(t, alg)
 
 	hash, err := CalcHash(path, alg)
 	assert.NoError(t, err)
@@ -41,3 +42,76 @@ func TestCalcHash_failed(t *testing.T) {
 	_, err := CalcHash(path, alg)
 	assert.Error(t, err)
 }
+
+func TestUpdateHashes(t *testing.T) {
+	sha1 := NewHashAlgFromString("sha1")
+	sha256 := NewHashAlgFromString("sha256")
+	blake3 := NewHashAlgFromString("blake3")
+	path, expectedSha1 := makeSingleDummyFile(t, sha1)
+
+	changed, hashes, err := UpdateHashes(path, []*HashAlg{sha1, sha256}, false)
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, 2, len(hashes))
+	assert.Equal(t, expectedSha1, hashes[0].String())
+	assert.Equal(t, sha256, hashes[1].Alg)
+
+	f, err := os.Open(path)
+	assert.NoError(t, err)
+	// nolint:errcheck
+	defer f.Close()
+	assert.Equal(t, expectedSha1, GetXattr(f, sha1.AttrName))
+	assert.Equal(t, hashes[1].String(), GetXattr(f, sha256.AttrName))
+
+	// not changed
+	changed, _, err = UpdateHashes(path, []*HashAlg{sha256, sha1}, false)
+	assert.NoError(t, err)
+	assert.False(t, changed)
+
+	// only missing algorithm is calculated
+	changed, hashes, err = UpdateHashes(path, []*HashAlg{sha1, blake3}, false)
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, expectedSha1, hashes[0].String())
+	assert.Equal(t, hashes[1].String(), GetXattr(f, blake3.AttrName))
+}
+
+func TestUpdateHashes_otherAlgorithmAfterChange(t *testing.T) {
+	sha1 := NewHashAlgFromString("sha1")
+	blake3 := NewHashAlgFromString("blake3")
+	path, _ := makeSingleDummyFile(t, sha1)
+
+	_, _, err := UpdateHashes(path, []*HashAlg{sha1}, false)
+	assert.NoError(t, err)
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	assert.NoError(t, err)
+	_, err = f.WriteString("appended")
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+
+	_, _, err = UpdateHashes(path, []*HashAlg{blake3}, false)
+	assert.NoError(t, err)
+
+	// the sha1 value calculated before the change must not be reused
+	expected, err := CalcHash(path, sha1)
+	assert.NoError(t, err)
+	changed, hashes, err := UpdateHashes(path, []*HashAlg{sha1}, false)
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, expected.String(), hashes[0].String())
+}
+
+func TestCalcHashes(t *testing.T) {
+	sha1 := NewHashAlgFromString("sha1")
+	xxh3 := NewHashAlgFromString("xxh3")
+	path, expectedSha1 := makeSingleDummyFile(t, sha1)
+
+	expectedXxh3, err := CalcHash(path, xxh3)
+	assert.NoError(t, err)
+
+	hashes, err := CalcHashes(path, []*HashAlg{sha1, xxh3})
+	assert.NoError(t, err)
+	assert.Equal(t, expectedSha1, hashes[0].String())
+	assert.Equal(t, expectedXxh3.String(), hashes[1].String())
+}
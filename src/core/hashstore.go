@@ -11,6 +11,7 @@ import (
 	"strconv"
 	"strings"
 
+	. "github.com/little-forest/hasher/common" // nolint:staticcheck
 	"github.com/pkg/errors"
 )
 
@@ -79,10 +80,7 @@ func (s *HashStore) LoadHashData(path string) error {
 	// nolint:errcheck
 	defer f.Close()
 
-	r := csv.NewReader(f)
-	r.Comma = '\t'
-	r.Comment = '#'
-	r.LazyQuotes = true
+	r := newHashFileReader(f)
 
 	for {
 		line, err := r.Read()
@@ -96,6 +94,7 @@ func (s *HashStore) LoadHashData(path string) error {
 		hash, err := s.parseHashLine(line)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+			continue
 		}
 
 		s.Put(hash)
@@ -104,36 +103,72 @@ func (s *HashStore) LoadHashData(path string) error {
 	return nil
 }
 
+// newHashFileReader returns a reader of the hash file format.
+func newHashFileReader(r io.Reader) *csv.Reader {
+	cr := csv.NewReader(r)
+	cr.Comma = '\t'
+	cr.Comment = '#'
+	cr.LazyQuotes = true
+	// number of hash values may differ in each line
+	cr.FieldsPerRecord = -1
+	return cr
+}
+
 func (s HashStore) parseHashLine(line []string) (*Hash, error) {
+	hashes, _, err := parseHashColumns(line)
+	if err != nil {
+		return nil, err
+	}
+	return hashes[0], nil
+}
+
+// parseHashColumns parses a line of the hash file format.
+// Additional columns which are not hash values (e.g. "size:1234") are returned as properties.
+//
+//	hash values : []*Hash
+//	properties : map[string]string
+//	error : error
+func parseHashColumns(line []string) ([]*Hash, map[string]string, error) {
 	if len(line) < 4 {
-		return nil, fmt.Errorf("invalid format : %v", line)
+		return nil, nil, fmt.Errorf("invalid format : %v", line)
 	}
 	modTime, err := strconv.Atoi(line[2])
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse modTime : %v", line)
+		return nil, nil, fmt.Errorf("failed to parse modTime : %v", line)
 	}
 
-	pos := strings.Index(line[3], ":")
-	if pos == -1 {
-		return nil, fmt.Errorf("invalid hash value format : %v", line)
-	}
-	alg := NewHashAlgFromString(line[3][0:pos])
-	hashValue := line[3][pos+1:]
+	hashes := make([]*Hash, 0, 1)
+	props := make(map[string]string)
+	for i, col := range line[3:] {
+		pos := strings.Index(col, ":")
+		if pos == -1 {
+			return nil, nil, fmt.Errorf("invalid hash value format : %v", line)
+		}
+		alg := NewHashAlgFromString(col[0:pos])
+		if alg == nil {
+			if i == 0 {
+				return nil, nil, fmt.Errorf("unknown hash algorithm : %v", line)
+			}
+			props[col[0:pos]] = col[pos+1:]
+			continue
+		}
 
-	hash, err := NewHashFromString(line[0], alg, hashValue, int64(modTime))
-	if err != nil {
-		return nil, fmt.Errorf("failed to patse tsv : %v", line)
+		hash, err := NewHashFromString(line[0], alg, col[pos+1:], int64(modTime))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to patse tsv : %v", line)
+		}
+		hashes = append(hashes, hash)
 	}
-	return hash, nil
+	return hashes, props, nil
 }
 
 func (s *HashStore) AppendHashDataFromDirectory(dirPath string, alg *HashAlg, verbose bool) error {
-	err := filepath.WalkDir(dirPath, func(path string, info fs.DirEntry, e error) error {
+	err := WalkDirFiltered(dirPath, func(path string, info fs.DirEntry, e error) error {
 		if e != nil {
 			return errors.Wrap(e, "failed to filepath.Walk")
 		}
 
-		if info.IsDir() {
+		if info.IsDir() || IsHasherFile(path) {
 			return nil
 		}
 
@@ -153,3 +188,47 @@ func (s *HashStore) AppendHashDataFromDirectory(dirPath string, alg *HashAlg, ve
 	})
 	return err
 }
+
+// AppendHashDataWithSummary is AppendHashDataFromDirectory which records files
+// to the summary, including errors of files which could not be hashed.
+func (s *HashStore) AppendHashDataWithSummary(dirPath string, alg *HashAlg, summary *RunSummary) error {
+	return walkTargets(dirPath, summary, func(path string, d fs.DirEntry) error {
+		if d.IsDir() {
+			return nil
+		}
+		summary.AddScanned()
+		absPath, _ := filepath.Abs(path)
+		changed, hash, err := UpdateHash(absPath, alg, false)
+		if err != nil {
+			summary.AddFailed(absPath, ErrorOp_Hash, err)
+			return nil
+		}
+		summary.AddHashed(changed)
+		s.Put(hash)
+		return nil
+	})
+}
+
+// SplitHardLinks splits hash values having the same value as the source
+// into duplicated files and hard links of the source (the same file).
+// Files which don't exist are treated as duplicated files.
+//
+//	duplicated files : []*Hash
+//	hard links : []*Hash
+func SplitHardLinks(source *Hash, sames []*Hash) ([]*Hash, []*Hash) {
+	duplicates := make([]*Hash, 0, len(sames))
+	hardLinks := make([]*Hash, 0)
+
+	srcInfo, err := os.Stat(source.Path)
+	if err != nil {
+		return append(duplicates, sames...), hardLinks
+	}
+	for _, s := range sames {
+		if info, err := os.Stat(s.Path); err == nil && os.SameFile(srcInfo, info) {
+			hardLinks = append(hardLinks, s)
+		} else {
+			duplicates = append(duplicates, s)
+		}
+	}
+	return duplicates, hardLinks
+}
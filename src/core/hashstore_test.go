@@ -0,0 +1,35 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAppendHashDataWithSummary(t *testing.T) {
+	alg := NewDefaultHashAlg()
+	dir := t.TempDir()
+	expected := make(map[string]string)
+	for _, name := range []string{"1.txt", "a/2.txt"} {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		assert.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		expected[p] = makeDummyFile(t, p, alg)
+	}
+	_, _, err := UpdateHash(filepath.Join(dir, "1.txt"), alg, false)
+	assert.NoError(t, err)
+
+	store := NewHashStore()
+	summary := NewRunSummary()
+	assert.NoError(t, store.AppendHashDataWithSummary(dir, alg, summary))
+	assert.NoError(t, summary.Err())
+	assert.Equal(t, 2, summary.Scanned())
+	assert.Equal(t, 1, summary.Unchanged())
+	assert.Equal(t, 1, summary.Updated())
+	for p, v := range expected {
+		if sames := store.Get(v); assert.Len(t, sames, 1) {
+			assert.Equal(t, p, sames[0].Path)
+		}
+	}
+}
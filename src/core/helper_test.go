@@ -1,7 +1,6 @@
 package core
 
 import (
-	"crypto"
 	"fmt"
 	"io"
 	"math/rand"
@@ -75,7 +74,7 @@ func touchDelta(t *testing.T, path1 string, path2 string, delta time.Duration) {
 // }
 
 // Make dummy file and returns it's hash value.
-func makeDummyFile(t *testing.T, path string, alg *crypto.Hash) string {
+func makeDummyFile(t *testing.T, path string, alg *HashAlg) string {
 	const SIZE = 128
 
 	t.Helper()
@@ -104,7 +103,7 @@ func makeDummyFile(t *testing.T, path string, alg *crypto.Hash) string {
 	return hashString
 }
 
-func makeSingleDummyFile(t *testing.T, alg *crypto.Hash) (string, string) {
+func makeSingleDummyFile(t *testing.T, alg *HashAlg) (string, string) {
 	t.Helper()
 
 	tmpDir := t.TempDir()
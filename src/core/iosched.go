@@ -0,0 +1,171 @@
+package core
+
+import (
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	. "github.com/little-forest/hasher/common" // nolint:staticcheck
+)
+
+// Default number of workers which read the same rotational device at once
+const DefaultRotationalJobs = 1
+
+// Number of tasks queued in each lane
+const ioLaneQueueSize = 1024
+
+// Min number of workers used when it is not specified,
+// so that lanes of several devices run at once even on a machine with few CPUs
+const minDefaultNumOfWorkers = 4
+
+// IOScheduler runs update tasks in a lane of each device (st_dev),
+// so that files on different devices are read in parallel.
+//
+// The number of workers of each lane is limited by the device type.
+// Rotational devices (HDD) are read by rotationalJobs workers to avoid random seeks,
+// while other devices (SSD, network filesystems, ...) are read by numOfWorkers workers.
+// The total number of running tasks is limited by numOfWorkers.
+//
+// The number of workers is not limited by the number of CPUs,
+// since only calculating hash values is limited by it (see hashingSlots).
+type IOScheduler struct {
+	// device -> task queue
+	lanes map[uint64]chan UpdateTask
+	// returns true if the device is rotational
+	isRotational func(dev uint64) bool
+	process      func(workerId int, task UpdateTask)
+	// ids of workers which are not running a task
+	workerIds      chan int
+	wg             sync.WaitGroup
+	numOfWorkers   int
+	rotationalJobs int
+	mu             sync.Mutex
+	stopped        atomic.Bool
+}
+
+func NewIOScheduler(numOfWorkers int, rotationalJobs int) *IOScheduler {
+	if numOfWorkers < 1 {
+		numOfWorkers = 1
+	}
+	if rotationalJobs < 1 {
+		rotationalJobs = 1
+	}
+	if rotationalJobs > numOfWorkers {
+		rotationalJobs = numOfWorkers
+	}
+
+	workerIds := make(chan int, numOfWorkers)
+	for i := 0; i < numOfWorkers; i++ {
+		workerIds <- i
+	}
+
+	return &IOScheduler{
+		lanes:          make(map[uint64]chan UpdateTask),
+		isRotational:   IsRotationalDevice,
+		workerIds:      workerIds,
+		numOfWorkers:   numOfWorkers,
+		rotationalJobs: rotationalJobs,
+	}
+}
+
+// DefaultNumOfWorkers returns the number of workers used when it is not specified.
+func DefaultNumOfWorkers() int {
+	return defaultNumOfWorkers(runtime.NumCPU())
+}
+
+func defaultNumOfWorkers(numOfCPU int) int {
+	if numOfCPU < minDefaultNumOfWorkers {
+		return minDefaultNumOfWorkers
+	}
+	return numOfCPU
+}
+
+func (s *IOScheduler) NumOfWorkers() int {
+	return s.numOfWorkers
+}
+
+// Start sets the function to process tasks.
+// Worker id passed to process is between 0 and NumOfWorkers()-1,
+// and it is unique among running tasks.
+// It must be called before Submit.
+func (s *IOScheduler) Start(process func(workerId int, task UpdateTask)) {
+	s.process = process
+}
+
+// Submit queues the task to the lane of given device.
+// It blocks while the queue of the lane is full.
+func (s *IOScheduler) Submit(task UpdateTask, dev uint64) {
+	s.getLane(dev) <- task
+}
+
+// Wait waits until all submitted tasks are processed.
+// No task can be submitted after calling it.
+func (s *IOScheduler) Wait() {
+	s.mu.Lock()
+	for _, lane := range s.lanes {
+		close(lane)
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+// Stop discards queued tasks. Running tasks are not interrupted.
+// Tasks submitted after calling it are also discarded.
+func (s *IOScheduler) Stop() {
+	s.stopped.Store(true)
+}
+
+// IsStopped returns true if Stop has been called.
+func (s *IOScheduler) IsStopped() bool {
+	return s.stopped.Load()
+}
+
+func (s *IOScheduler) getLane(dev uint64) chan UpdateTask {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lane, ok := s.lanes[dev]
+	if ok {
+		return lane
+	}
+
+	lane = make(chan UpdateTask, ioLaneQueueSize)
+	s.lanes[dev] = lane
+
+	numOfLaneWorkers := s.numOfWorkers
+	if s.isRotational(dev) {
+		numOfLaneWorkers = s.rotationalJobs
+	}
+	for i := 0; i < numOfLaneWorkers; i++ {
+		s.wg.Add(1)
+		go s.runLane(lane)
+	}
+	return lane
+}
+
+func (s *IOScheduler) runLane(lane <-chan UpdateTask) {
+	defer s.wg.Done()
+
+	for task := range lane {
+		if s.IsStopped() {
+			continue
+		}
+		id := <-s.workerIds
+		s.process(id, task)
+		s.workerIds <- id
+	}
+}
+
+// deviceOf returns the device of given file.
+// When it is not available, it will return 0.
+func deviceOf(info os.FileInfo) uint64 {
+	if info == nil {
+		return 0
+	}
+	if id, ok := GetFileId(info); ok {
+		return id.Dev
+	}
+	return 0
+}
@@ -0,0 +1,181 @@
+package core
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/little-forest/hasher/common"
+	"github.com/stretchr/testify/assert"
+)
+
+// Runs tasks on given devices and returns the max number of tasks which ran at once
+// in total and on each device.
+func runScheduledTasks(t *testing.T, s *IOScheduler, devs []uint64, numOfTasks int) (int32, map[uint64]int32) {
+	t.Helper()
+
+	var mu sync.Mutex
+	var current, max int32
+	devCurrent := make(map[uint64]int32)
+	devMax := make(map[uint64]int32)
+	workerIds := make(map[int]bool)
+
+	s.Start(func(workerId int, task UpdateTask) {
+		dev := uint64(task.Path[0] - '0')
+
+		mu.Lock()
+		assert.False(t, workerIds[workerId], "worker id %d is in use", workerId)
+		assert.True(t, workerId >= 0 && workerId < s.NumOfWorkers())
+		workerIds[workerId] = true
+		current++
+		if current > max {
+			max = current
+		}
+		devCurrent[dev]++
+		if devCurrent[dev] > devMax[dev] {
+			devMax[dev] = devCurrent[dev]
+		}
+		mu.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		mu.Lock()
+		delete(workerIds, workerId)
+		current--
+		devCurrent[dev]--
+		mu.Unlock()
+	})
+
+	for i := 0; i < numOfTasks; i++ {
+		for _, dev := range devs {
+			s.Submit(NewUpdateTask(fmt.Sprintf("%d/file%d", dev, i)), dev)
+		}
+	}
+	s.Wait()
+	return max, devMax
+}
+
+func TestIOScheduler_rotational(t *testing.T) {
+	s := NewIOScheduler(8, 2)
+	s.isRotational = func(dev uint64) bool { return true }
+
+	max, devMax := runScheduledTasks(t, s, []uint64{1, 2}, 8)
+	assert.Equal(t, int32(4), max)
+	assert.Equal(t, map[uint64]int32{1: 2, 2: 2}, devMax)
+}
+
+func TestIOScheduler_nonRotational(t *testing.T) {
+	s := NewIOScheduler(8, 1)
+	s.isRotational = func(dev uint64) bool { return false }
+
+	max, devMax := runScheduledTasks(t, s, []uint64{1}, 8)
+	assert.Equal(t, int32(8), max)
+	assert.Equal(t, int32(8), devMax[1])
+}
+
+func TestIOScheduler_numOfWorkers(t *testing.T) {
+	s := NewIOScheduler(3, 1)
+	s.isRotational = func(dev uint64) bool { return dev != 1 }
+
+	max, devMax := runScheduledTasks(t, s, []uint64{1, 2, 3, 4}, 8)
+	assert.Equal(t, int32(3), max)
+	for _, dev := range []uint64{2, 3, 4} {
+		assert.Equal(t, int32(1), devMax[dev])
+	}
+}
+
+func TestIOScheduler_singleCPU(t *testing.T) {
+	s := NewIOScheduler(defaultNumOfWorkers(1), DefaultRotationalJobs)
+	s.isRotational = func(dev uint64) bool { return true }
+
+	// lanes of two disks run at once
+	max, devMax := runScheduledTasks(t, s, []uint64{1, 2}, 4)
+	assert.Equal(t, int32(2), max)
+	assert.Equal(t, map[uint64]int32{1: 1, 2: 1}, devMax)
+}
+
+// concurrencyWriter records the max number of goroutines writing at once.
+type concurrencyWriter struct {
+	current int32
+	max     int32
+	mu      sync.Mutex
+}
+
+func (w *concurrencyWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	w.current++
+	if w.current > w.max {
+		w.max = w.current
+	}
+	w.mu.Unlock()
+
+	time.Sleep(time.Millisecond)
+
+	w.mu.Lock()
+	w.current--
+	w.mu.Unlock()
+	return len(p), nil
+}
+
+func TestHashingWriter(t *testing.T) {
+	orig := hashingSlots
+	hashingSlots = make(chan struct{}, numOfHashingCPUs(1))
+	t.Cleanup(func() { hashingSlots = orig })
+
+	w := &concurrencyWriter{}
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 5; j++ {
+				hashingWriter{w: w}.Write([]byte("x")) // nolint:errcheck
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), w.max)
+}
+
+func TestNumOfHashingCPUs(t *testing.T) {
+	assert.Equal(t, 1, numOfHashingCPUs(1))
+	assert.Equal(t, 1, numOfHashingCPUs(2))
+	assert.Equal(t, 3, numOfHashingCPUs(4))
+}
+
+func TestConcurrentUpdateHash(t *testing.T) {
+	alg := NewDefaultHashAlg()
+	dir := t.TempDir()
+	expected := make(map[string]string)
+	for i := 0; i < 20; i++ {
+		p := filepath.Join(dir, string(rune('a'+i))+".txt")
+		expected[p] = makeDummyFile(t, p, alg)
+	}
+
+	err := ConcurrentUpdateHash([]string{dir}, []*HashAlg{alg}, false, NewIOScheduler(4, 1), nil, nil, &nopProgressNotifier{})
+	assert.NoError(t, err)
+
+	for p, v := range expected {
+		h, err := GetHash(p, alg)
+		assert.NoError(t, err)
+		if assert.NotNil(t, h) {
+			assert.Equal(t, v, h.String())
+		}
+	}
+}
+
+type nopProgressNotifier struct{}
+
+func (n *nopProgressNotifier) SetTotal(total int)                               {}
+func (n *nopProgressNotifier) SetFileCounter(counter *common.FileCounter)       {}
+func (n *nopProgressNotifier) Start()                                           {}
+func (n *nopProgressNotifier) Shutdown()                                        {}
+func (n *nopProgressNotifier) NotifyTaskStart(workerId int, taskName string)    {}
+func (n *nopProgressNotifier) NotifyTaskDone(workerId int, message string)      {}
+func (n *nopProgressNotifier) NotifyProgress(done int, total int)               {}
+func (n *nopProgressNotifier) NotifyBytes(workerId int, bytes int64, read bool) {}
+func (n *nopProgressNotifier) NotifyWarning(workerId int, message string)       {}
+func (n *nopProgressNotifier) NotifyError(workerId int, message string)         {}
+func (n *nopProgressNotifier) IsVerbose() bool                                  { return false }
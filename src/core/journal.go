@@ -0,0 +1,550 @@
+package core
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ------------------------------------------------------------------------------
+//  update journal format
+//
+//  Tab separated text file recording the progress of a recursive update.
+//  Paths are quoted by strconv.Quote, and relative paths are separated by '/'.
+//  Later pos lines override earlier ones.
+//
+//  # hasher journal
+//  algs	ALG,ALG...
+//  root	ROOT_INDEX	ROOT_PATH (absolute)
+//  total	NUM_OF_FILES
+//  pos	ROOT_INDEX	COUNT	RELATIVE_PATH
+//    all files of the root up to RELATIVE_PATH in walk order are done (COUNT files)
+//  done	ROOT_INDEX	RELATIVE_PATH
+//    the file is done
+//  failed	ROOT_INDEX	RELATIVE_PATH
+//    the file is done with an error, and retried by a resumed run even if it is before pos
+// ===============================================================================
+
+const journalHeader = "# hasher journal"
+
+// Interval to write the journal to the file
+const journalFlushInterval = time.Second
+
+// UpdateJournal records files done by ConcurrentUpdateHash,
+// so that an interrupted update can be resumed.
+//
+// Files are walked in lexical order, and the walk position of each root is recorded
+// as the last file of the sequence of done files.
+// Files done out of order are recorded individually.
+// Failed files are also recorded individually, so that the walk position can move past them.
+type UpdateJournal struct {
+	lastFlush time.Time
+	file      *os.File
+	w         *bufio.Writer
+	path      string
+	algs      string
+	roots     []*journalRoot
+	total     int
+	mu        sync.Mutex
+}
+
+type journalRoot struct {
+	// relative paths of files done beyond pos
+	done map[string]bool
+	// relative paths of files done with an error up to pos
+	failed map[string]bool
+	// seq -> relative path of files done out of order
+	pending map[int]string
+	// root path given to ConcurrentUpdateHash
+	path string
+	// absolute root path
+	absPath string
+	// relative path of the last file of the sequence of done files ("" : none)
+	pos string
+	// seq of the next file after pos
+	next int
+	// number of files up to pos
+	count int
+	// count when pos was written
+	written int
+}
+
+// DefaultJournalPath returns the journal path for given root paths,
+// which is located in <UserCacheDir>/hasher/journal.
+func DefaultJournalPath(roots []string) (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	absRoots, err := absPaths(roots)
+	if err != nil {
+		return "", err
+	}
+	key := sha1.Sum([]byte(strings.Join(absRoots, "\n")))
+	return filepath.Join(dir, "hasher", "journal", fmt.Sprintf("%x", key)), nil
+}
+
+// NewUpdateJournal creates a new journal.
+// The existing journal is overwritten.
+func NewUpdateJournal(path string, roots []string, algs []*HashAlg) (*UpdateJournal, error) {
+	j, err := newUpdateJournal(path, roots, algs)
+	if err != nil {
+		return nil, err
+	}
+	if err := j.open(); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// ResumeUpdateJournal loads the journal written by the interrupted update.
+// When the journal doesn't exist, it will return an error satisfying os.IsNotExist.
+func ResumeUpdateJournal(path string, roots []string, algs []*HashAlg) (*UpdateJournal, error) {
+	j, err := newUpdateJournal(path, roots, algs)
+	if err != nil {
+		return nil, err
+	}
+	if err := j.load(); err != nil {
+		return nil, err
+	}
+	if err := j.open(); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+func newUpdateJournal(path string, roots []string, algs []*HashAlg) (*UpdateJournal, error) {
+	absRoots, err := absPaths(roots)
+	if err != nil {
+		return nil, err
+	}
+
+	algNames := make([]string, len(algs))
+	for i, alg := range algs {
+		algNames[i] = alg.AlgName
+	}
+
+	j := &UpdateJournal{
+		path:  path,
+		algs:  strings.Join(algNames, ","),
+		roots: make([]*journalRoot, len(roots)),
+		total: -1,
+	}
+	for i, r := range roots {
+		j.roots[i] = &journalRoot{
+			done:    make(map[string]bool),
+			failed:  make(map[string]bool),
+			pending: make(map[int]string),
+			path:    r,
+			absPath: absRoots[i],
+		}
+	}
+	return j, nil
+}
+
+func absPaths(paths []string) ([]string, error) {
+	absPaths := make([]string, len(paths))
+	for i, p := range paths {
+		a, err := filepath.Abs(p)
+		if err != nil {
+			return nil, err
+		}
+		absPaths[i] = a
+	}
+	return absPaths, nil
+}
+
+// load reads the journal file.
+func (j *UpdateJournal) load() error {
+	f, err := os.Open(j.path)
+	if err != nil {
+		return err
+	}
+	// nolint:errcheck
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if err := j.parseLine(strings.Split(line, "\t")); err != nil {
+			if err == errInvalidJournalLine {
+				return fmt.Errorf("invalid journal : %s:%d", j.path, lineNo)
+			}
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	// files before the walk position are no longer needed,
+	// and failed files beyond it are retried anyway
+	for _, root := range j.roots {
+		for p := range root.done {
+			if root.pos != "" && !walkOrderLess(root.pos, p) {
+				delete(root.done, p)
+			}
+		}
+		for p := range root.failed {
+			if root.pos == "" || walkOrderLess(root.pos, p) {
+				delete(root.failed, p)
+			}
+		}
+	}
+	return nil
+}
+
+var errInvalidJournalLine = fmt.Errorf("invalid journal line")
+
+func (j *UpdateJournal) parseLine(cols []string) error {
+	switch cols[0] {
+	case "algs":
+		if len(cols) != 2 {
+			return errInvalidJournalLine
+		}
+		if cols[1] != j.algs {
+			return fmt.Errorf("journal was recorded with other hash algorithms (%s) : %s", cols[1], j.path)
+		}
+	case "root":
+		root, err := j.parseRoot(cols, 3)
+		if err != nil {
+			return errInvalidJournalLine
+		}
+		p, err := strconv.Unquote(cols[2])
+		if err != nil {
+			return errInvalidJournalLine
+		}
+		if p != root.absPath {
+			return fmt.Errorf("journal was recorded for other directories : %s", j.path)
+		}
+	case "total":
+		if len(cols) != 2 {
+			return errInvalidJournalLine
+		}
+		total, err := strconv.Atoi(cols[1])
+		if err != nil {
+			return errInvalidJournalLine
+		}
+		j.total = total
+	case "pos":
+		root, err := j.parseRoot(cols, 4)
+		if err != nil {
+			return errInvalidJournalLine
+		}
+		count, err := strconv.Atoi(cols[2])
+		if err != nil {
+			return errInvalidJournalLine
+		}
+		pos, err := strconv.Unquote(cols[3])
+		if err != nil {
+			return errInvalidJournalLine
+		}
+		root.pos = pos
+		root.count = count
+		root.written = count
+	case "done":
+		root, err := j.parseRoot(cols, 3)
+		if err != nil {
+			return errInvalidJournalLine
+		}
+		p, err := strconv.Unquote(cols[2])
+		if err != nil {
+			return errInvalidJournalLine
+		}
+		root.done[p] = true
+		delete(root.failed, p)
+	case "failed":
+		root, err := j.parseRoot(cols, 3)
+		if err != nil {
+			return errInvalidJournalLine
+		}
+		p, err := strconv.Unquote(cols[2])
+		if err != nil {
+			return errInvalidJournalLine
+		}
+		root.failed[p] = true
+		delete(root.done, p)
+	default:
+		return errInvalidJournalLine
+	}
+	return nil
+}
+
+func (j *UpdateJournal) parseRoot(cols []string, numOfCols int) (*journalRoot, error) {
+	if len(cols) != numOfCols {
+		return nil, fmt.Errorf("invalid number of columns")
+	}
+	i, err := strconv.Atoi(cols[1])
+	if err != nil || i < 0 || i >= len(j.roots) {
+		return nil, fmt.Errorf("invalid root index : %s", cols[1])
+	}
+	return j.roots[i], nil
+}
+
+// open writes the current state to a new journal file, and keeps it open to append.
+func (j *UpdateJournal) open() error {
+	if err := os.MkdirAll(filepath.Dir(j.path), 0o755); err != nil {
+		return err
+	}
+
+	tmpPath := j.path + ".tmp"
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+
+	fmt.Fprintln(w, journalHeader)       // nolint:errcheck
+	fmt.Fprintf(w, "algs\t%s\n", j.algs) // nolint:errcheck
+	for i, root := range j.roots {
+		fmt.Fprintf(w, "root\t%d\t%s\n", i, strconv.Quote(root.absPath)) // nolint:errcheck
+	}
+	if j.total >= 0 {
+		fmt.Fprintf(w, "total\t%d\n", j.total) // nolint:errcheck
+	}
+	for i, root := range j.roots {
+		if root.pos != "" {
+			fmt.Fprintf(w, "pos\t%d\t%d\t%s\n", i, root.count, strconv.Quote(root.pos)) // nolint:errcheck
+		}
+		for p := range root.done {
+			fmt.Fprintf(w, "done\t%d\t%s\n", i, strconv.Quote(p)) // nolint:errcheck
+		}
+		for p := range root.failed {
+			fmt.Fprintf(w, "failed\t%d\t%s\n", i, strconv.Quote(p)) // nolint:errcheck
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		f.Close() // nolint:errcheck
+		return err
+	}
+	if err := os.Rename(tmpPath, j.path); err != nil {
+		f.Close() // nolint:errcheck
+		return err
+	}
+
+	j.file = f
+	j.w = w
+	j.lastFlush = time.Now()
+	return nil
+}
+
+// Path returns the file path of the journal.
+func (j *UpdateJournal) Path() string {
+	return j.path
+}
+
+// Total returns the number of target files.
+// When it has not been recorded, it will return -1.
+func (j *UpdateJournal) Total() int {
+	if j == nil {
+		return -1
+	}
+	return j.total
+}
+
+func (j *UpdateJournal) SetTotal(total int) {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.total = total
+	fmt.Fprintf(j.w, "total\t%d\n", total) // nolint:errcheck
+}
+
+// Completed returns the number of files done successfully.
+func (j *UpdateJournal) Completed() int {
+	if j == nil {
+		return 0
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	n := 0
+	for _, root := range j.roots {
+		n += root.count + len(root.done) - len(root.failed)
+	}
+	return n
+}
+
+// position returns the walk position of the root.
+// All files up to the position have been done.
+func (j *UpdateJournal) position(rootIdx int) string {
+	if j == nil {
+		return ""
+	}
+	return j.roots[rootIdx].pos
+}
+
+// isDone returns true if the file has been done, regardless of the walk position.
+func (j *UpdateJournal) isDone(rootIdx int, relPath string) bool {
+	if j == nil {
+		return false
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.roots[rootIdx].done[relPath]
+}
+
+// isFailed returns true if the file up to the walk position has been done with an error.
+func (j *UpdateJournal) isFailed(rootIdx int, relPath string) bool {
+	if j == nil {
+		return false
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.roots[rootIdx].failed[relPath]
+}
+
+// hasFailedIn returns true if the directory has files done with an error.
+func (j *UpdateJournal) hasFailedIn(rootIdx int, relDir string) bool {
+	if j == nil {
+		return false
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	for p := range j.roots[rootIdx].failed {
+		if isAncestorPath(relDir, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// relPath returns the path of the file relative to the root.
+func (j *UpdateJournal) relPath(task UpdateTask) string {
+	rel, err := filepath.Rel(j.roots[task.Root].path, task.Path)
+	if err != nil {
+		return task.Path
+	}
+	return filepath.ToSlash(rel)
+}
+
+// complete marks the task as done.
+// When record is false, the task is not written to the journal file
+// (because it is already recorded).
+func (j *UpdateJournal) complete(task UpdateTask, record bool) {
+	j.finish(task, record, false)
+}
+
+// fail marks the task as done with an error.
+// The walk position moves past it, but it is retried by a resumed run.
+func (j *UpdateJournal) fail(task UpdateTask) {
+	j.finish(task, true, true)
+}
+
+// finish marks the task as done, and moves the walk position if possible.
+// Tasks whose Seq is negative are failed files retried from before the walk position.
+func (j *UpdateJournal) finish(task UpdateTask, record bool, failed bool) {
+	if j == nil {
+		return
+	}
+	rel := j.relPath(task)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	root := j.roots[task.Root]
+	if failed {
+		root.failed[rel] = true
+	} else {
+		delete(root.failed, rel)
+	}
+	if task.Seq >= 0 {
+		root.pending[task.Seq] = rel
+		for {
+			p, ok := root.pending[root.next]
+			if !ok {
+				break
+			}
+			delete(root.pending, root.next)
+			delete(root.done, p)
+			root.pos = p
+			root.next++
+			root.count++
+		}
+	}
+	if record {
+		if failed {
+			fmt.Fprintf(j.w, "failed\t%d\t%s\n", task.Root, strconv.Quote(rel)) // nolint:errcheck
+		} else if task.Seq < 0 || root.pending[task.Seq] != "" {
+			fmt.Fprintf(j.w, "done\t%d\t%s\n", task.Root, strconv.Quote(rel)) // nolint:errcheck
+		}
+	}
+
+	if time.Since(j.lastFlush) >= journalFlushInterval {
+		j.flush() // nolint:errcheck
+	}
+}
+
+// flush writes walk positions and pending lines to the file.
+// Attributes are written first, so that files recorded as done never lose them
+// even if stored in sidecar files.
+func (j *UpdateJournal) flush() error {
+	if err := FlushAttrStore(); err != nil {
+		return err
+	}
+	for i, root := range j.roots {
+		if root.count != root.written {
+			fmt.Fprintf(j.w, "pos\t%d\t%d\t%s\n", i, root.count, strconv.Quote(root.pos)) // nolint:errcheck
+			root.written = root.count
+		}
+	}
+	j.lastFlush = time.Now()
+	return j.w.Flush()
+}
+
+// Close writes the journal to the file and closes it.
+// When the update is completed, the journal file is removed.
+func (j *UpdateJournal) Close(completed bool) error {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	flushErr := j.flush()
+	if err := j.file.Close(); err != nil {
+		return err
+	}
+	if completed {
+		return os.Remove(j.path)
+	}
+	return flushErr
+}
+
+// walkOrderLess returns true if relative path a is walked before b by filepath.WalkDir.
+// Paths are compared by each element, because WalkDir walks entries of a directory in lexical order.
+func walkOrderLess(a string, b string) bool {
+	as := strings.Split(a, "/")
+	bs := strings.Split(b, "/")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		if as[i] != bs[i] {
+			return as[i] < bs[i]
+		}
+	}
+	return len(as) < len(bs)
+}
+
+// isAncestorPath returns true if relative path dir is an ancestor of p.
+func isAncestorPath(dir string, p string) bool {
+	return strings.HasPrefix(p, dir+"/")
+}
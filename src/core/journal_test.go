@@ -0,0 +1,179 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingNotifier records started tasks, and stops the scheduler after stopAfter tasks.
+// If listed is not nil, the scheduler is stopped only after all files are listed,
+// so that the total is recorded to the journal.
+type recordingNotifier struct {
+	nopProgressNotifier
+	sched      *IOScheduler
+	listed     chan struct{}
+	started    []string
+	stopAfter  int
+	listedOnce sync.Once
+	mu         sync.Mutex
+}
+
+func (n *recordingNotifier) NotifyTaskStart(workerId int, taskName string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.started = append(n.started, taskName)
+	if len(n.started) == n.stopAfter {
+		if n.listed != nil {
+			<-n.listed
+		}
+		n.sched.Stop()
+	}
+}
+
+func (n *recordingNotifier) NotifyProgress(done int, total int) {
+	// the total is notified after all files are listed
+	if total >= 0 && n.listed != nil {
+		n.listedOnce.Do(func() { close(n.listed) })
+	}
+}
+
+func TestConcurrentUpdateHash_resume(t *testing.T) {
+	alg := NewDefaultHashAlg()
+	algs := []*HashAlg{alg}
+	dir := t.TempDir()
+	expected := make(map[string]string)
+	for _, name := range []string{"a/b/1.txt", "a/b/2.txt", "a/c.txt", "a-c/3.txt", "a-c/4.txt", "b.txt", "c/d/e/5.txt", "c/f.txt"} {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		assert.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		expected[p] = makeDummyFile(t, p, alg)
+	}
+	journalPath := filepath.Join(t.TempDir(), "journal")
+
+	// interrupted run
+	journal, err := NewUpdateJournal(journalPath, []string{dir}, algs)
+	assert.NoError(t, err)
+	sched := NewIOScheduler(2, 1)
+	first := &recordingNotifier{sched: sched, stopAfter: 3, listed: make(chan struct{})}
+	err = ConcurrentUpdateHash([]string{dir}, algs, false, sched, journal, nil, first)
+	assert.Equal(t, ErrInterrupted, err)
+	assert.NoError(t, journal.Close(false))
+	assert.FileExists(t, journalPath)
+
+	// resumed run
+	journal, err = ResumeUpdateJournal(journalPath, []string{dir}, algs)
+	assert.NoError(t, err)
+	assert.Equal(t, len(expected), journal.Total())
+	assert.Equal(t, len(first.started), journal.Completed())
+	sched = NewIOScheduler(2, 1)
+	second := &recordingNotifier{sched: sched}
+	err = ConcurrentUpdateHash([]string{dir}, algs, false, sched, journal, nil, second)
+	assert.NoError(t, err)
+	assert.NoError(t, journal.Close(true))
+	assert.NoFileExists(t, journalPath)
+
+	// each file is updated only once
+	assert.ElementsMatch(t, keys(expected), append(first.started, second.started...))
+	for p, v := range expected {
+		h, err := GetHash(p, alg)
+		assert.NoError(t, err)
+		if assert.NotNil(t, h) {
+			assert.Equal(t, v, h.String())
+		}
+	}
+}
+
+// failingNotifier removes the file when its task starts, so that the task fails.
+type failingNotifier struct {
+	failPath string
+	recordingNotifier
+}
+
+func (n *failingNotifier) NotifyTaskStart(workerId int, taskName string) {
+	if taskName == n.failPath {
+		os.Remove(taskName) // nolint:errcheck
+	}
+	n.recordingNotifier.NotifyTaskStart(workerId, taskName)
+}
+
+func TestConcurrentUpdateHash_resumeFailed(t *testing.T) {
+	alg := NewDefaultHashAlg()
+	algs := []*HashAlg{alg}
+	dir := t.TempDir()
+	paths := make([]string, 0)
+	for _, name := range []string{"a/1.txt", "b/2.txt", "c/3.txt", "c/4.txt"} {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		assert.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		makeDummyFile(t, p, alg)
+		paths = append(paths, p)
+	}
+	journalPath := filepath.Join(t.TempDir(), "journal")
+
+	// the first file fails, and the run is interrupted
+	journal, err := NewUpdateJournal(journalPath, []string{dir}, algs)
+	assert.NoError(t, err)
+	sched := NewIOScheduler(1, 1)
+	first := &failingNotifier{recordingNotifier: recordingNotifier{sched: sched, stopAfter: 2}, failPath: paths[0]}
+	summary := NewRunSummary()
+	err = ConcurrentUpdateHash([]string{dir}, algs, false, sched, journal, summary, first)
+	assert.Equal(t, ErrInterrupted, err)
+	assert.Equal(t, 1, summary.Failed())
+	// the walk position moves past the failed file
+	assert.Equal(t, "b/2.txt", journal.position(0))
+	assert.NoError(t, journal.Close(false))
+
+	// the failed file is retried by the resumed run
+	expected := makeDummyFile(t, paths[0], alg)
+	journal, err = ResumeUpdateJournal(journalPath, []string{dir}, algs)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, journal.Completed())
+	assert.Equal(t, "b/2.txt", journal.position(0))
+	assert.True(t, journal.isFailed(0, "a/1.txt"))
+	sched = NewIOScheduler(1, 1)
+	second := &recordingNotifier{sched: sched}
+	err = ConcurrentUpdateHash([]string{dir}, algs, false, sched, journal, nil, second)
+	assert.NoError(t, err)
+	assert.NoError(t, journal.Close(true))
+
+	assert.ElementsMatch(t, []string{paths[0], paths[2], paths[3]}, second.started)
+	h, err := GetHash(paths[0], alg)
+	assert.NoError(t, err)
+	if assert.NotNil(t, h) {
+		assert.Equal(t, expected, h.String())
+	}
+}
+
+func TestResumeUpdateJournal_mismatch(t *testing.T) {
+	dir := t.TempDir()
+	journalPath := filepath.Join(t.TempDir(), "journal")
+
+	journal, err := NewUpdateJournal(journalPath, []string{dir}, []*HashAlg{NewDefaultHashAlg()})
+	assert.NoError(t, err)
+	assert.NoError(t, journal.Close(false))
+
+	_, err = ResumeUpdateJournal(journalPath, []string{dir}, []*HashAlg{NewHashAlgFromString("sha256")})
+	assert.Error(t, err)
+
+	_, err = ResumeUpdateJournal(filepath.Join(t.TempDir(), "not_exist"), []string{dir}, []*HashAlg{NewDefaultHashAlg()})
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestWalkOrderLess(t *testing.T) {
+	assert.True(t, walkOrderLess("a/b", "a-c"))
+	assert.False(t, walkOrderLess("a-c", "a/b"))
+	assert.True(t, walkOrderLess("a", "a/b"))
+	assert.True(t, walkOrderLess("a/b/c", "a/c"))
+	assert.False(t, walkOrderLess("b", "b"))
+}
+
+func keys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
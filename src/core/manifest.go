@@ -0,0 +1,291 @@
+package core
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	. "github.com/little-forest/hasher/common" // nolint:staticcheck
+)
+
+// ------------------------------------------------------------------------------
+//  manifest file format
+//
+//  Same as the hash file format, except that
+//  the path is relative to the exported directory (separated by '/'),
+//  and the following properties are appended after hash values.
+//
+//  size:FILE_SIZE
+//  mtime:MODIFIED_TIME (UNIX time nano)
+// ===============================================================================
+
+const manifestHeader = "# hasher manifest"
+
+const (
+	manifestProp_size  = "size"
+	manifestProp_mtime = "mtime"
+)
+
+type ManifestEntry struct {
+	// relative path separated by '/'
+	Path string
+	// hash values whose Path is the relative path
+	Hashes []*Hash
+	Size   int64
+	// modified time (UNIX time nano)
+	ModTime int64
+}
+
+func (e ManifestEntry) Tsv() string {
+	return fmt.Sprintf("%s\t%s:%d\t%s:%d", HashesTsv(e.Hashes), manifestProp_size, e.Size, manifestProp_mtime, e.ModTime)
+}
+
+type ImportStatus uint8
+
+const (
+	// hash values are imported
+	IMPORT_OK ImportStatus = iota + 1
+	// file doesn't exist
+	IMPORT_NOT_FOUND
+	// size or mtime differs from the manifest
+	IMPORT_MODIFIED
+	// file contents don't match the manifest (only when verified)
+	IMPORT_CORRUPTED
+)
+
+func (s ImportStatus) String() string {
+	switch s {
+	case IMPORT_OK:
+		return "OK"
+	case IMPORT_NOT_FOUND:
+		return "NOT_FOUND"
+	case IMPORT_MODIFIED:
+		return "MODIFIED"
+	case IMPORT_CORRUPTED:
+		return "CORRUPTED"
+	}
+	return "UNKNOWN"
+}
+
+type ImportOption struct {
+	// allowed difference of mtime between the manifest and the file
+	MtimeWindow time.Duration
+	// recalculate hash values and import them only when they match
+	Verify bool
+}
+
+// ExportManifest writes stored hash values of all files under given directory as a manifest.
+// Files which have no hash value are skipped,
+// and so are files modified after the hash was calculated.
+//
+//	number of exported files : int
+//	error : error
+func ExportManifest(dirPath string, w io.Writer) (int, error) {
+	bw := bufio.NewWriterSize(w, 16384)
+
+	fmt.Fprintln(bw, manifestHeader) // nolint:errcheck
+
+	count := 0
+	err := WalkDir(dirPath, func(f *os.File) error {
+		relPath, err := filepath.Rel(dirPath, f.Name())
+		if err != nil {
+			return err
+		}
+		entry, err := readManifestEntry(f, filepath.ToSlash(relPath))
+		if err != nil {
+			ShowWarn("%s : %s", err.Error(), f.Name())
+			return nil
+		}
+		if entry == nil {
+			return nil
+		}
+		if _, err := fmt.Fprintln(bw, entry.Tsv()); err != nil {
+			return err
+		}
+		count++
+		return nil
+	})
+	if err != nil {
+		return count, err
+	}
+	return count, bw.Flush()
+}
+
+// readManifestEntry reads stored hash values of all algorithms.
+// When the file has no hash value, it will return nil.
+func readManifestEntry(f *os.File, relPath string) (*ManifestEntry, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &ManifestEntry{
+		Path:    relPath,
+		Hashes:  make([]*Hash, 0),
+		Size:    info.Size(),
+		ModTime: info.ModTime().UnixNano(),
+	}
+	for _, name := range HashAlgNames() {
+		alg := NewHashAlgFromString(name)
+		v := GetAttr(f, alg.AttrName)
+		if v == "" {
+			continue
+		}
+		h, err := NewHashFromString(relPath, alg, v, info.ModTime().Unix())
+		if err != nil {
+			return nil, fmt.Errorf("invalid hash value (%s)", alg.AttrName)
+		}
+		entry.Hashes = append(entry.Hashes, h)
+	}
+	if len(entry.Hashes) == 0 {
+		return nil, nil
+	}
+
+	if GetAttr(f, Xattr_size) != fmt.Sprint(entry.Size) || GetAttr(f, Xattr_modifiedTime) != fmt.Sprint(entry.ModTime) {
+		return nil, fmt.Errorf("file was modified after the hash was calculated")
+	}
+	return entry, nil
+}
+
+// ReadManifest reads all entries of the manifest file.
+func ReadManifest(path string) ([]*ManifestEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	// nolint:errcheck
+	defer f.Close()
+
+	entries := make([]*ManifestEntry, 0)
+	r := newHashFileReader(f)
+	for {
+		line, err := r.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("%s : %s", path, err.Error())
+		}
+
+		entry, err := parseManifestLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s : %s", path, err.Error())
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func parseManifestLine(line []string) (*ManifestEntry, error) {
+	hashes, props, err := parseHashColumns(line)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &ManifestEntry{
+		Path:   line[0],
+		Hashes: hashes,
+	}
+	if entry.Size, err = strconv.ParseInt(props[manifestProp_size], 10, 64); err != nil {
+		return nil, fmt.Errorf("invalid size : %v", line)
+	}
+	if entry.ModTime, err = strconv.ParseInt(props[manifestProp_mtime], 10, 64); err != nil {
+		return nil, fmt.Errorf("invalid mtime : %v", line)
+	}
+
+	// reject paths escaping from the import directory
+	p := filepath.FromSlash(entry.Path)
+	if filepath.IsAbs(p) || p == ".." || strings.HasPrefix(p, ".."+string(filepath.Separator)) || filepath.Clean(p) != p {
+		return nil, fmt.Errorf("invalid path : %s", entry.Path)
+	}
+	return entry, nil
+}
+
+// ImportManifest applies hash values in the manifest to files under given directory.
+// Hash values are imported only when size and mtime of the file match the manifest.
+// onImported is called for each entry with the path of the file.
+func ImportManifest(entries []*ManifestEntry, dirPath string, opt ImportOption, onImported func(path string, entry *ManifestEntry, status ImportStatus, err error)) {
+	for _, entry := range entries {
+		path := filepath.Join(dirPath, filepath.FromSlash(entry.Path))
+		status, err := importManifestEntry(path, entry, opt)
+		onImported(path, entry, status, err)
+	}
+}
+
+func importManifestEntry(path string, entry *ManifestEntry, opt ImportOption) (ImportStatus, error) {
+	file, err := OpenFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return IMPORT_NOT_FOUND, nil
+		}
+		return 0, err
+	}
+	// nolint:errcheck
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	mtimeDiff := info.ModTime().Sub(time.Unix(0, entry.ModTime))
+	if mtimeDiff < 0 {
+		mtimeDiff = -mtimeDiff
+	}
+	if info.Size() != entry.Size || mtimeDiff > opt.MtimeWindow {
+		return IMPORT_MODIFIED, nil
+	}
+
+	hashes := make([]*Hash, len(entry.Hashes))
+	for i, h := range entry.Hashes {
+		hashes[i] = NewHash(path, h.Alg, h.Value, info.ModTime().Unix())
+	}
+
+	if opt.Verify {
+		algs := make([]*HashAlg, len(hashes))
+		for i, h := range hashes {
+			algs[i] = h.Alg
+		}
+		actual, err := CalcHashes(path, algs)
+		if err != nil {
+			return 0, err
+		}
+		for i, h := range hashes {
+			if !h.HasSameHashValue(actual[i]) {
+				return IMPORT_CORRUPTED, nil
+			}
+		}
+	}
+
+	// hash values of other algorithms may be stale
+	if err := ClearAttr(file); err != nil {
+		return 0, NewUpdateError(err)
+	}
+	for _, h := range hashes {
+		if err := SetAttr(file, h.Alg.AttrName, h.String()); err != nil {
+			return 0, NewUpdateError(err)
+		}
+	}
+	if err := SetAttr(file, Xattr_size, fmt.Sprint(info.Size())); err != nil {
+		return 0, NewUpdateError(err)
+	}
+	if err := SetAttr(file, Xattr_modifiedTime, strconv.FormatInt(info.ModTime().UnixNano(), 10)); err != nil {
+		return 0, NewUpdateError(err)
+	}
+	// Checked time is recorded only when verified.
+	// Unverified files are treated as never checked by scrub.
+	if opt.Verify {
+		if err := updateHashCheckedTime(file); err != nil {
+			return 0, NewUpdateError(err)
+		}
+	}
+	setFileInfo(hashes, file, info)
+	if err := recordToCatalog(file, hashes); err != nil {
+		return 0, NewUpdateError(err)
+	}
+	return IMPORT_OK, nil
+}
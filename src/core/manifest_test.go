@@ -0,0 +1,74 @@
+package core
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportImportManifest(t *testing.T) {
+	sha1 := NewHashAlgFromString("sha1")
+	sha256 := NewHashAlgFromString("sha256")
+
+	srcDir := t.TempDir()
+	dstDir := t.TempDir()
+	assert.NoError(t, os.Mkdir(filepath.Join(srcDir, "sub"), 0755))
+	assert.NoError(t, os.Mkdir(filepath.Join(dstDir, "sub"), 0755))
+
+	expected := makeDummyFile(t, filepath.Join(srcDir, "sub", "file1.txt"), sha1)
+	makeDummyFile(t, filepath.Join(srcDir, "file2.txt"), sha1)
+	makeDummyFile(t, filepath.Join(srcDir, "nohash.txt"), sha1)
+	for _, name := range []string{filepath.Join("sub", "file1.txt"), "file2.txt"} {
+		_, _, err := UpdateHashes(filepath.Join(srcDir, name), []*HashAlg{sha1, sha256}, false)
+		assert.NoError(t, err)
+	}
+
+	buf := &bytes.Buffer{}
+	count, err := ExportManifest(srcDir, buf)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	manifestPath := filepath.Join(t.TempDir(), "manifest.tsv")
+	assert.NoError(t, os.WriteFile(manifestPath, buf.Bytes(), 0644))
+	entries, err := ReadManifest(manifestPath)
+	assert.NoError(t, err)
+	if assert.Equal(t, 2, len(entries)) {
+		assert.Equal(t, "file2.txt", entries[0].Path)
+		assert.Equal(t, "sub/file1.txt", entries[1].Path)
+		assert.Equal(t, 2, len(entries[1].Hashes))
+	}
+
+	// copy without extended attributes
+	copyFile(t, filepath.Join(srcDir, "sub"), filepath.Join(dstDir, "sub"), "file1.txt")
+	copyFile(t, srcDir, dstDir, "file2.txt")
+	touchDelta(t, filepath.Join(dstDir, "file2.txt"), filepath.Join(srcDir, "file2.txt"), time.Minute)
+
+	results := make(map[string]ImportStatus)
+	ImportManifest(entries, dstDir, ImportOption{Verify: true}, func(path string, entry *ManifestEntry, status ImportStatus, err error) {
+		assert.NoError(t, err)
+		results[entry.Path] = status
+	})
+	assert.Equal(t, IMPORT_OK, results["sub/file1.txt"])
+	assert.Equal(t, IMPORT_MODIFIED, results["file2.txt"])
+
+	// imported hash value is treated as up-to-date
+	changed, hash, err := UpdateHash(filepath.Join(dstDir, "sub", "file1.txt"), sha1, false)
+	assert.NoError(t, err)
+	assert.False(t, changed)
+	assert.Equal(t, expected, hash.String())
+
+	hash, err = GetHash(filepath.Join(dstDir, "file2.txt"), sha1)
+	assert.NoError(t, err)
+	assert.Nil(t, hash)
+}
+
+func TestParseManifestLine_invalidPath(t *testing.T) {
+	for _, p := range []string{"../escape.txt", "/abs.txt", "a/../../b.txt"} {
+		_, err := parseManifestLine([]string{p, "x", "0", "sha1:da39a3ee5e6b4b0d3255bfef95601890afd80709", "size:0", "mtime:0"})
+		assert.Error(t, err, p)
+	}
+}
@@ -0,0 +1,177 @@
+package core
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"time"
+)
+
+// Output formats of listing commands
+const (
+	OutputFormat_Tsv    = "tsv"
+	OutputFormat_Json   = "json"
+	OutputFormat_Ndjson = "ndjson"
+)
+
+// OutputFormatNames returns names of all output formats.
+func OutputFormatNames() []string {
+	return []string{OutputFormat_Tsv, OutputFormat_Json, OutputFormat_Ndjson}
+}
+
+// FormatRecordTime formats times of records in RFC3339 with nanoseconds,
+// so that all kinds of records of the same file have the same time.
+func FormatRecordTime(t time.Time) string {
+	return t.Format(time.RFC3339Nano)
+}
+
+// RecordWriter writes records as a JSON array or newline delimited JSON.
+type RecordWriter struct {
+	w      io.Writer
+	format string
+	count  int
+}
+
+func NewRecordWriter(w io.Writer, format string) (*RecordWriter, error) {
+	if format != OutputFormat_Json && format != OutputFormat_Ndjson {
+		return nil, fmt.Errorf("unsupported record format : %s", format)
+	}
+	return &RecordWriter{w: w, format: format}, nil
+}
+
+// Write writes a record encoded to JSON.
+func (rw *RecordWriter) Write(record interface{}) error {
+	b, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	if rw.format == OutputFormat_Json {
+		sep := ",\n"
+		if rw.count == 0 {
+			sep = "[\n"
+		}
+		if _, err = io.WriteString(rw.w, sep); err != nil {
+			return err
+		}
+	}
+	rw.count++
+
+	if _, err = rw.w.Write(b); err != nil {
+		return err
+	}
+	if rw.format == OutputFormat_Ndjson {
+		_, err = io.WriteString(rw.w, "\n")
+	}
+	return err
+}
+
+// Close terminates the JSON array.
+// It must be called even if no record has been written.
+func (rw *RecordWriter) Close() error {
+	if rw.format != OutputFormat_Json {
+		return nil
+	}
+	end := "\n]\n"
+	if rw.count == 0 {
+		end = "[]\n"
+	}
+	_, err := io.WriteString(rw.w, end)
+	return err
+}
+
+// ------------------------------------------------------------------------------
+
+// DuplicateRecord is the JSON representation of a result of the duplicate sub-command.
+type DuplicateRecord struct {
+	Source     *HashRecord  `json:"source"`
+	Duplicates []HashRecord `json:"duplicates"`
+	// hard links of the source, which are already deduplicated
+	HardLinks []HashRecord `json:"hardlinks"`
+}
+
+func NewDuplicateRecord(source *Hash, duplicates []*Hash, hardLinks []*Hash) DuplicateRecord {
+	src := NewHashRecord(source.Path, []*Hash{source})
+	r := DuplicateRecord{
+		Source:     &src,
+		Duplicates: make([]HashRecord, len(duplicates)),
+		HardLinks:  make([]HashRecord, len(hardLinks)),
+	}
+	for i, d := range duplicates {
+		r.Duplicates[i] = NewHashRecord(d.Path, []*Hash{d})
+	}
+	for i, h := range hardLinks {
+		r.HardLinks[i] = NewHashRecord(h.Path, []*Hash{h})
+	}
+	return r
+}
+
+// DiffRecord is the JSON representation of a compared file.
+type DiffRecord struct {
+	// path of the file (dirPath joined with the file name)
+	Path   string `json:"path"`
+	Status string `json:"status"`
+	Mark   string `json:"mark"`
+	// path of the paired file when it has a different name
+	PairPath  string `json:"pair,omitempty"`
+	Algorithm string `json:"algorithm"`
+	Hash      string `json:"hash"`
+	// modified time (see FormatRecordTime)
+	ModTime string `json:"mtime"`
+}
+
+func NewDiffRecord(dirPath string, f *FileDiff, alg *HashAlg) DiffRecord {
+	r := DiffRecord{
+		Path:      filepath.Join(dirPath, f.Basename),
+		Status:    f.Status.String(),
+		Mark:      f.StatusMark(),
+		Algorithm: alg.AlgName,
+		Hash:      fmt.Sprintf("%x", f.HashValue),
+		ModTime:   FormatRecordTime(f.ModTime),
+	}
+	if f.PairFileName != "" && f.PairFileName != f.Basename {
+		r.PairPath = filepath.Join(dirPath, f.PairFileName)
+	}
+	return r
+}
+
+// ------------------------------------------------------------------------------
+
+// hashListWriter writes hash values of each file in given format.
+type hashListWriter struct {
+	w       *bufio.Writer
+	records *RecordWriter
+	format  string
+}
+
+func newHashListWriter(w io.Writer, format string) *hashListWriter {
+	hw := &hashListWriter{
+		w:      bufio.NewWriterSize(w, 16384),
+		format: format,
+	}
+	if format == OutputFormat_Json || format == OutputFormat_Ndjson {
+		hw.records, _ = NewRecordWriter(hw.w, format)
+	}
+	return hw
+}
+
+// write writes hash values of the same file.
+// path is used for the coreutils and bsd formats.
+func (hw *hashListWriter) write(path string, hashes []*Hash) error {
+	if hw.records != nil {
+		return hw.records.Write(NewHashRecord(hashes[0].Path, hashes))
+	}
+	_, err := fmt.Fprintf(hw.w, "%s\n", FormatHashes(hw.format, path, hashes))
+	return err
+}
+
+func (hw *hashListWriter) close() error {
+	if hw.records != nil {
+		if err := hw.records.Close(); err != nil {
+			return err
+		}
+	}
+	return hw.w.Flush()
+}
@@ -0,0 +1,90 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordWriter_json(t *testing.T) {
+	buf := &bytes.Buffer{}
+	rw, err := NewRecordWriter(buf, OutputFormat_Json)
+	assert.NoError(t, err)
+	assert.NoError(t, rw.Close())
+	assert.Equal(t, "[]\n", buf.String())
+
+	buf.Reset()
+	rw, _ = NewRecordWriter(buf, OutputFormat_Json)
+	assert.NoError(t, rw.Write(map[string]int{"a": 1}))
+	assert.NoError(t, rw.Write(map[string]int{"b": 2}))
+	assert.NoError(t, rw.Close())
+	assert.Equal(t, "[\n{\"a\":1},\n{\"b\":2}\n]\n", buf.String())
+
+	_, err = NewRecordWriter(buf, OutputFormat_Tsv)
+	assert.Error(t, err)
+}
+
+func TestRecordWriter_ndjson(t *testing.T) {
+	buf := &bytes.Buffer{}
+	rw, err := NewRecordWriter(buf, OutputFormat_Ndjson)
+	assert.NoError(t, err)
+	assert.NoError(t, rw.Write(map[string]int{"a": 1}))
+	assert.NoError(t, rw.Write(map[string]int{"b": 2}))
+	assert.NoError(t, rw.Close())
+	assert.Equal(t, "{\"a\":1}\n{\"b\":2}\n", buf.String())
+}
+
+func TestHash_Json(t *testing.T) {
+	alg := NewDefaultHashAlg()
+	path := "/dir/\"quoted\"\\file.txt"
+	h, err := NewHashFromString(path, alg, "da39a3ee5e6b4b0d3255bfef95601890afd80709", 0)
+	assert.NoError(t, err)
+	h.Size = 10
+
+	r := HashRecord{}
+	assert.NoError(t, json.Unmarshal([]byte(h.Json()), &r))
+	assert.Equal(t, path, r.Path)
+	assert.Equal(t, int64(10), r.Size)
+	assert.Equal(t, []HashValue{{Algorithm: "sha1", Value: "da39a3ee5e6b4b0d3255bfef95601890afd80709"}}, r.Hashes)
+	assert.Equal(t, "", r.CheckedTime)
+}
+
+func TestUpdateHash_fileInfo(t *testing.T) {
+	alg := NewDefaultHashAlg()
+	path, _ := makeSingleDummyFile(t, alg)
+
+	_, hash, err := UpdateHash(path, alg, false)
+	assert.NoError(t, err)
+	assert.NotZero(t, hash.Size)
+	assert.NotZero(t, hash.CheckedTime)
+
+	r := NewHashRecord(path, []*Hash{hash})
+	assert.NotEmpty(t, r.ModTime)
+	assert.NotEmpty(t, r.CheckedTime)
+}
+
+func TestRecordTime(t *testing.T) {
+	alg := NewDefaultHashAlg()
+	path, _ := makeSingleDummyFile(t, alg)
+	mtime := time.Date(2024, 1, 2, 3, 4, 5, 123456789, time.Local)
+	assert.NoError(t, os.Chtimes(path, mtime, mtime))
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	expected := info.ModTime().Format(time.RFC3339Nano)
+
+	// file without hash value
+	r := NewFileRecord(path, info)
+	assert.Equal(t, expected, r.ModTime)
+
+	// the same file with hash value
+	_, hash, err := UpdateHash(path, alg, false)
+	assert.NoError(t, err)
+	r = NewHashRecord(path, []*Hash{hash})
+	assert.Equal(t, expected, r.ModTime)
+	_, err = time.Parse(time.RFC3339Nano, r.CheckedTime)
+	assert.NoError(t, err)
+}
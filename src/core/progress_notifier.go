@@ -1,13 +1,40 @@
 package core
 
+import (
+	"io"
+
+	. "github.com/little-forest/hasher/common" // nolint:staticcheck
+)
+
 type ProgressNotifier interface {
 	SetTotal(total int)
+	// SetFileCounter sets the counter of files which estimates the total
+	// until the total is known.
+	SetFileCounter(counter *FileCounter)
 	Start()
 	Shutdown()
 	NotifyTaskStart(workerId int, taskName string)
 	NotifyTaskDone(workerId int, message string)
 	NotifyProgress(done int, total int)
+	// NotifyBytes notifies that the worker has processed n bytes of the file.
+	// read is false if they are processed without reading, because the hash values are up to date.
+	// It may be called very often while reading files, so it must not block.
+	NotifyBytes(workerId int, n int64, read bool)
 	NotifyWarning(workerId int, message string)
 	NotifyError(workerId int, message string)
 	IsVerbose() bool
 }
+
+// progressReader calls onRead with the number of bytes read from r.
+type progressReader struct {
+	r      io.Reader
+	onRead func(n int64)
+}
+
+func (p *progressReader) Read(buf []byte) (int, error) {
+	n, err := p.r.Read(buf)
+	if n > 0 {
+		p.onRead(int64(n))
+	}
+	return n, err
+}
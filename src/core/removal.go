@@ -0,0 +1,138 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// ConfirmBackup recalculates hash values of the source file and candidates of its backup
+// without trusting stored attributes, and returns the source hash and the first candidate
+// which has the same hash value.
+// Since a short hash value may collide, a candidate is confirmed only when its contents
+// are also the same as the source byte-for-byte.
+// Candidates which are the source file itself or its hard links are never chosen.
+// If no candidate is confirmed, it returns nil as the candidate.
+func ConfirmBackup(srcPath string, candidates []*Hash, alg *HashAlg) (*Hash, *Hash, error) {
+	srcInfo, err := os.Stat(srcPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	srcHash, err := CalcHash(srcPath, alg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, c := range candidates {
+		info, err := os.Stat(c.Path)
+		if err != nil || os.SameFile(srcInfo, info) {
+			continue
+		}
+		h, err := CalcHash(c.Path, alg)
+		if err != nil {
+			continue
+		}
+		if !h.HasSameHashValue(srcHash) {
+			continue
+		}
+		if same, err := hasSameContents(srcPath, c.Path); err == nil && same {
+			return srcHash, h, nil
+		}
+	}
+	return srcHash, nil, nil
+}
+
+// TrashPath returns the path where the file is moved in the trash directory.
+// The absolute path of the file is kept under the trash directory,
+// so that files of the same name never collide.
+func TrashPath(trashDir string, path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	abs = strings.TrimPrefix(abs, filepath.VolumeName(abs))
+	return filepath.Join(trashDir, abs), nil
+}
+
+// MoveToTrash moves the file into the trash directory, and returns the moved path.
+// When the trash directory is on another filesystem, the file is copied and
+// removed only after the copy is confirmed to have the same contents.
+// An existing file in the trash directory is never overwritten.
+func MoveToTrash(trashDir string, path string) (string, error) {
+	dst, err := TrashPath(trashDir, path)
+	if err != nil {
+		return "", err
+	}
+	if _, e := os.Lstat(dst); e == nil {
+		return "", fmt.Errorf("file already exists in trash : %s", dst)
+	}
+	if err = os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return "", err
+	}
+
+	err = os.Rename(path, dst)
+	if err == nil {
+		return dst, nil
+	}
+	if !errors.Is(err, syscall.EXDEV) {
+		return "", err
+	}
+
+	if err = copyFileExclusively(path, dst, nil); err != nil {
+		return "", err
+	}
+	same, err := hasSameContents(path, dst)
+	if err == nil && !same {
+		err = fmt.Errorf("copied file differs from the original : %s", dst)
+	}
+	if err != nil {
+		os.Remove(dst) // nolint:errcheck
+		return "", err
+	}
+	return dst, os.Remove(path)
+}
+
+// copyFileExclusively copies the file with its mode and mtime.
+// It fails if dst already exists.
+// If h is not nil, contents read from src are also written to it.
+func copyFileExclusively(src string, dst string, h hash.Hash) error {
+	s, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	// nolint:errcheck
+	defer s.Close()
+
+	info, err := s.Stat()
+	if err != nil {
+		return err
+	}
+	d, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	var r io.Reader = s
+	if h != nil {
+		r = io.TeeReader(s, h)
+	}
+	if _, err := io.CopyBuffer(d, r, make([]byte, hashBufSize)); err != nil {
+		d.Close()      // nolint:errcheck
+		os.Remove(dst) // nolint:errcheck
+		return err
+	}
+	if err := d.Sync(); err != nil {
+		d.Close()      // nolint:errcheck
+		os.Remove(dst) // nolint:errcheck
+		return err
+	}
+	if err := d.Close(); err != nil {
+		os.Remove(dst) // nolint:errcheck
+		return err
+	}
+	return os.Chtimes(dst, info.ModTime(), info.ModTime())
+}
@@ -0,0 +1,99 @@
+package core
+
+import (
+	"hash"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfirmBackup(t *testing.T) {
+	alg := NewDefaultHashAlg()
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src.txt")
+	backup := filepath.Join(dir, "backup.txt")
+	link := filepath.Join(dir, "link.txt")
+	assert.NoError(t, os.WriteFile(src, []byte("contents"), 0o644))
+	assert.NoError(t, os.WriteFile(backup, []byte("contents"), 0o644))
+	assert.NoError(t, os.Link(src, link))
+
+	stored, err := CalcHash(backup, alg)
+	assert.NoError(t, err)
+	linkHash := NewHash(link, alg, stored.Value, 0)
+
+	srcHash, confirmed, err := ConfirmBackup(src, []*Hash{linkHash, stored}, alg)
+	assert.NoError(t, err)
+	assert.True(t, srcHash.HasSameHashValue(stored))
+	if assert.NotNil(t, confirmed) {
+		// hard links of the source are never chosen
+		assert.Equal(t, backup, confirmed.Path)
+	}
+
+	// the backup has been changed since its hash value was stored
+	assert.NoError(t, os.WriteFile(backup, []byte("changed!"), 0o644))
+	_, confirmed, err = ConfirmBackup(src, []*Hash{linkHash, stored}, alg)
+	assert.NoError(t, err)
+	assert.Nil(t, confirmed)
+}
+
+// collidingHash is a hash whose value never depends on the contents.
+type collidingHash struct {
+	hash.Hash
+}
+
+func (h collidingHash) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+func TestConfirmBackup_collision(t *testing.T) {
+	alg := &HashAlg{
+		New:      func() hash.Hash { return collidingHash{crc32.NewIEEE()} },
+		AlgName:  "colliding",
+		AttrName: Xattr_prefix + ".colliding",
+		Size:     crc32.Size,
+	}
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src.txt")
+	other := filepath.Join(dir, "other.txt")
+	assert.NoError(t, os.WriteFile(src, []byte("contents"), 0o644))
+	assert.NoError(t, os.WriteFile(other, []byte("CONTENTS"), 0o644))
+
+	stored, err := CalcHash(other, alg)
+	assert.NoError(t, err)
+
+	srcHash, confirmed, err := ConfirmBackup(src, []*Hash{stored}, alg)
+	assert.NoError(t, err)
+	// hash values are the same, but contents differ
+	assert.True(t, srcHash.HasSameHashValue(stored))
+	assert.Nil(t, confirmed)
+}
+
+func TestMoveToTrash(t *testing.T) {
+	dir := t.TempDir()
+	trash := filepath.Join(dir, "trash")
+	src := filepath.Join(dir, "card", "DCIM", "1.jpg")
+	assert.NoError(t, os.MkdirAll(filepath.Dir(src), 0o755))
+	assert.NoError(t, os.WriteFile(src, []byte("photo"), 0o644))
+
+	moved, err := MoveToTrash(trash, src)
+	assert.NoError(t, err)
+	expected, _ := TrashPath(trash, src)
+	assert.Equal(t, expected, moved)
+	assert.True(t, strings.HasPrefix(moved, trash+string(filepath.Separator)))
+	assert.NoFileExists(t, src)
+	b, err := os.ReadFile(moved)
+	assert.NoError(t, err)
+	assert.Equal(t, "photo", string(b))
+
+	// never overwrite a file already in the trash
+	assert.NoError(t, os.WriteFile(src, []byte("another"), 0o644))
+	_, err = MoveToTrash(trash, src)
+	assert.Error(t, err)
+	assert.FileExists(t, src)
+	b, _ = os.ReadFile(moved)
+	assert.Equal(t, "photo", string(b))
+}
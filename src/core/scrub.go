@@ -0,0 +1,124 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	. "github.com/little-forest/hasher/common" // nolint:staticcheck
+)
+
+type ScrubTarget struct {
+	// hash checked time
+	CheckedTime time.Time
+	Path        string
+	Size        int64
+	// true if size or mtime has changed since the hash was calculated
+	Modified bool
+}
+
+// Limits of a single scrub run.
+// Zero value means unlimited.
+type ScrubBudget struct {
+	MaxBytes    int64
+	MaxDuration time.Duration
+}
+
+// GetHashCheckedTime returns the time when the hash was checked last.
+// When it has not been recorded, it will return false.
+func GetHashCheckedTime(file *os.File) (time.Time, bool) {
+	htime, err := strconv.ParseInt(GetAttr(file, Xattr_hashCheckedTime), 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(0, htime), true
+}
+
+// ListScrubTargets lists files under given directories
+// whose hash was checked before `checkedBefore`.
+// Files which have no hash value are excluded.
+// The result is sorted in order of oldest checked time,
+// except that modified files come last since they are never read by VerifyHash
+// and their checked time is never updated.
+func ListScrubTargets(dirPaths []string, algs []*HashAlg, checkedBefore time.Time) ([]ScrubTarget, error) {
+	targets := make([]ScrubTarget, 0)
+
+	err := WalkDirs(dirPaths, func(f *os.File) error {
+		hasHash := false
+		for _, alg := range algs {
+			if GetAttr(f, alg.AttrName) != "" {
+				hasHash = true
+				break
+			}
+		}
+		if !hasHash {
+			return nil
+		}
+
+		// a file whose checked time isn't recorded is treated as never checked
+		htime, _ := GetHashCheckedTime(f)
+		if !htime.Before(checkedBefore) {
+			return nil
+		}
+
+		info, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		modified := GetAttr(f, Xattr_size) != fmt.Sprint(info.Size()) ||
+			GetAttr(f, Xattr_modifiedTime) != strconv.FormatInt(info.ModTime().UnixNano(), 10)
+		targets = append(targets, ScrubTarget{
+			Path:        f.Name(),
+			CheckedTime: htime,
+			Size:        info.Size(),
+			Modified:    modified,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(targets, func(i, j int) bool {
+		if targets[i].Modified != targets[j].Modified {
+			return !targets[i].Modified
+		}
+		return targets[i].CheckedTime.Before(targets[j].CheckedTime)
+	})
+	return targets, nil
+}
+
+// Scrub verifies given targets in order until the budget is exhausted.
+// Only bytes actually read count against MaxBytes,
+// and targets which don't fit in the rest of it are skipped so that smaller ones can still be verified.
+// At least one target is verified regardless of the budget.
+// onVerified is called for each target with the result of VerifyHash.
+//
+//	number of verified targets : int
+//	read bytes : int64
+func Scrub(targets []ScrubTarget, algs []*HashAlg, budget ScrubBudget, onVerified func(target ScrubTarget, result *VerifyResult, err error)) (int, int64) {
+	start := time.Now()
+
+	var bytes int64
+	count := 0
+	for _, t := range targets {
+		if count > 0 {
+			if budget.MaxDuration > 0 && time.Since(start) >= budget.MaxDuration {
+				break
+			}
+			if budget.MaxBytes > 0 && !t.Modified && bytes+t.Size > budget.MaxBytes {
+				continue
+			}
+		}
+
+		result, err := VerifyHash(t.Path, algs)
+		onVerified(t, result, err)
+		if result != nil && result.Actual != nil {
+			bytes += t.Size
+		}
+		count++
+	}
+	return count, bytes
+}
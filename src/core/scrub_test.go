@@ -0,0 +1,112 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListScrubTargets(t *testing.T) {
+	alg := NewDefaultHashAlg()
+	algs := []*HashAlg{alg}
+	dir := t.TempDir()
+
+	paths := []string{
+		filepath.Join(dir, "test01"),
+		filepath.Join(dir, "test02"),
+		filepath.Join(dir, "test03"),
+	}
+	for _, p := range paths {
+		makeDummyFile(t, p, alg)
+	}
+	// test03 has no hash
+	for _, p := range paths[0:2] {
+		_, _, err := UpdateHash(p, alg, false)
+		assert.NoError(t, err)
+	}
+
+	// test02 was checked earlier than test01
+	setCheckedTime(t, paths[0], time.Now().Add(-24*time.Hour))
+	setCheckedTime(t, paths[1], time.Now().Add(-48*time.Hour))
+
+	targets, err := ListScrubTargets([]string{dir}, algs, time.Now().Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(targets))
+	assert.Equal(t, paths[1], targets[0].Path)
+	assert.Equal(t, paths[0], targets[1].Path)
+
+	targets, err = ListScrubTargets([]string{dir}, algs, time.Now().Add(-36*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(targets))
+
+	// budget
+	count, bytes := Scrub(targets, algs, ScrubBudget{MaxBytes: 1}, func(target ScrubTarget, result *VerifyResult, err error) {
+		assert.NoError(t, err)
+		assert.Equal(t, VERIFY_OK, result.Status)
+	})
+	assert.Equal(t, 1, count)
+	assert.Equal(t, targets[0].Size, bytes)
+
+	// checked time is updated
+	targets, err = ListScrubTargets([]string{dir}, algs, time.Now().Add(-36*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(targets))
+}
+
+func TestScrub_budget(t *testing.T) {
+	alg := NewDefaultHashAlg()
+	algs := []*HashAlg{alg}
+	dir := t.TempDir()
+
+	modified := filepath.Join(dir, "modified")
+	large := filepath.Join(dir, "large")
+	small := filepath.Join(dir, "small")
+	makeDummyFile(t, modified, alg)
+	assert.NoError(t, os.WriteFile(large, make([]byte, 1024), 0o644))
+	makeDummyFile(t, small, alg)
+	for _, p := range []string{modified, large, small} {
+		_, _, err := UpdateHash(p, alg, false)
+		assert.NoError(t, err)
+	}
+	setCheckedTime(t, modified, time.Now().Add(-72*time.Hour))
+	setCheckedTime(t, large, time.Now().Add(-48*time.Hour))
+	setCheckedTime(t, small, time.Now().Add(-24*time.Hour))
+	assert.NoError(t, os.WriteFile(modified, []byte("changed"), 0o644))
+
+	targets, err := ListScrubTargets([]string{dir}, algs, time.Now().Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(targets))
+	// modified file comes last though it was checked earliest
+	assert.Equal(t, modified, targets[2].Path)
+	assert.True(t, targets[2].Modified)
+
+	// the large file doesn't fit, but the rest are verified
+	assert.Equal(t, large, targets[0].Path)
+	assert.Equal(t, small, targets[1].Path)
+	targets = []ScrubTarget{targets[1], targets[0], targets[2]}
+	statuses := make(map[string]VerifyStatus)
+	count, bytes := Scrub(targets, algs, ScrubBudget{MaxBytes: targets[0].Size + 100}, func(target ScrubTarget, result *VerifyResult, err error) {
+		assert.NoError(t, err)
+		statuses[target.Path] = result.Status
+	})
+	assert.Equal(t, 2, count)
+	assert.Equal(t, targets[0].Size, bytes)
+	assert.Equal(t, VERIFY_OK, statuses[small])
+	assert.Equal(t, VERIFY_MODIFIED, statuses[modified])
+	assert.NotContains(t, statuses, large)
+}
+
+func setCheckedTime(t *testing.T, path string, checkedTime time.Time) {
+	t.Helper()
+
+	f, err := os.Open(path)
+	assert.NoError(t, err)
+	// nolint:errcheck
+	defer f.Close()
+
+	assert.NoError(t, SetAttr(f, Xattr_hashCheckedTime, strconv.FormatInt(checkedTime.UnixNano(), 10)))
+}
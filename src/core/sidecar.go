@@ -0,0 +1,258 @@
+package core
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/little-forest/hasher/common" // nolint:staticcheck
+)
+
+// Number of directories whose sidecar file is cached in memory
+const sidecarCacheSize = 256
+
+// Interval to write changed sidecar files
+const sidecarFlushInterval = 5 * time.Second
+
+// SidecarStore stores attributes to a sidecar file placed in each directory.
+// The sidecar file keeps the same attributes as extended attributes
+// for all files in the directory.
+//
+//	{"files": {"FILE_NAME": {"user.hasher.sha1": "...", "user.hasher.size": "...", ...}}}
+//
+// Changes are kept in memory until Flush() is called,
+// or written when sidecarFlushInterval has passed since they were written last.
+//
+// Sidecar files are cached by the directory whose symbolic links are resolved,
+// so that a directory reached by several paths shares the same one.
+type SidecarStore struct {
+	lastFlush time.Time
+	// directory -> sidecar file
+	dirs map[string]*sidecarFile
+	// directory -> directory whose symbolic links are resolved
+	resolved map[string]string
+	mu       sync.Mutex
+}
+
+type sidecarFile struct {
+	Files map[string]map[string]string `json:"files"`
+	path  string
+	dirty bool
+}
+
+func NewSidecarStore() *SidecarStore {
+	return &SidecarStore{
+		dirs:      make(map[string]*sidecarFile),
+		resolved:  make(map[string]string),
+		lastFlush: time.Now(),
+	}
+}
+
+func (s *SidecarStore) Get(file *os.File, attrName string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sf, name, err := s.load(file)
+	if err != nil {
+		return ""
+	}
+	return sf.Files[name][attrName]
+}
+
+func (s *SidecarStore) Set(file *os.File, attrName string, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sf, name, err := s.load(file)
+	if err != nil {
+		return err
+	}
+	attrs := sf.Files[name]
+	if attrs == nil {
+		attrs = make(map[string]string)
+		sf.Files[name] = attrs
+	}
+	attrs[attrName] = value
+	sf.dirty = true
+	return s.flushIfDue()
+}
+
+func (s *SidecarStore) Remove(file *os.File, attrName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sf, name, err := s.load(file)
+	if err != nil {
+		return err
+	}
+	if attrs, ok := sf.Files[name]; ok {
+		delete(attrs, attrName)
+		if len(attrs) == 0 {
+			delete(sf.Files, name)
+		}
+		sf.dirty = true
+	}
+	return s.flushIfDue()
+}
+
+func (s *SidecarStore) Clear(file *os.File) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sf, name, err := s.load(file)
+	if err != nil {
+		return err
+	}
+	if attrs, ok := sf.Files[name]; ok {
+		for attrName := range attrs {
+			if strings.HasPrefix(attrName, Xattr_prefix) {
+				delete(attrs, attrName)
+			}
+		}
+		if len(attrs) == 0 {
+			delete(sf.Files, name)
+		}
+		sf.dirty = true
+	}
+	return s.flushIfDue()
+}
+
+func (s *SidecarStore) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.flush()
+}
+
+func (s *SidecarStore) flush() error {
+	s.lastFlush = time.Now()
+	for _, sf := range s.dirs {
+		if err := sf.save(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flushIfDue writes changed sidecar files if sidecarFlushInterval has passed,
+// so that changes are not lost all together when the process is killed.
+func (s *SidecarStore) flushIfDue() error {
+	if time.Since(s.lastFlush) < sidecarFlushInterval {
+		return nil
+	}
+	return s.flush()
+}
+
+// resolveDir returns the directory whose symbolic links are resolved.
+// If it can't be resolved, the directory is returned as it is.
+func (s *SidecarStore) resolveDir(dir string) string {
+	if r, ok := s.resolved[dir]; ok {
+		return r
+	}
+	r, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		r = dir
+	}
+	s.resolved[dir] = r
+	return r
+}
+
+// load returns the sidecar file of the directory which contains given file,
+// and the key of the file.
+func (s *SidecarStore) load(file *os.File) (*sidecarFile, string, error) {
+	absPath, err := filepath.Abs(file.Name())
+	if err != nil {
+		return nil, "", err
+	}
+	dir, name := filepath.Split(absPath)
+	dir = s.resolveDir(dir)
+
+	if sf, ok := s.dirs[dir]; ok {
+		return sf, name, nil
+	}
+
+	if len(s.dirs) >= sidecarCacheSize {
+		if err = s.flush(); err != nil {
+			return nil, "", err
+		}
+		s.dirs = make(map[string]*sidecarFile)
+		s.resolved = make(map[string]string)
+	}
+
+	sf, err := loadSidecarFile(filepath.Join(dir, SidecarFileName))
+	if err != nil {
+		return nil, "", err
+	}
+	s.dirs[dir] = sf
+	return sf, name, nil
+}
+
+func loadSidecarFile(path string) (*sidecarFile, error) {
+	sf := &sidecarFile{path: path}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, sf); err != nil {
+			return nil, err
+		}
+	}
+	if sf.Files == nil {
+		sf.Files = make(map[string]map[string]string)
+	}
+	return sf, nil
+}
+
+// save writes the sidecar file atomically.
+// When no attributes remain, the sidecar file is removed.
+func (sf *sidecarFile) save() error {
+	if !sf.dirty {
+		return nil
+	}
+
+	if len(sf.Files) == 0 {
+		if err := os.Remove(sf.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		sf.dirty = false
+		return nil
+	}
+
+	data, err := json.Marshal(sf)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(sf.path), SidecarFileName+".*")
+	if err != nil {
+		return err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()           // nolint:errcheck
+		os.Remove(tmp.Name()) // nolint:errcheck
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()           // nolint:errcheck
+		os.Remove(tmp.Name()) // nolint:errcheck
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name()) // nolint:errcheck
+		return err
+	}
+	if err := os.Rename(tmp.Name(), sf.path); err != nil {
+		os.Remove(tmp.Name()) // nolint:errcheck
+		return err
+	}
+	sf.dirty = false
+	return nil
+}
+
+// check implementation
+var _ AttrStore = &SidecarStore{}
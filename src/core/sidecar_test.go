@@ -0,0 +1,106 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/little-forest/hasher/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func useAttrStore(t *testing.T, s AttrStore) {
+	t.Helper()
+
+	prev := attrStore
+	assert.NoError(t, SetAttrStore(s))
+	t.Cleanup(func() {
+		attrStore = prev
+	})
+}
+
+func TestSidecarStore(t *testing.T) {
+	useAttrStore(t, NewSidecarStore())
+
+	alg := NewDefaultHashAlg()
+	path, expectedHash := makeSingleDummyFile(t, alg)
+	sidecarPath := filepath.Join(filepath.Dir(path), common.SidecarFileName)
+
+	changed, hash, err := UpdateHash(path, alg, false)
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, expectedHash, hash.String())
+
+	// not written until flushed
+	assert.NoFileExists(t, sidecarPath)
+	assert.NoError(t, FlushAttrStore())
+	assert.FileExists(t, sidecarPath)
+
+	// extended attribute is not used
+	f, err := os.Open(path)
+	assert.NoError(t, err)
+	// nolint:errcheck
+	defer f.Close()
+	assert.Equal(t, "", GetXattr(f, alg.AttrName))
+
+	// load from sidecar file
+	useAttrStore(t, NewSidecarStore())
+	changed, hash, err = UpdateHash(path, alg, false)
+	assert.NoError(t, err)
+	assert.False(t, changed)
+	assert.Equal(t, expectedHash, hash.String())
+
+	// sidecar file is removed when all attributes are cleared
+	assert.NoError(t, ClearAttr(f))
+	assert.NoError(t, FlushAttrStore())
+	assert.NoFileExists(t, sidecarPath)
+}
+
+func TestSidecarStore_flushPeriodically(t *testing.T) {
+	s := NewSidecarStore()
+	useAttrStore(t, s)
+
+	alg := NewDefaultHashAlg()
+	path, _ := makeSingleDummyFile(t, alg)
+	sidecarPath := filepath.Join(filepath.Dir(path), common.SidecarFileName)
+
+	_, _, err := UpdateHash(path, alg, false)
+	assert.NoError(t, err)
+	assert.NoFileExists(t, sidecarPath)
+
+	// written without Flush() after the interval
+	s.lastFlush = time.Now().Add(-sidecarFlushInterval)
+	_, _, err = UpdateHash(path, alg, true)
+	assert.NoError(t, err)
+	assert.FileExists(t, sidecarPath)
+}
+
+func TestSidecarStore_symlinkDir(t *testing.T) {
+	useAttrStore(t, NewSidecarStore())
+
+	alg := NewDefaultHashAlg()
+	dir := t.TempDir()
+	path1 := filepath.Join(dir, "file1.txt")
+	path2 := filepath.Join(dir, "file2.txt")
+	hash1 := makeDummyFile(t, path1, alg)
+	hash2 := makeDummyFile(t, path2, alg)
+	link := filepath.Join(t.TempDir(), "link")
+	assert.NoError(t, os.Symlink(dir, link))
+
+	// the same sidecar file is updated through both paths
+	_, _, err := UpdateHash(path1, alg, false)
+	assert.NoError(t, err)
+	_, _, err = UpdateHash(filepath.Join(link, "file2.txt"), alg, false)
+	assert.NoError(t, err)
+	assert.NoError(t, FlushAttrStore())
+
+	useAttrStore(t, NewSidecarStore())
+	for p, expected := range map[string]string{path1: hash1, path2: hash2} {
+		h, err := GetHash(p, alg)
+		assert.NoError(t, err)
+		if assert.NotNil(t, h) {
+			assert.Equal(t, expected, h.String())
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"sort"
+	"strings"
+	"sync"
+
+	. "github.com/little-forest/hasher/common" // nolint:staticcheck
+)
+
+// Operations where errors occur
+const (
+	ErrorOp_Stat   = "stat"
+	ErrorOp_Walk   = "walk"
+	ErrorOp_Hash   = "hash"
+	ErrorOp_Update = "update"
+	ErrorOp_Copy   = "copy"
+)
+
+// ErrorRecord is an error occurred on a file.
+type ErrorRecord struct {
+	Path  string `json:"path"`
+	Op    string `json:"op"`
+	Error string `json:"error"`
+}
+
+// RunSummary counts results of processing files.
+// It is safe for concurrent use, and methods to record results do nothing on nil summary.
+type RunSummary struct {
+	// reason -> number of skipped files
+	skipped   map[string]int
+	errors    []ErrorRecord
+	scanned   int
+	unchanged int
+	updated   int
+	failed    int
+	mu        sync.Mutex
+}
+
+func NewRunSummary() *RunSummary {
+	return &RunSummary{
+		skipped: make(map[string]int),
+		errors:  make([]ErrorRecord, 0),
+	}
+}
+
+// AddScanned counts a file found as a target.
+func (s *RunSummary) AddScanned() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scanned++
+}
+
+// AddSkipped counts a file which is not processed for the reason.
+func (s *RunSummary) AddSkipped(path string, reason string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.skipped[reason]++
+}
+
+// AddHashed counts a file whose hash values are up to date.
+// changed is true if they have been calculated again.
+func (s *RunSummary) AddHashed(changed bool) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if changed {
+		s.updated++
+	} else {
+		s.unchanged++
+	}
+}
+
+// AddFailed counts a file which could not be processed.
+func (s *RunSummary) AddFailed(path string, op string, err error) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failed++
+	s.errors = append(s.errors, ErrorRecord{Path: path, Op: op, Error: err.Error()})
+}
+
+// AddError records an error which is not of a file, such as a directory failed to read.
+func (s *RunSummary) AddError(path string, op string, err error) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errors = append(s.errors, ErrorRecord{Path: path, Op: op, Error: err.Error()})
+}
+
+func (s *RunSummary) Scanned() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.scanned
+}
+
+// Hashed returns the number of files whose hash values are up to date.
+func (s *RunSummary) Hashed() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.unchanged + s.updated
+}
+
+func (s *RunSummary) Unchanged() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.unchanged
+}
+
+func (s *RunSummary) Updated() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.updated
+}
+
+func (s *RunSummary) Failed() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.failed
+}
+
+// Skipped returns the number of skipped files of each reason.
+func (s *RunSummary) Skipped() map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	skipped := make(map[string]int, len(s.skipped))
+	for k, v := range s.skipped {
+		skipped[k] = v
+	}
+	return skipped
+}
+
+func (s *RunSummary) Errors() []ErrorRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]ErrorRecord{}, s.errors...)
+}
+
+// Err returns PartialFailureError if any error has been recorded.
+func (s *RunSummary) Err() error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.errors) == 0 {
+		return nil
+	}
+	return &PartialFailureError{NumOfErrors: len(s.errors)}
+}
+
+// Write writes the summary in human readable form.
+func (s *RunSummary) Write(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "Scanned %d, hashed %d (unchanged %d, updated %d), failed %d\n",
+		s.Scanned(), s.Hashed(), s.Unchanged(), s.Updated(), s.Failed())
+	if err != nil {
+		return err
+	}
+
+	skipped := s.Skipped()
+	if len(skipped) == 0 {
+		return nil
+	}
+	reasons := make([]string, 0, len(skipped))
+	for r := range skipped {
+		reasons = append(reasons, r)
+	}
+	sort.Strings(reasons)
+	items := make([]string, len(reasons))
+	for i, r := range reasons {
+		items[i] = fmt.Sprintf("%s %d", r, skipped[r])
+	}
+	_, err = fmt.Fprintf(w, "Skipped %s\n", strings.Join(items, ", "))
+	return err
+}
+
+type summaryRecord struct {
+	Skipped   map[string]int `json:"skipped"`
+	Scanned   int            `json:"scanned"`
+	Hashed    int            `json:"hashed"`
+	Unchanged int            `json:"unchanged"`
+	Updated   int            `json:"updated"`
+	Failed    int            `json:"failed"`
+}
+
+type errorReport struct {
+	Errors  []ErrorRecord `json:"errors"`
+	Summary summaryRecord `json:"summary"`
+}
+
+// WriteErrorReport writes the summary and all errors in JSON.
+func (s *RunSummary) WriteErrorReport(w io.Writer) error {
+	report := errorReport{
+		Summary: summaryRecord{
+			Scanned:   s.Scanned(),
+			Hashed:    s.Hashed(),
+			Unchanged: s.Unchanged(),
+			Updated:   s.Updated(),
+			Failed:    s.Failed(),
+			Skipped:   s.Skipped(),
+		},
+		Errors: s.Errors(),
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+// PartialFailureError is returned when some files could not be processed.
+type PartialFailureError struct {
+	NumOfErrors int
+}
+
+func (e *PartialFailureError) Error() string {
+	return fmt.Sprintf("%d error(s) occurred", e.NumOfErrors)
+}
+
+// walkTargets walks the directory in the same way as WalkDirFiltered,
+// except that hasher's own files are not passed to fn.
+// Errors and skipped files are recorded to the summary instead of stopping the walk.
+func walkTargets(root string, summary *RunSummary, fn func(path string, d fs.DirEntry) error) error {
+	f := NewPathFilter(root)
+	f.OnSkip(summary.AddSkipped)
+	return f.WalkDir(func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			summary.AddError(path, ErrorOp_Walk, err)
+			return nil
+		}
+		if !d.IsDir() && IsHasherFile(path) {
+			return nil
+		}
+		return fn(path, d)
+	})
+}
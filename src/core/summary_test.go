@@ -0,0 +1,77 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/little-forest/hasher/common" // nolint:staticcheck
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConcurrentUpdateHash_summary(t *testing.T) {
+	alg := NewDefaultHashAlg()
+	dir := t.TempDir()
+	makeDummyFile(t, filepath.Join(dir, "a.txt"), alg)
+	makeDummyFile(t, filepath.Join(dir, "b.txt"), alg)
+	assert.NoError(t, os.Symlink("a.txt", filepath.Join(dir, "link")))
+
+	// the first run updates all files
+	summary := NewRunSummary()
+	err := ConcurrentUpdateHash([]string{dir}, []*HashAlg{alg}, false, NewIOScheduler(2, 1), nil, summary, &nopProgressNotifier{})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, summary.Scanned())
+	assert.Equal(t, 2, summary.Updated())
+	assert.Equal(t, 0, summary.Unchanged())
+	assert.Equal(t, map[string]int{SkipReason_Symlink: 1}, summary.Skipped())
+
+	// the second run with a missing directory
+	missing := filepath.Join(dir, "missing")
+	summary = NewRunSummary()
+	err = ConcurrentUpdateHash([]string{dir, missing}, []*HashAlg{alg}, false, NewIOScheduler(2, 1), nil, summary, &nopProgressNotifier{})
+	var partial *PartialFailureError
+	assert.True(t, errors.As(err, &partial))
+	assert.Equal(t, 2, summary.Hashed())
+	assert.Equal(t, 2, summary.Unchanged())
+	if assert.Len(t, summary.Errors(), 1) {
+		assert.Equal(t, missing, summary.Errors()[0].Path)
+		assert.Equal(t, ErrorOp_Stat, summary.Errors()[0].Op)
+	}
+}
+
+func TestRunSummary_WriteErrorReport(t *testing.T) {
+	summary := NewRunSummary()
+	summary.AddScanned()
+	summary.AddScanned()
+	summary.AddHashed(true)
+	summary.AddFailed("/a/b", ErrorOp_Hash, errors.New("read error"))
+	summary.AddSkipped("/a/c", SkipReason_Excluded)
+
+	var buf bytes.Buffer
+	assert.NoError(t, summary.WriteErrorReport(&buf))
+
+	var report errorReport
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &report))
+	assert.Equal(t, summaryRecord{
+		Scanned: 2,
+		Hashed:  1,
+		Updated: 1,
+		Failed:  1,
+		Skipped: map[string]int{SkipReason_Excluded: 1},
+	}, report.Summary)
+	assert.Equal(t, []ErrorRecord{{Path: "/a/b", Op: ErrorOp_Hash, Error: "read error"}}, report.Errors)
+
+	buf.Reset()
+	assert.NoError(t, summary.Write(&buf))
+	assert.Equal(t, "Scanned 2, hashed 1 (unchanged 0, updated 1), failed 1\nSkipped excluded 1\n", buf.String())
+}
+
+func TestRunSummary_nil(t *testing.T) {
+	var summary *RunSummary
+	summary.AddScanned()
+	summary.AddFailed("/a", ErrorOp_Hash, errors.New("error"))
+	assert.NoError(t, summary.Err())
+}
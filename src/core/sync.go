@@ -0,0 +1,128 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	. "github.com/little-forest/hasher/common" // nolint:staticcheck
+)
+
+// Layouts of files copied into the target directory
+const (
+	// keep the relative path from the source directory
+	SyncLayout_Relative = "relative"
+	// YYYY/MM/DD/FILENAME by mtime of the file
+	SyncLayout_Date = "date"
+)
+
+// SyncDestPath returns the path where the file is copied in the target directory.
+// relPath is the path of the file relative to its source directory.
+func SyncDestPath(targetDir string, relPath string, modTime time.Time, layout string) (string, error) {
+	switch layout {
+	case SyncLayout_Relative:
+		if filepath.IsAbs(relPath) || relPath == ".." || strings.HasPrefix(relPath, ".."+string(filepath.Separator)) {
+			return "", fmt.Errorf("file is not in the source directory : %s", relPath)
+		}
+		return filepath.Join(targetDir, relPath), nil
+	case SyncLayout_Date:
+		return filepath.Join(targetDir, modTime.Format("2006"), modTime.Format("01"), modTime.Format("02"), filepath.Base(relPath)), nil
+	default:
+		return "", fmt.Errorf("unsupported layout : %s", layout)
+	}
+}
+
+// UniquePath returns the path which is not taken,
+// by adding a number to the file name if necessary (e.g. name_1.ext).
+func UniquePath(path string, taken func(p string) bool) string {
+	if !taken(path) {
+		return path
+	}
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	for i := 1; ; i++ {
+		p := fmt.Sprintf("%s_%d%s", base, i, ext)
+		if !taken(p) {
+			return p
+		}
+	}
+}
+
+// CopyVerified copies the file to dst, which must not exist, with its mode and mtime.
+//
+// The source read while copying must have the expected hash value,
+// and the copy is read again after written to confirm it has the same value.
+// Then the hash value is written to attributes of the copy,
+// so that it doesn't need to be calculated again.
+// If only writing attributes fails, the copy is kept and an UpdateError is returned.
+func CopyVerified(src string, dst string, expected *Hash) (*Hash, error) {
+	if _, err := os.Lstat(dst); err == nil {
+		return nil, fmt.Errorf("file already exists : %s", dst)
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return nil, err
+	}
+
+	h := expected.Alg.New()
+	tmp, err := createTempFile(dst, TempFileSuffix_Sync, func(tmp string) error {
+		return copyFileExclusively(src, tmp, h)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(h.Sum(nil), expected.Value) {
+		os.Remove(tmp) // nolint:errcheck
+		return nil, fmt.Errorf("source has been changed since hashed : %s", src)
+	}
+
+	copied, err := CalcHash(tmp, expected.Alg)
+	if err == nil && !copied.HasSameHashValue(expected) {
+		err = fmt.Errorf("copied file differs from the source : %s", dst)
+	}
+	if err == nil {
+		err = os.Rename(tmp, dst)
+	}
+	if err != nil {
+		os.Remove(tmp) // nolint:errcheck
+		return nil, err
+	}
+	copied.Path = dst
+
+	if err := writeCopiedHash(copied); err != nil {
+		return copied, NewUpdateError(err)
+	}
+	return copied, nil
+}
+
+// writeCopiedHash writes the verified hash value to attributes of the copied file.
+func writeCopiedHash(h *Hash) error {
+	file, err := OpenFile(h.Path)
+	if err != nil {
+		return err
+	}
+	// nolint:errcheck
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if err := SetAttr(file, h.Alg.AttrName, h.String()); err != nil {
+		return err
+	}
+	if err := SetAttr(file, Xattr_size, fmt.Sprint(info.Size())); err != nil {
+		return err
+	}
+	if err := SetAttr(file, Xattr_modifiedTime, strconv.FormatInt(info.ModTime().UnixNano(), 10)); err != nil {
+		return err
+	}
+	if err := updateHashCheckedTime(file); err != nil {
+		return err
+	}
+	setFileInfo([]*Hash{h}, file, info)
+	return recordToCatalog(file, []*Hash{h})
+}
@@ -0,0 +1,86 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/little-forest/hasher/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyncDestPath(t *testing.T) {
+	mtime := time.Date(2024, 3, 9, 12, 0, 0, 0, time.Local)
+
+	p, err := SyncDestPath("/target", filepath.Join("DCIM", "1.jpg"), mtime, SyncLayout_Relative)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join("/target", "DCIM", "1.jpg"), p)
+
+	p, err = SyncDestPath("/target", filepath.Join("DCIM", "1.jpg"), mtime, SyncLayout_Date)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join("/target", "2024", "03", "09", "1.jpg"), p)
+
+	_, err = SyncDestPath("/target", filepath.Join("..", "1.jpg"), mtime, SyncLayout_Relative)
+	assert.Error(t, err)
+	_, err = SyncDestPath("/target", "1.jpg", mtime, "flat")
+	assert.Error(t, err)
+}
+
+func TestUniquePath(t *testing.T) {
+	taken := map[string]bool{"a/1.jpg": true, "a/1_1.jpg": true}
+	isTaken := func(p string) bool { return taken[p] }
+
+	assert.Equal(t, "a/2.jpg", UniquePath("a/2.jpg", isTaken))
+	assert.Equal(t, "a/1_2.jpg", UniquePath("a/1.jpg", isTaken))
+}
+
+func TestCopyVerified(t *testing.T) {
+	alg := NewDefaultHashAlg()
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src.txt")
+	dst := filepath.Join(dir, "target", "sub", "dst.txt")
+	assert.NoError(t, os.WriteFile(src, []byte("contents"), 0o640))
+	mtime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	assert.NoError(t, os.Chtimes(src, mtime, mtime))
+
+	expected, err := CalcHash(src, alg)
+	assert.NoError(t, err)
+
+	// left by an interrupted run of an older version
+	leftover := filepath.Join(filepath.Dir(dst), ".dst.txt"+common.TempFileSuffix_Sync)
+	assert.NoError(t, os.MkdirAll(filepath.Dir(dst), 0o755))
+	assert.NoError(t, os.WriteFile(leftover, []byte("leftover"), 0o644))
+
+	copied, err := CopyVerified(src, dst, expected)
+	assert.NoError(t, err)
+	if assert.NotNil(t, copied) {
+		assert.Equal(t, dst, copied.Path)
+		assert.True(t, copied.HasSameHashValue(expected))
+	}
+	info, err := os.Stat(dst)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o640), info.Mode().Perm())
+	assert.True(t, mtime.Equal(info.ModTime()))
+	b, _ := os.ReadFile(leftover)
+	assert.Equal(t, "leftover", string(b))
+
+	// the hash value is stored, so it is not calculated again
+	changed, h, err := UpdateHash(dst, alg, false)
+	assert.NoError(t, err)
+	assert.False(t, changed)
+	assert.True(t, h.HasSameHashValue(expected))
+
+	// never overwrite an existing file
+	_, err = CopyVerified(src, dst, expected)
+	assert.Error(t, err)
+
+	// the source has been changed since hashed
+	assert.NoError(t, os.WriteFile(src, []byte("changed!"), 0o640))
+	other := filepath.Join(dir, "target", "other.txt")
+	_, err = CopyVerified(src, other, expected)
+	assert.Error(t, err)
+	assert.NoFileExists(t, other)
+	entries, _ := os.ReadDir(filepath.Join(dir, "target"))
+	assert.Len(t, entries, 1)
+}
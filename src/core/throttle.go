@@ -0,0 +1,110 @@
+package core
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	. "github.com/little-forest/hasher/common" // nolint:staticcheck
+)
+
+// Interval to check the load average
+const loadCheckInterval = 5 * time.Second
+
+// Throttle limits reading files while calculating hash values.
+// It is shared by all workers.
+//
+// Read bandwidth is limited by a token bucket which is refilled by rate bytes per second,
+// and can hold tokens of 1 second at most.
+// When maxLoad is specified, reading is paused while the load average exceeds it.
+type Throttle struct {
+	// returns the current load average
+	loadAverage   func() (float64, error)
+	last          time.Time
+	lastLoadCheck time.Time
+	// bytes per second (0 : unlimited)
+	rate int64
+	// available bytes (may be negative while workers are waiting)
+	tokens float64
+	// (0 : disabled)
+	maxLoad  float64
+	lastLoad float64
+	mu       sync.Mutex
+}
+
+func NewThrottle(rate int64, maxLoad float64) *Throttle {
+	return &Throttle{
+		loadAverage: LoadAverage,
+		last:        time.Now(),
+		rate:        rate,
+		tokens:      float64(rate),
+		maxLoad:     maxLoad,
+	}
+}
+
+var throttle *Throttle
+
+// SetThrottle sets the throttle applied to reading files in CalcHashes.
+// Specify nil to read files at full speed.
+func SetThrottle(t *Throttle) {
+	throttle = t
+}
+
+// Wait blocks until n bytes can be read.
+func (t *Throttle) Wait(n int) {
+	if t.maxLoad > 0 {
+		for t.isOverloaded() {
+			time.Sleep(loadCheckInterval)
+		}
+	}
+
+	if t.rate <= 0 {
+		return
+	}
+
+	t.mu.Lock()
+	now := time.Now()
+	t.tokens += now.Sub(t.last).Seconds() * float64(t.rate)
+	if t.tokens > float64(t.rate) {
+		t.tokens = float64(t.rate)
+	}
+	t.last = now
+	t.tokens -= float64(n)
+	deficit := -t.tokens
+	t.mu.Unlock()
+
+	if deficit > 0 {
+		time.Sleep(time.Duration(deficit / float64(t.rate) * float64(time.Second)))
+	}
+}
+
+// isOverloaded returns true if the load average exceeds maxLoad.
+// The load average is checked at most once per loadCheckInterval.
+func (t *Throttle) isOverloaded() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if time.Since(t.lastLoadCheck) >= loadCheckInterval {
+		load, err := t.loadAverage()
+		if err != nil {
+			// can't pause without the load average
+			load = 0
+		}
+		t.lastLoad = load
+		t.lastLoadCheck = time.Now()
+	}
+	return t.lastLoad > t.maxLoad
+}
+
+type throttledReader struct {
+	r io.Reader
+	t *Throttle
+}
+
+func (r *throttledReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 {
+		r.t.Wait(n)
+	}
+	return n, err
+}
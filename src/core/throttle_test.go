@@ -0,0 +1,64 @@
+package core
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThrottle_rate(t *testing.T) {
+	const rate = 1024 * 1024
+	th := NewThrottle(rate, 0)
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 4; j++ {
+				th.Wait(rate / 8)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// 2 x rate bytes, where the first rate bytes are allowed by the initial tokens
+	elapsed := time.Since(start)
+	assert.GreaterOrEqual(t, elapsed, 900*time.Millisecond)
+	assert.Less(t, elapsed, 3*time.Second)
+}
+
+func TestThrottle_maxLoad(t *testing.T) {
+	th := NewThrottle(0, 2.0)
+	th.loadAverage = func() (float64, error) { return 1.5, nil }
+
+	done := make(chan struct{})
+	go func() {
+		th.Wait(1)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		assert.Fail(t, "throttled under max load")
+	}
+
+	th.lastLoadCheck = time.Time{}
+	th.loadAverage = func() (float64, error) { return 3.0, nil }
+	assert.True(t, th.isOverloaded())
+}
+
+func TestCalcHash_throttle(t *testing.T) {
+	alg := NewDefaultHashAlg()
+	path, expected := makeSingleDummyFile(t, alg)
+
+	SetThrottle(NewThrottle(1024*1024, 0))
+	t.Cleanup(func() { SetThrottle(nil) })
+
+	h, err := CalcHash(path, alg)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, h.String())
+}
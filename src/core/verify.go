@@ -0,0 +1,129 @@
+package core
+
+import (
+	"fmt"
+	"strconv"
+
+	. "github.com/little-forest/hasher/common" // nolint:staticcheck
+)
+
+type VerifyStatus uint8
+
+const (
+	// hash value matches the file contents
+	VERIFY_OK VerifyStatus = iota + 1
+	// file contents changed while size and mtime are unchanged (bit rot)
+	VERIFY_CORRUPTED
+	// size or mtime changed after hash was calculated
+	VERIFY_MODIFIED
+	// hash value has not been calculated yet
+	VERIFY_NO_HASH
+)
+
+type VerifyResult struct {
+	Path string
+	// stored hash values
+	Expected []*Hash
+	// recalculated hash values (same order as Expected)
+	Actual []*Hash
+	Status VerifyStatus
+}
+
+// Returns hash values which don't match.
+//
+//	expected : []*Hash
+//	actual : []*Hash
+func (r VerifyResult) Mismatches() ([]*Hash, []*Hash) {
+	expected := make([]*Hash, 0)
+	actual := make([]*Hash, 0)
+	for i, e := range r.Expected {
+		if i < len(r.Actual) && !e.HasSameHashValue(r.Actual[i]) {
+			expected = append(expected, e)
+			actual = append(actual, r.Actual[i])
+		}
+	}
+	return expected, actual
+}
+
+func (s VerifyStatus) String() string {
+	switch s {
+	case VERIFY_OK:
+		return "OK"
+	case VERIFY_CORRUPTED:
+		return "CORRUPTED"
+	case VERIFY_MODIFIED:
+		return "MODIFIED"
+	case VERIFY_NO_HASH:
+		return "NO_HASH"
+	}
+	return "UNKNOWN"
+}
+
+// VerifyHash recalculates hash values of given file and compares them with stored values.
+// Stored hash values are never overwritten.
+// Only when the file is verified successfully, hash checked time is updated.
+//
+// When size or mtime has changed since the hash was calculated,
+// the file is not read and VERIFY_MODIFIED is returned.
+func VerifyHash(path string, algs []*HashAlg) (*VerifyResult, error) {
+	file, err := OpenFile(path)
+	if err != nil {
+		return nil, err
+	}
+	// nolint:errcheck
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &VerifyResult{
+		Path:     path,
+		Expected: make([]*Hash, 0, len(algs)),
+	}
+
+	targetAlgs := make([]*HashAlg, 0, len(algs))
+	for _, alg := range algs {
+		curHash := GetAttr(file, alg.AttrName)
+		if curHash == "" {
+			continue
+		}
+		h, e := NewHashFromString(path, alg, curHash, info.ModTime().Unix())
+		if e != nil {
+			return nil, fmt.Errorf("invalid hash value : %s (%s)", path, alg.AttrName)
+		}
+		result.Expected = append(result.Expected, h)
+		targetAlgs = append(targetAlgs, alg)
+	}
+
+	if len(targetAlgs) == 0 {
+		result.Status = VERIFY_NO_HASH
+		return result, nil
+	}
+
+	size := fmt.Sprint(info.Size())
+	modTime := strconv.FormatInt(info.ModTime().UnixNano(), 10)
+	if GetAttr(file, Xattr_size) != size || GetAttr(file, Xattr_modifiedTime) != modTime {
+		result.Status = VERIFY_MODIFIED
+		return result, nil
+	}
+
+	result.Actual, err = CalcHashes(path, targetAlgs)
+	if err != nil {
+		return nil, err
+	}
+
+	result.Status = VERIFY_OK
+	for i, e := range result.Expected {
+		if !e.HasSameHashValue(result.Actual[i]) {
+			result.Status = VERIFY_CORRUPTED
+			return result, nil
+		}
+	}
+
+	if err := updateHashCheckedTime(file); err != nil {
+		return result, NewUpdateError(err)
+	}
+	return result, nil
+}
@@ -0,0 +1,55 @@
+package core
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyHash(t *testing.T) {
+	alg := NewDefaultHashAlg()
+	algs := []*HashAlg{alg}
+	path, _ := makeSingleDummyFile(t, alg)
+
+	// no hash
+	result, err := VerifyHash(path, algs)
+	assert.NoError(t, err)
+	assert.Equal(t, VERIFY_NO_HASH, result.Status)
+
+	_, _, err = UpdateHash(path, alg, false)
+	assert.NoError(t, err)
+
+	// ok
+	result, err = VerifyHash(path, algs)
+	assert.NoError(t, err)
+	assert.Equal(t, VERIFY_OK, result.Status)
+
+	// corrupted : rewrite contents keeping size and mtime
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	contents, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	contents[0] ^= 0xff
+	assert.NoError(t, os.WriteFile(path, contents, 0644))
+	assert.NoError(t, os.Chtimes(path, time.Now(), info.ModTime()))
+
+	result, err = VerifyHash(path, algs)
+	assert.NoError(t, err)
+	assert.Equal(t, VERIFY_CORRUPTED, result.Status)
+	expected, actual := result.Mismatches()
+	assert.Equal(t, 1, len(expected))
+	assert.NotEqual(t, expected[0].String(), actual[0].String())
+
+	// stored hash value must not be overwritten
+	hash, err := GetHash(path, alg)
+	assert.NoError(t, err)
+	assert.Equal(t, expected[0].String(), hash.String())
+
+	// modified
+	assert.NoError(t, os.Chtimes(path, time.Now(), info.ModTime().Add(time.Minute)))
+	result, err = VerifyHash(path, algs)
+	assert.NoError(t, err)
+	assert.Equal(t, VERIFY_MODIFIED, result.Status)
+}